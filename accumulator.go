@@ -0,0 +1,61 @@
+package main
+
+import "math"
+
+// Accumulator summarizes a stream of values in a single pass: their count,
+// mean, variance, minimum and maximum. It updates the mean and the sum of
+// squared deviations from it with Welford's method, which stays accurate for
+// values far from zero, where a running sum of squares loses precision.
+type Accumulator struct {
+    n        int
+    mean     float64
+    m2       float64 // Sum of squared deviations from the mean
+    min, max float64
+}
+
+// Add adds v to the values summarized.
+func (a *Accumulator) Add(v float64) {
+    if a.n == 0 || v < a.min {
+        a.min = v
+    }
+    if a.n == 0 || v > a.max {
+        a.max = v
+    }
+    a.n++
+    delta := v - a.mean
+    a.mean += delta / float64(a.n)
+    a.m2 += delta * (v - a.mean)
+}
+
+// Count is the number of values added.
+func (a *Accumulator) Count() int {
+    return a.n
+}
+
+// Mean is the mean of the values, 0 if there are none.
+func (a *Accumulator) Mean() float64 {
+    return a.mean
+}
+
+// Variance is the population variance of the values, 0 if there are none.
+func (a *Accumulator) Variance() float64 {
+    if a.n == 0 {
+        return 0
+    }
+    return a.m2 / float64(a.n)
+}
+
+// StdDev is the population standard deviation of the values.
+func (a *Accumulator) StdDev() float64 {
+    return math.Sqrt(a.Variance())
+}
+
+// Min is the smallest value, 0 if there are none.
+func (a *Accumulator) Min() float64 {
+    return a.min
+}
+
+// Max is the largest value, 0 if there are none.
+func (a *Accumulator) Max() float64 {
+    return a.max
+}
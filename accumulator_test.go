@@ -0,0 +1,76 @@
+package main
+
+import (
+    "math"
+    "math/rand"
+    "testing"
+)
+
+// twoPassStdDev is the standard deviation the summaries used before the
+// Accumulator: the mean first, then the squared deviations from it.
+func twoPassStdDev(data []float64) float64 {
+    mean := 0.0
+    for _, v := range data {
+        mean += v
+    }
+    mean /= float64(len(data))
+    sum := 0.0
+    for _, v := range data {
+        sum += math.Pow(v-mean, 2)
+    }
+    return math.Sqrt(sum / float64(len(data)))
+}
+
+func TestAccumulatorMatchesTwoPass(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    tricks := make([]float64, 10000)
+    for i := range tricks {
+        tricks[i] = float64(rng.Intn(2000))
+    }
+    // Values around 1e9 with a spread of about 1: their squares need about
+    // 60 bits, so a naive sum of squares loses the variance entirely.
+    large := make([]float64, 10000)
+    for i := range large {
+        large[i] = 1e9 + rng.NormFloat64()
+    }
+
+    for _, tc := range []struct {
+        name string
+        data []float64
+    }{
+        {"tricks", tricks},
+        {"large", large},
+        {"single", []float64{7}},
+    } {
+        var acc Accumulator
+        lo, hi := math.Inf(1), math.Inf(-1)
+        for _, v := range tc.data {
+            acc.Add(v)
+            lo, hi = math.Min(lo, v), math.Max(hi, v)
+        }
+        want := twoPassStdDev(tc.data)
+        if math.Abs(acc.StdDev()-want) > 1e-6*math.Max(1, want) {
+            t.Errorf("%s: standard deviation %v, two-pass %v", tc.name, acc.StdDev(), want)
+        }
+        if acc.Count() != len(tc.data) || acc.Min() != lo || acc.Max() != hi {
+            t.Errorf("%s: count %d, min %v, max %v, want %d, %v, %v", tc.name, acc.Count(), acc.Min(), acc.Max(), len(tc.data), lo, hi)
+        }
+    }
+
+    // The naive sum of squares is what the large dataset guards against.
+    n, sum, sumSq := 0.0, 0.0, 0.0
+    for _, v := range large {
+        n, sum, sumSq = n+1, sum+v, sumSq+v*v
+    }
+    naive := math.Sqrt(math.Max(0, sumSq/n-(sum/n)*(sum/n)))
+    if want := twoPassStdDev(large); math.Abs(naive-want) < 1e-6*want {
+        t.Errorf("naive sum of squares gives %v, as close to the two-pass %v as the Accumulator", naive, want)
+    }
+}
+
+func TestEmptyAccumulator(t *testing.T) {
+    var acc Accumulator
+    if acc.Count() != 0 || acc.Mean() != 0 || acc.Variance() != 0 || acc.StdDev() != 0 || acc.Min() != 0 || acc.Max() != 0 {
+        t.Errorf("empty accumulator %+v", acc)
+    }
+}
@@ -0,0 +1,51 @@
+package main
+
+import "wargames/war"
+
+// aggregatorBuffer is how many games can wait on a StatsAggregator's channel
+// before the workers sending them block.
+const aggregatorBuffer = 256
+
+// StatsAggregator builds a run's summary from games sent to it over a
+// channel as they are played, so a run that needs only the summary never
+// holds its games or loops over them again. Games may arrive in any order
+// from any number of goroutines. The summary is the one a streamed run
+// gives: exact for runs of up to reservoirSize games, up to floating-point
+// rounding in the order of the sums, and with sampled percentiles beyond.
+type StatsAggregator struct {
+    games   chan war.GameStats
+    done    chan struct{}
+    summary *streamSummary
+}
+
+// NewStatsAggregator starts an aggregator whose percentile samples are drawn
+// with a source seeded with seed.
+func NewStatsAggregator(seed int64) *StatsAggregator {
+    a := &StatsAggregator{
+        games:   make(chan war.GameStats, aggregatorBuffer),
+        done:    make(chan struct{}),
+        summary: newStreamSummary(seed),
+    }
+    go a.run()
+    return a
+}
+
+// Games returns the channel to send games on.
+func (a *StatsAggregator) Games() chan<- war.GameStats {
+    return a.games
+}
+
+func (a *StatsAggregator) run() {
+    defer close(a.done)
+    for game := range a.games {
+        a.summary.add([]war.GameStats{game})
+    }
+}
+
+// Close closes the channel, which must not be sent on afterwards, waits for
+// the games already sent to be counted and returns the summary of them all.
+func (a *StatsAggregator) Close() Summary {
+    close(a.games)
+    <-a.done
+    return a.summary.summary()
+}
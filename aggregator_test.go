@@ -0,0 +1,43 @@
+package main
+
+import (
+    "math/rand"
+    "sync"
+    "testing"
+
+    "wargames/war"
+)
+
+// Games sent to the aggregator out of order from several goroutines give
+// the summary computed from all of them at once.
+func TestStatsAggregatorMatchesTheBatchSummary(t *testing.T) {
+    stats := war.RunSimulations(2000, 500, 15000, false, 3600000, 5, 4, war.DefaultOptions())
+    stats[3] = war.GameStats{GameNumber: 4, Tricks: -1, TerminationReason: "error", Errored: true}
+    stats[9] = war.GameStats{GameNumber: 10, Tricks: 5000, TerminationReason: "trick-limit"}
+
+    aggregator := NewStatsAggregator(5)
+    order := rand.New(rand.NewSource(1)).Perm(len(stats))
+    var wg sync.WaitGroup
+    for w := range 4 {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := w; i < len(order); i += 4 {
+                aggregator.Games() <- stats[order[i]]
+            }
+        }()
+    }
+    wg.Wait()
+    sameSummary(t, aggregator.Close(), newSummary(stats))
+
+    sameSummary(t, NewStatsAggregator(5).Close(), newSummary(nil))
+}
+
+// Streaming a range of games into the aggregator summarizes the games
+// RunGameRange returns for it.
+func TestStreamGameRangeIntoAnAggregator(t *testing.T) {
+    opts := war.DefaultOptions()
+    aggregator := NewStatsAggregator(3)
+    war.StreamGameRange(100, 500, 500, 15000, true, 3600000, 3, 3, opts, aggregator.Games())
+    sameSummary(t, aggregator.Close(), newSummary(war.RunGameRange(100, 500, 500, 15000, true, 3600000, 3, 2, opts)))
+}
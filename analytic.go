@@ -0,0 +1,80 @@
+package main
+
+import (
+    "fmt"
+    "io"
+
+    "wargames/war"
+)
+
+// The analytical estimate treats a game as a random walk in Player A's card
+// count, starting at half the deck and ending when either player is out. A
+// plain trick moves one card; a war of final depth d moves the loser's tied
+// card plus k = warDown+1 cards per round, 1+kd. For a symmetric walk with step
+// variance s², the expected time to leave (0, N) from N/2 is (N/2)² / s².
+// The model ignores reshuffles and wars cut short by a player running out, so
+// it is a sanity check on the simulation's order of magnitude rather than a
+// prediction.
+
+// estimateGameLength returns the expected number of tricks for a deck of
+// deckSize cards, given the fraction of tricks that start a war and the mean
+// number of rounds in a war, with warDown cards laid face down per round.
+func estimateGameLength(deckSize int, warRate, warDepth float64, warDown int) float64 {
+    warStep := 1 + float64(warDown+1)*warDepth
+    stepVariance := (1 - warRate) + warRate*warStep*warStep
+    half := float64(deckSize) / 2
+    return half * half / stepVariance
+}
+
+// theoreticalWarRate is the chance that two cards drawn from a full deck
+// share a rank.
+func theoreticalWarRate(deck []war.Card) float64 {
+    copies := make(map[int]int)
+    for _, card := range deck {
+        copies[card.Rank]++
+    }
+    pairs := 0
+    for _, c := range copies {
+        pairs += c * (c - 1)
+    }
+    n := len(deck)
+    return float64(pairs) / float64(n*(n-1))
+}
+
+// printAnalyticEstimate compares the simulated mean game length to the
+// random-walk estimate, using both the observed and the theoretical war rate.
+func printAnalyticEstimate(w io.Writer, stats []war.GameStats, deck []war.Card, warDown int) {
+    games, tricks, wars, warStarts := 0, 0, 0, 0
+    for _, game := range stats {
+        if game.Errored {
+            continue
+        }
+        games++
+        tricks += game.Tricks
+        wars += game.Wars
+        warStarts += game.Wars - game.DeepWars
+    }
+    if games == 0 || tricks == 0 {
+        fmt.Fprintln(w, "Analytical estimate: no games to compare against")
+        return
+    }
+
+    simulated := float64(tricks) / float64(games)
+    observedRate := float64(warStarts) / float64(tricks)
+    warDepth := 1.0
+    if warStarts > 0 {
+        warDepth = float64(wars) / float64(warStarts)
+    }
+    theoreticalRate := theoreticalWarRate(deck)
+    // A tied war goes another round at the same tie rate.
+    theoreticalDepth := 1 / (1 - theoreticalRate)
+
+    observed := estimateGameLength(len(deck), observedRate, warDepth, warDown)
+    theoretical := estimateGameLength(len(deck), theoreticalRate, theoreticalDepth, warDown)
+
+    fmt.Fprintf(w, "Analytical game length (observed war rate %.4f, depth %.2f): %.1f tricks (simulated/analytical %.2f)\n",
+        observedRate, warDepth, observed, simulated/observed)
+    fmt.Fprintf(w, "Analytical game length (theoretical war rate %.4f, depth %.2f): %.1f tricks (simulated/analytical %.2f)\n",
+        theoreticalRate, theoreticalDepth, theoretical, simulated/theoretical)
+    fmt.Fprintf(w, "Simulated mean game length: %.1f tricks\n", simulated)
+}
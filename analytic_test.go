@@ -0,0 +1,35 @@
+package main
+
+import (
+    "bytes"
+    "math"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestAnalyticEstimateIsWithinAnOrderOfMagnitude(t *testing.T) {
+    deck := war.CreateDeck(war.StandardDeck, false)
+    if rate := theoreticalWarRate(deck); math.Abs(rate-3.0/51) > 1e-12 {
+        t.Errorf("theoretical war rate %g, want 3/51", rate)
+    }
+
+    stats := war.RunSimulations(500, 500, 15000, false, 3600000, 1, 4, war.DefaultOptions())
+    tricks := 0
+    for _, game := range stats {
+        tricks += game.Tricks
+    }
+    simulated := float64(tricks) / float64(len(stats))
+    rate := theoreticalWarRate(deck)
+    estimate := estimateGameLength(len(deck), rate, 1/(1-rate), 3)
+    if ratio := simulated / estimate; ratio < 0.1 || ratio > 10 {
+        t.Errorf("simulated mean %.1f tricks is %.2f times the estimate %.1f, want within a factor of 10", simulated, ratio, estimate)
+    }
+
+    var buf bytes.Buffer
+    printAnalyticEstimate(&buf, stats, deck, 3)
+    if !strings.Contains(buf.String(), "simulated/analytical") || !strings.Contains(buf.String(), "Simulated mean game length") {
+        t.Errorf("estimate report:\n%s", buf.String())
+    }
+}
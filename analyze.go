@@ -0,0 +1,97 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "math"
+
+    "wargames/war"
+)
+
+// Report compares how often Player A, dealt the top half of the deck, and
+// Player B won, over the games one of them won.
+type Report struct {
+    WinsA, WinsB int
+    ShareA       float64    // Player A's share of the decided games
+    Interval     [2]float64 // 95% Wilson confidence interval for ShareA
+    ChiSquare    float64    // Chi-square statistic against an even split, one degree of freedom
+    PValue       float64
+}
+
+// WinRateReport tests whether either seat has an edge. Draws, unfinished and
+// errored games, and wins by the other seats of a multiplayer game are left
+// out.
+func WinRateReport(stats []war.GameStats) Report {
+    var r Report
+    for _, game := range stats {
+        switch game.Winner {
+        case 1:
+            r.WinsA++
+        case 2:
+            r.WinsB++
+        }
+    }
+    n := float64(r.WinsA + r.WinsB)
+    if n == 0 {
+        return r
+    }
+
+    const z = 1.959964 // Two-sided 95%
+    p := float64(r.WinsA) / n
+    center := (p + z*z/(2*n)) / (1 + z*z/n)
+    halfWidth := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+    r.ShareA = p
+    r.Interval = [2]float64{center - halfWidth, center + halfWidth}
+
+    // With two cells, each expected to hold n/2, the statistic simplifies
+    // to (A-B)²/n, and its tail with one degree of freedom is erfc(√(x/2)).
+    diff := float64(r.WinsA - r.WinsB)
+    r.ChiSquare = diff * diff / n
+    r.PValue = math.Erfc(math.Sqrt(r.ChiSquare / 2))
+    return r
+}
+
+// DealStrength is the Elo rating difference that would make a player win a
+// share winRate of their games: 0 for an even split, about +191 for 75% and
+// -191 for 25%. It is infinite for a winRate of 0 or 1.
+func DealStrength(winRate float64) float64 {
+    return 400 * math.Log10(winRate/(1-winRate))
+}
+
+// printDealStrength prints how much stronger Player A's hand of a fixed deal
+// is than Player B's, as the Elo difference implied by A's share of the
+// decided games. A share of 0 or 1 would rate infinite, so shares are kept
+// half a game from either end: a hand that won all n games rates as if it
+// had won n - 1/2 of them, and is reported as at least that strong.
+func printDealStrength(w io.Writer, r Report) {
+    n := r.WinsA + r.WinsB
+    if n == 0 {
+        fmt.Fprintln(w, "Deal strength: no games won by Player A or B")
+        return
+    }
+    rating := func(share float64) float64 {
+        edge := 0.5 / float64(n)
+        return DealStrength(math.Min(math.Max(share, edge), 1-edge))
+    }
+    bound := ""
+    switch r.WinsB {
+    case 0:
+        bound = "at least "
+    case n:
+        bound = "at most "
+    }
+    fmt.Fprintf(w, "Deal strength: Player A's hand rates %s%+.0f Elo against Player B's (95%% CI %+.0f to %+.0f)\n",
+        bound, rating(r.ShareA), rating(r.Interval[0]), rating(r.Interval[1]))
+}
+
+// printWinRateReport prints Player A's share of the decided games with its
+// confidence interval and the chi-square test against 50/50.
+func printWinRateReport(w io.Writer, r Report) {
+    if r.WinsA+r.WinsB == 0 {
+        fmt.Fprintln(w, "Win rates: no games won by Player A or B")
+        return
+    }
+    fmt.Fprintf(w, "Win rates: Player A %d, Player B %d; A's share %.2f%% (95%% CI %.2f%% to %.2f%%)\n",
+        r.WinsA, r.WinsB, r.ShareA*100, r.Interval[0]*100, r.Interval[1]*100)
+    fmt.Fprintf(w, "Chi-square against 50/50: %.3f (p = %.4f)\n", r.ChiSquare, r.PValue)
+}
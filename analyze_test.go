@@ -0,0 +1,92 @@
+package main
+
+import (
+    "bytes"
+    "math"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+// riggedStats returns games won winsA times by Player A and winsB times by
+// Player B, with a draw and an errored game that the report leaves out.
+func riggedStats(winsA, winsB int) []war.GameStats {
+    var stats []war.GameStats
+    for range winsA {
+        stats = append(stats, war.GameStats{Winner: 1, Finished: true})
+    }
+    for range winsB {
+        stats = append(stats, war.GameStats{Winner: 2, Finished: true})
+    }
+    return append(stats, war.GameStats{Finished: true, TerminationReason: "draw"}, war.GameStats{Errored: true})
+}
+
+func TestWinRateReport(t *testing.T) {
+    r := WinRateReport(riggedStats(60, 40))
+    if r.WinsA != 60 || r.WinsB != 40 || r.ShareA != 0.6 {
+        t.Fatalf("report %+v, want 60 wins to 40 and a share of 0.6", r)
+    }
+    if r.Interval[0] >= r.ShareA || r.Interval[1] <= r.ShareA {
+        t.Errorf("interval %v does not bracket the share %v", r.Interval, r.ShareA)
+    }
+    // The Wilson interval for 60 of 100 at 95%.
+    if math.Abs(r.Interval[0]-0.5020) > 0.0005 || math.Abs(r.Interval[1]-0.6906) > 0.0005 {
+        t.Errorf("interval %v, want about [0.5020, 0.6906]", r.Interval)
+    }
+    // (60-40)²/100 = 4, just past the 5% critical value of 3.84.
+    if r.ChiSquare != 4 || math.Abs(r.PValue-0.0455) > 0.0005 {
+        t.Errorf("chi-square %v with p %v, want 4 with p about 0.0455", r.ChiSquare, r.PValue)
+    }
+
+    even := WinRateReport(riggedStats(50, 50))
+    if even.ChiSquare != 0 || even.PValue != 1 || even.Interval[0] >= 0.5 || even.Interval[1] <= 0.5 {
+        t.Errorf("even split %+v, want chi-square 0, p 1 and an interval around 0.5", even)
+    }
+    lopsided := WinRateReport(riggedStats(10, 0))
+    if lopsided.ShareA != 1 || lopsided.Interval[1] > 1 || lopsided.Interval[0] <= 0.5 {
+        t.Errorf("10 wins to none %+v, want a share of 1 inside [0, 1]", lopsided)
+    }
+
+    var buf bytes.Buffer
+    printWinRateReport(&buf, WinRateReport(riggedStats(0, 0)))
+    if !strings.Contains(buf.String(), "no games won") {
+        t.Errorf("report of no decided games: %q", buf.String())
+    }
+}
+
+func TestDealStrength(t *testing.T) {
+    for _, tc := range []struct {
+        winRate, want float64
+    }{
+        {0.5, 0},
+        {0.75, 190.85},   // 400 log10(3)
+        {0.25, -190.85},  // The same deal from the other side
+        {10.0 / 11, 400}, // Ten to one is 400 points
+        {0.64, 99.95},
+    } {
+        if got := DealStrength(tc.winRate); math.Abs(got-tc.want) > 0.01 {
+            t.Errorf("DealStrength(%v) = %v, want %v", tc.winRate, got, tc.want)
+        }
+    }
+}
+
+// A hand that wins or loses every game rates half a game from the end, as a
+// bound, rather than infinitely strong or weak.
+func TestPrintDealStrength(t *testing.T) {
+    for _, tc := range []struct {
+        winsA, winsB int
+        want         string
+    }{
+        {75, 25, "rates +191 Elo"},
+        {10, 0, "rates at least +512 Elo"}, // 9.5 of 10 is 400 log10(19)
+        {0, 10, "rates at most -512 Elo"},
+        {0, 0, "no games won by Player A or B"},
+    } {
+        var buf bytes.Buffer
+        printDealStrength(&buf, WinRateReport(riggedStats(tc.winsA, tc.winsB)))
+        if !strings.Contains(buf.String(), tc.want) || strings.Contains(buf.String(), "Inf") {
+            t.Errorf("%d wins to %d: %q, want %q", tc.winsA, tc.winsB, buf.String(), tc.want)
+        }
+    }
+}
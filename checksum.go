@@ -0,0 +1,39 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "sort"
+
+    "wargames/war"
+)
+
+// runChecksum hashes every game, in game-number order, into a checksum that
+// identifies the run's results. Each game is serialized whole as JSON, which
+// writes the fields in declaration order and holds only integers, booleans,
+// strings and lists of them, so the value is the same on every platform for
+// the same configuration and seed, and a field added to GameStats is covered
+// without changing this. Only the panic trace of an errored game is left out,
+// since its stack holds addresses that differ from one run to the next.
+func runChecksum(stats []war.GameStats) string {
+    games := append([]war.GameStats(nil), stats...)
+    sort.Slice(games, func(i, j int) bool { return games[i].GameNumber < games[j].GameNumber })
+
+    hash := sha256.New()
+    var size [8]byte
+    for _, game := range games {
+        game.PanicTrace = ""
+        encoded, err := json.Marshal(game)
+        if err != nil {
+            panic(err) // GameStats holds nothing that cannot be encoded
+        }
+        // Each game is prefixed with its length so that no two runs'
+        // encodings run together the same way.
+        binary.BigEndian.PutUint64(size[:], uint64(len(encoded)))
+        hash.Write(size[:])
+        hash.Write(encoded)
+    }
+    return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestChecksumIsReproducible(t *testing.T) {
+    first := runChecksum(runGames(t, 20))
+    if again := runChecksum(runGames(t, 20)); again != first {
+        t.Errorf("the same run gave checksums %s and %s", first, again)
+    }
+
+    // Games may arrive in any order, as from a coordinator.
+    stats := runGames(t, 20)
+    stats[0], stats[19] = stats[19], stats[0]
+    if got := runChecksum(stats); got != first {
+        t.Errorf("reordered games gave checksum %s, want %s", got, first)
+    }
+}
+
+func TestChecksumCoversEveryField(t *testing.T) {
+    want := runChecksum(runGames(t, 5))
+    changes := map[string]func(*war.GameStats){
+        "Tricks":            func(g *war.GameStats) { g.Tricks++ },
+        "MaxWarDepth":       func(g *war.GameStats) { g.MaxWarDepth++ },
+        "WarShufflesA":      func(g *war.GameStats) { g.WarShufflesA++ },
+        "FinalCardsB":       func(g *war.GameStats) { g.FinalCardsB++ },
+        "WinnerMargin":      func(g *war.GameStats) { g.WinnerMargin++ },
+        "RankWins":          func(g *war.GameStats) { g.RankWins[7]++ },
+        "DepthCapped":       func(g *war.GameStats) { g.DepthCapped++ },
+        "EarlyDraw":         func(g *war.GameStats) { g.EarlyDraw = !g.EarlyDraw },
+        "JokerTricks":       func(g *war.GameStats) { g.JokerTricks++ },
+        "TerminationReason": func(g *war.GameStats) { g.TerminationReason = "cycle" },
+        "ShuffleTricksA":    func(g *war.GameStats) { g.ShuffleTricksA = append(g.ShuffleTricksA, 1) },
+    }
+    for field, change := range changes {
+        stats := runGames(t, 5)
+        change(&stats[2])
+        if runChecksum(stats) == want {
+            t.Errorf("changing %s of one game left the checksum unchanged", field)
+        }
+    }
+
+    stats := runGames(t, 5)
+    stats[2].PanicTrace = "goroutine 7 [running]: 0xc000012345"
+    if runChecksum(stats) != want {
+        t.Error("a panic trace changed the checksum")
+    }
+}
+
+func TestChecksumIsInTheMetadata(t *testing.T) {
+    stats := runGames(t, 5)
+    meta := RunMetadata{Seed: 1, Games: 5, Checksum: runChecksum(stats)}
+    var buf bytes.Buffer
+    if err := (CSVWriter{Metadata: meta}).WriteResults(&buf, stats); err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(buf.String(), "# checksum="+meta.Checksum+"\n") {
+        t.Errorf("metadata does not record the checksum:\n%s", buf.String())
+    }
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "wargames/war"
+)
+
+// Column is a single named field of the per-game results output.
+type Column struct {
+    Name  string
+    Value func(war.GameStats) string
+}
+
+// allColumns lists every available output column in output order.
+var allColumns = []Column{
+    {"Game Number", func(g war.GameStats) string { return strconv.Itoa(g.GameNumber) }},
+    {"Seed", func(g war.GameStats) string { return strconv.FormatInt(g.Seed, 10) }},
+    {"Tricks", func(g war.GameStats) string { return strconv.Itoa(g.Tricks) }},
+    {"Wars", func(g war.GameStats) string { return strconv.Itoa(g.Wars) }},
+    {"Deep Wars", func(g war.GameStats) string { return strconv.Itoa(g.DeepWars) }},
+    {"Total War Depth", func(g war.GameStats) string { return strconv.Itoa(g.TotalWarDepth) }},
+    {"Max War Depth", func(g war.GameStats) string { return strconv.Itoa(g.MaxWarDepth) }},
+    {"Shuffles A", func(g war.GameStats) string { return strconv.Itoa(g.ShufflesA) }},
+    {"Shuffles B", func(g war.GameStats) string { return strconv.Itoa(g.ShufflesB) }},
+    {"War Shuffles A", func(g war.GameStats) string { return strconv.Itoa(g.WarShufflesA) }},
+    {"War Shuffles B", func(g war.GameStats) string { return strconv.Itoa(g.WarShufflesB) }},
+    {"Game Duration (ms)", func(g war.GameStats) string { return strconv.FormatInt(g.GameDuration.Milliseconds(), 10) }},
+    {"Finished", func(g war.GameStats) string { return strconv.FormatBool(g.Finished) }},
+    {"Player A Tricks", func(g war.GameStats) string { return strconv.Itoa(g.PlayerATricks) }},
+    {"Player B Tricks", func(g war.GameStats) string { return strconv.Itoa(g.PlayerBTricks) }},
+    {"Winner", func(g war.GameStats) string { return strconv.Itoa(g.Winner) }},
+    {"Pips A", func(g war.GameStats) string { return strconv.Itoa(g.PipsA) }},
+    {"Pips B", func(g war.GameStats) string { return strconv.Itoa(g.PipsB) }},
+    {"Extra Card To", func(g war.GameStats) string { return strconv.Itoa(g.ExtraCardTo) }},
+    {"Deal Balance", func(g war.GameStats) string { return strconv.Itoa(g.DealBalance()) }},
+    {"Suit Tie Breaks", func(g war.GameStats) string { return strconv.Itoa(g.SuitTieBreaks) }},
+    {"Depth Capped", func(g war.GameStats) string { return strconv.Itoa(g.DepthCapped) }},
+    {"Early Draw", func(g war.GameStats) string { return strconv.FormatBool(g.EarlyDraw) }},
+    {"Joker Tricks", func(g war.GameStats) string { return strconv.Itoa(g.JokerTricks) }},
+    {"Final Cards A", func(g war.GameStats) string { return strconv.Itoa(g.FinalCardsA) }},
+    {"Final Cards B", func(g war.GameStats) string { return strconv.Itoa(g.FinalCardsB) }},
+    {"Winner Margin", func(g war.GameStats) string { return strconv.Itoa(g.WinnerMargin) }},
+    {"Player Tricks", func(g war.GameStats) string { return joinInts(g.PlayerTricks) }},
+    {"Rank Wins", func(g war.GameStats) string { return joinInts(g.RankWins[2:]) }},
+    {"Termination Reason", func(g war.GameStats) string { return g.TerminationReason }},
+    {"Lead Changes", func(g war.GameStats) string { return strconv.Itoa(g.LeadChanges) }},
+    {"Winner Was Behind", func(g war.GameStats) string { return strconv.FormatBool(g.WinnerWasBehind) }},
+    {"Shuffle Tricks A", func(g war.GameStats) string { return joinInts(g.ShuffleTricksA) }},
+    {"Shuffle Tricks B", func(g war.GameStats) string { return joinInts(g.ShuffleTricksB) }},
+}
+
+// columnPresets maps each -columns preset to its column names, in output order.
+var columnPresets = map[string][]string{
+    "minimal": {"Game Number", "Winner", "Tricks"},
+    "standard": {"Game Number", "Tricks", "Wars", "Deep Wars", "Shuffles A", "Shuffles B", "Game Duration (ms)", "Finished", "Player A Tricks", "Player B Tricks", "Winner"},
+    "full": {"Game Number", "Seed", "Tricks", "Wars", "Deep Wars", "Total War Depth", "Max War Depth", "Shuffles A", "Shuffles B", "War Shuffles A", "War Shuffles B", "Game Duration (ms)", "Finished", "Player A Tricks", "Player B Tricks", "Winner", "Pips A", "Pips B", "Extra Card To", "Deal Balance", "Suit Tie Breaks", "Depth Capped", "Early Draw", "Joker Tricks", "Final Cards A", "Final Cards B", "Winner Margin", "Player Tricks", "Rank Wins", "Termination Reason", "Lead Changes", "Winner Was Behind", "Shuffle Tricks A", "Shuffle Tricks B"},
+}
+
+func columnsForPreset(preset string) ([]Column, error) {
+    names, ok := columnPresets[preset]
+    if !ok {
+        return nil, fmt.Errorf("unknown column preset %q (want minimal, standard or full)", preset)
+    }
+    columns := make([]Column, 0, len(names))
+    for _, name := range names {
+        column, ok := findColumn(name)
+        if !ok {
+            return nil, fmt.Errorf("preset %q references unknown column %q", preset, name)
+        }
+        columns = append(columns, column)
+    }
+    return columns, nil
+}
+
+// joinInts formats a list of numbers as a single semicolon-separated field.
+func joinInts(values []int) string {
+    parts := make([]string, len(values))
+    for i, v := range values {
+        parts[i] = strconv.Itoa(v)
+    }
+    return strings.Join(parts, ";")
+}
+
+func findColumn(name string) (Column, bool) {
+    for _, column := range allColumns {
+        if column.Name == name {
+            return column, true
+        }
+    }
+    return Column{}, false
+}
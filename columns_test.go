@@ -0,0 +1,90 @@
+package main
+
+import (
+    "bytes"
+    "slices"
+    "strconv"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestColumnPresets(t *testing.T) {
+    tests := []struct {
+        preset string
+        want   []string
+    }{
+        {"minimal", []string{"Game Number", "Winner", "Tricks"}},
+        {"standard", []string{"Game Number", "Tricks", "Wars", "Deep Wars", "Shuffles A", "Shuffles B", "Game Duration (ms)", "Finished", "Player A Tricks", "Player B Tricks", "Winner"}},
+    }
+    for _, tt := range tests {
+        columns, err := columnsForPreset(tt.preset)
+        if err != nil {
+            t.Fatalf("columnsForPreset(%q): %v", tt.preset, err)
+        }
+        if got := headerOf(t, columns); !slices.Equal(got, tt.want) {
+            t.Errorf("preset %q writes columns %q, want %q", tt.preset, got, tt.want)
+        }
+    }
+}
+
+// The full preset has every column, in the order they are listed, so a new
+// column cannot be left out of it by mistake.
+func TestFullPresetHasEveryColumn(t *testing.T) {
+    columns, err := columnsForPreset("full")
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := make([]string, len(allColumns))
+    for i, column := range allColumns {
+        want[i] = column.Name
+    }
+    got := headerOf(t, columns)
+    if !slices.Equal(got, want) {
+        t.Errorf("full preset writes columns %q, want %q", got, want)
+    }
+    for _, name := range []string{"Seed", "Max War Depth", "Final Cards A", "Final Cards B"} {
+        if !slices.Contains(got, name) {
+            t.Errorf("full preset is missing %q", name)
+        }
+    }
+}
+
+func TestFullPresetSeedIsTheGameSeed(t *testing.T) {
+    columns, _ := columnsForPreset("full")
+    stats := war.RunGameRange(0, 2, 500, 15000, false, 3600000, 42, 1, war.DefaultOptions())
+    var buf bytes.Buffer
+    if err := (CSVWriter{Columns: columns, RowsOnly: true}).WriteResults(&buf, stats); err != nil {
+        t.Fatal(err)
+    }
+    rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    for i, row := range rows {
+        want := strconv.FormatInt(war.GameSeed(42, i+1), 10)
+        if seed := strings.Split(row, ",")[1]; seed != want {
+            t.Errorf("game %d has seed %s, want %s", i+1, seed, want)
+        }
+    }
+}
+
+func TestUnknownColumnPreset(t *testing.T) {
+    if _, err := columnsForPreset("everything"); err == nil {
+        t.Error("columnsForPreset accepted an unknown preset")
+    }
+}
+
+// headerOf returns the header row a CSVWriter writes for columns.
+func headerOf(t *testing.T, columns []Column) []string {
+    t.Helper()
+    var buf bytes.Buffer
+    if err := (CSVWriter{Columns: columns}).WriteResults(&buf, nil); err != nil {
+        t.Fatal(err)
+    }
+    for _, line := range strings.Split(buf.String(), "\n") {
+        if line != "" && !strings.HasPrefix(line, "#") {
+            return strings.Split(line, ",")
+        }
+    }
+    t.Fatal("no header row written")
+    return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// applyConfigFile sets the flags named in the JSON object in filename, such
+// as {"games": 10000, "hand": 250, "jokers": true}, to the values given,
+// except those set on the command line. So a setting comes from the command
+// line if it is there, from the file if not, and from its default otherwise.
+func applyConfigFile(filename string, flags *flag.FlagSet) error {
+    data, err := os.ReadFile(filename)
+    if err != nil {
+        return err
+    }
+    var settings map[string]json.RawMessage
+    if err := json.Unmarshal(data, &settings); err != nil {
+        return fmt.Errorf("reading config %s: %v", filename, err)
+    }
+
+    onCommandLine := make(map[string]bool)
+    flags.Visit(func(f *flag.Flag) { onCommandLine[f.Name] = true })
+
+    names := make([]string, 0, len(settings))
+    for name := range settings {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        if name == "config" || flags.Lookup(name) == nil {
+            return fmt.Errorf("unknown setting %q in config %s", name, filename)
+        }
+        value, err := configValue(settings[name])
+        if err != nil {
+            return fmt.Errorf("setting %q in config %s: %v", name, filename, err)
+        }
+        if onCommandLine[name] {
+            continue
+        }
+        if err := flags.Set(name, value); err != nil {
+            return fmt.Errorf("invalid value %q for %q in config %s: %v", value, name, filename, err)
+        }
+    }
+    return nil
+}
+
+// configValue returns a setting's JSON value as it would be written on the
+// command line: a string's contents, or a number or boolean as written.
+func configValue(raw json.RawMessage) (string, error) {
+    text := strings.TrimSpace(string(raw))
+    if strings.HasPrefix(text, "\"") {
+        var s string
+        err := json.Unmarshal(raw, &s)
+        return s, err
+    }
+    if text == "" || text == "null" || strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+        return "", fmt.Errorf("want a string, number or boolean, got %s", text)
+    }
+    return text, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+    "flag"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// parseArgsOf runs parseArgs on args with a fresh set of flags.
+func parseArgsOf(t *testing.T, args ...string) Config {
+    t.Helper()
+    savedFlags, savedArgs := flag.CommandLine, os.Args
+    defer func() { flag.CommandLine, os.Args = savedFlags, savedArgs }()
+    flag.CommandLine = flag.NewFlagSet("wargames", flag.ContinueOnError)
+    os.Args = append([]string{"wargames"}, args...)
+    return parseArgs()
+}
+
+// A setting comes from the command line if it is there, from the config
+// file if not, and from its default otherwise.
+func TestConfigFile(t *testing.T) {
+    filename := filepath.Join(t.TempDir(), "experiment.json")
+    settings := `{"games": 5000, "hand": 250, "jokers": true, "shuffle-model": "riffle", "seed": 7}`
+    if err := os.WriteFile(filename, []byte(settings), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    cfg := parseArgsOf(t, "-hand", "300", "-config", filename)
+    if cfg.Games != 5000 || !cfg.IncludeJokers || cfg.ShuffleModel != "riffle" || cfg.Seed != 7 {
+        t.Errorf("file settings not applied: %+v", cfg)
+    }
+    if cfg.HandTime != 300 {
+        t.Errorf("hand time %d, want the command line's 300 over the file's 250", cfg.HandTime)
+    }
+    if cfg.ShuffleTime != 15000 || cfg.MaxGameTime != 3600000 {
+        t.Errorf("shuffle time %d and max time %d, want the defaults", cfg.ShuffleTime, cfg.MaxGameTime)
+    }
+
+    want := parseArgsOf(t, "-games", "5000", "-hand", "300", "-jokers", "-shuffle-model", "riffle", "-seed", "7")
+    if !reflect.DeepEqual(cfg, want) {
+        t.Errorf("config file gives %+v, the same flags give %+v", cfg, want)
+    }
+}
+
+func TestInvalidConfigFile(t *testing.T) {
+    dir := t.TempDir()
+    for _, tc := range []struct {
+        settings, want string
+    }{
+        {`{"gmaes": 10}`, `unknown setting "gmaes"`},
+        {`{"games": "many"}`, `invalid value "many" for "games"`},
+        {`{"games": [10]}`, `setting "games"`},
+        {`{"games": 10`, "reading config"},
+    } {
+        filename := filepath.Join(dir, "config.json")
+        if err := os.WriteFile(filename, []byte(tc.settings), 0644); err != nil {
+            t.Fatal(err)
+        }
+        if _, stderr, err := runMain(t, dir, "-config", filename); err == nil || !strings.Contains(stderr, tc.want) {
+            t.Errorf("config %s: %v, %q, want an error with %q", tc.settings, err, stderr, tc.want)
+        }
+    }
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strings"
+    "unicode"
+
+    "wargames/war"
+)
+
+// loadDeal reads a fixed starting deal for -deal: Player A's hand on the
+// first line and Player B's on the second, top card first, as ranks separated
+// by spaces or commas. Blank lines and lines starting with # are skipped.
+// Ranks carry no suits, so each card gets the first suit of its rank that the
+// deal has not used yet; the deal may not hold more of a rank than the deck.
+func loadDeal(filename string, deck war.DeckSpec, includeJokers bool) ([][]war.Card, error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    remaining := make(map[int][]war.Card)
+    for _, card := range war.CreateDeck(deck, includeJokers) {
+        remaining[card.Rank] = append(remaining[card.Rank], card)
+    }
+
+    var hands [][]war.Card
+    scanner := bufio.NewScanner(file)
+    for lineNumber := 1; scanner.Scan(); lineNumber++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        if len(hands) == 2 {
+            return nil, fmt.Errorf("%s:%d: a deal has only two hands", filename, lineNumber)
+        }
+
+        var hand []war.Card
+        for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+            rank, err := war.ParseRank(field)
+            if err != nil {
+                return nil, fmt.Errorf("%s:%d: %v", filename, lineNumber, err)
+            }
+            if len(remaining[rank]) == 0 {
+                return nil, fmt.Errorf("%s:%d: more cards of rank %s than the deck holds", filename, lineNumber, war.RankName(rank))
+            }
+            hand = append(hand, remaining[rank][0])
+            remaining[rank] = remaining[rank][1:]
+        }
+        hands = append(hands, hand)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    if len(hands) != 2 {
+        return nil, fmt.Errorf("%s: want a line for each of the two hands, got %d", filename, len(hands))
+    }
+    return hands, nil
+}
+
+// parseDeckSpec reads the -deck-ranks range, two ranks such as 2-A, into a
+// deck spec with copies cards of each rank.
+func parseDeckSpec(ranks string, copies int) (war.DeckSpec, error) {
+    low, high, found := strings.Cut(ranks, "-")
+    if !found {
+        return war.DeckSpec{}, fmt.Errorf("invalid deck ranks %q (want the lowest and highest rank, such as 2-A)", ranks)
+    }
+    minRank, err := war.ParseRank(strings.TrimSpace(low))
+    if err != nil {
+        return war.DeckSpec{}, err
+    }
+    maxRank, err := war.ParseRank(strings.TrimSpace(high))
+    if err != nil {
+        return war.DeckSpec{}, err
+    }
+    return war.DeckSpec{MinRank: minRank, MaxRank: maxRank, Copies: copies}, nil
+}
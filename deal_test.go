@@ -0,0 +1,74 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+// writeDeal writes a deal file and returns its name.
+func writeDeal(t *testing.T, contents string) string {
+    t.Helper()
+    filename := filepath.Join(t.TempDir(), "deal.txt")
+    if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+        t.Fatal(err)
+    }
+    return filename
+}
+
+func TestLoadDeal(t *testing.T) {
+    // Player A holds all the aces.
+    filename := writeDeal(t, "# Aces against the rest\nA, a A a\n\n2 K 10 Q 2 Joker\n")
+    deal, err := loadDeal(filename, war.StandardDeck, true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    card := func(rank int, suit war.Suit) war.Card { return war.Card{Rank: rank, Suit: suit} }
+    wantA := []war.Card{card(14, war.Clubs), card(14, war.Diamonds), card(14, war.Hearts), card(14, war.Spades)}
+    wantB := []war.Card{card(2, war.Clubs), card(13, war.Clubs), card(10, war.Clubs), card(12, war.Clubs), card(2, war.Diamonds), card(15, war.Clubs)}
+    if !slices.Equal(deal[0], wantA) || !slices.Equal(deal[1], wantB) {
+        t.Errorf("loaded hands %v and %v, want %v and %v", deal[0], deal[1], wantA, wantB)
+    }
+
+    // The game starts with those piles, top card first.
+    opts := war.DefaultOptions()
+    opts.Deal, opts.RecordDeal = deal, true
+    stats := war.PlayGame(nil, 500, 15000, true, 3600000, opts, war.NewGameRand(1, 1, 0))
+    if !slices.Equal(stats.InitialDealA, wantA) || !slices.Equal(stats.InitialDealB, wantB) {
+        t.Errorf("game was dealt %v and %v", stats.InitialDealA, stats.InitialDealB)
+    }
+}
+
+func TestLoadDealRejectsIllegalDeals(t *testing.T) {
+    for _, tt := range []struct {
+        contents string
+        err      string
+    }{
+        {"A A A A A\n2\n", "more cards of rank A than the deck holds"},
+        {"Joker\n2\n", "more cards of rank Joker"}, // No jokers in the deck
+        {"2 3\n", "want a line for each of the two hands, got 1"},
+        {"2\n3\n4\n", "a deal has only two hands"},
+        {"2 1\n3\n", "invalid rank"},
+    } {
+        _, err := loadDeal(writeDeal(t, tt.contents), war.StandardDeck, false)
+        if err == nil || !strings.Contains(err.Error(), tt.err) {
+            t.Errorf("deal %q gave %v, want an error about %q", tt.contents, err, tt.err)
+        }
+    }
+}
+
+func TestParseDeckSpec(t *testing.T) {
+    spec, err := parseDeckSpec("9 - a", 10)
+    if want := (war.DeckSpec{MinRank: 9, MaxRank: 14, Copies: 10}); err != nil || spec != want {
+        t.Errorf("parseDeckSpec = %+v, %v; want %+v", spec, err, want)
+    }
+    for _, ranks := range []string{"2", "2-X", "0-A"} {
+        if _, err := parseDeckSpec(ranks, 4); err == nil {
+            t.Errorf("parseDeckSpec(%q) accepted it", ranks)
+        }
+    }
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+    "encoding/json"
+    "net"
+    "sync"
+    "time"
+
+    "wargames/war"
+)
+
+// A coordinator (-coordinator :7000) splits a run into fixed ranges of games
+// and hands them to workers (-worker host:7000) over TCP. Each connection
+// carries a stream of JSON messages: the coordinator sends an assignment, the
+// worker replies with the stats for that range, and so on until the
+// coordinator sends an assignment with Done set.
+//
+// Every game seeds its own RNG from the run seed and its game number, so
+// results do not depend on how many workers there are or which worker plays
+// which range.
+
+const distributedChunkSize = 1000
+
+type workAssignment struct {
+    Done          bool
+    Start         int // Index of the first game in the range
+    Count         int
+    Seed          int64
+    HandTime      int
+    ShuffleTime   int
+    IncludeJokers bool
+    MaxGameTime   int
+    Opts          Options
+}
+
+type workResult struct {
+    Start int
+    Stats []war.GameStats
+}
+
+// runCoordinator serves game ranges to workers connecting on addr until all
+// of the run's games have been played, then returns the combined results.
+// A range whose worker disconnects is handed to the next worker that asks.
+func runCoordinator(addr string, cfg Config) ([]war.GameStats, error) {
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return nil, err
+    }
+    defer listener.Close()
+    logger.Infof("Coordinator listening on %s", listener.Addr())
+
+    pending := make(chan int, cfg.Games/distributedChunkSize+1)
+    for start := 0; start < cfg.Games; start += distributedChunkSize {
+        pending <- start
+    }
+
+    stats := make([]war.GameStats, cfg.Games)
+    var mu sync.Mutex
+    var workers sync.WaitGroup
+    remaining := len(pending)
+    done := make(chan struct{})
+
+    serve := func(conn net.Conn) {
+        defer conn.Close()
+        encoder, decoder := json.NewEncoder(conn), json.NewDecoder(conn)
+        for {
+            var start int
+            select {
+            case start = <-pending:
+            case <-done:
+                encoder.Encode(workAssignment{Done: true})
+                return
+            }
+
+            assignment := workAssignment{
+                Start:         start,
+                Count:         min(distributedChunkSize, cfg.Games-start),
+                Seed:          cfg.Seed,
+                HandTime:      cfg.HandTime,
+                ShuffleTime:   cfg.ShuffleTime,
+                IncludeJokers: cfg.IncludeJokers,
+                MaxGameTime:   cfg.MaxGameTime,
+                Opts:          cfg.Options,
+            }
+            var result workResult
+            if err := encoder.Encode(assignment); err == nil {
+                err = decoder.Decode(&result)
+            }
+            if err != nil || result.Start != start || len(result.Stats) != assignment.Count {
+                logger.Warnf("worker %s failed on games %d-%d, reassigning", conn.RemoteAddr(), start+1, start+assignment.Count)
+                pending <- start
+                return
+            }
+
+            mu.Lock()
+            copy(stats[start:], result.Stats)
+            if cfg.Progress != nil {
+                cfg.Progress.Add(int64(assignment.Count))
+            }
+            remaining--
+            if remaining == 0 {
+                close(done)
+            }
+            mu.Unlock()
+        }
+    }
+
+    accepting := make(chan struct{})
+    go func() {
+        defer close(accepting)
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return
+            }
+            workers.Add(1)
+            go func() {
+                defer workers.Done()
+                serve(conn)
+            }()
+        }
+    }()
+
+    // Let every connected worker receive its Done message before returning.
+    <-done
+    listener.Close()
+    <-accepting
+    workers.Wait()
+    return stats, nil
+}
+
+// runWorker connects to a coordinator and plays the ranges it assigns, on
+// workers goroutines, until told the run is complete.
+func runWorker(addr string, workers int) error {
+    conn, err := net.Dial("tcp", addr)
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+    encoder, decoder := json.NewEncoder(conn), json.NewDecoder(conn)
+
+    for {
+        var assignment workAssignment
+        if err := decoder.Decode(&assignment); err != nil {
+            return err
+        }
+        if assignment.Done {
+            return nil
+        }
+
+        startTime := time.Now()
+        stats := war.RunGameRange(assignment.Start, assignment.Count, assignment.HandTime, assignment.ShuffleTime, assignment.IncludeJokers, assignment.MaxGameTime, assignment.Seed, workers, assignment.Opts.Options)
+        logger.Infof("Played games %d-%d in %v", assignment.Start+1, assignment.Start+assignment.Count, time.Since(startTime))
+
+        if err := encoder.Encode(workResult{Start: assignment.Start, Stats: stats}); err != nil {
+            return err
+        }
+    }
+}
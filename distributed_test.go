@@ -0,0 +1,67 @@
+package main
+
+import (
+    "io"
+    "net"
+    "reflect"
+    "testing"
+    "time"
+
+    "wargames/war"
+)
+
+func TestCoordinatorWithTwoWorkers(t *testing.T) {
+    defer quietLogger()()
+    cfg := testConfig()
+    cfg.Games = 2*distributedChunkSize + 500 // Three ranges, so a worker plays more than one
+    cfg.Seed = 77
+
+    // Take a free port for the coordinator to listen on.
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    addr := listener.Addr().String()
+    listener.Close()
+
+    workerErrs := make(chan error, 2)
+    for i := 0; i < 2; i++ {
+        go func() {
+            // Retry until the coordinator is listening.
+            for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+                err := runWorker(addr, 2)
+                if _, refused := err.(*net.OpError); !refused || time.Since(start) > 5*time.Second {
+                    workerErrs <- err
+                    return
+                }
+            }
+        }()
+    }
+    stats, err := runCoordinator(addr, cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 2; i++ {
+        if err := <-workerErrs; err != nil {
+            t.Errorf("worker: %v", err)
+        }
+    }
+
+    want := war.RunSimulations(cfg.Games, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, cfg.Seed, 4, cfg.Options.Options)
+    if !reflect.DeepEqual(stats, want) {
+        for i := range want {
+            if !reflect.DeepEqual(stats[i], want[i]) {
+                t.Fatalf("game %d from the workers is %+v, want %+v", i+1, stats[i], want[i])
+            }
+        }
+        t.Fatal("the workers' results differ from a single-process run")
+    }
+}
+
+// quietLogger sends the logger's messages nowhere until the function it
+// returns restores it.
+func quietLogger() func() {
+    saved := *logger
+    logger.Out, logger.Err = io.Discard, io.Discard
+    return func() { *logger = saved }
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "time"
+    "unsafe"
+
+    "wargames/war"
+)
+
+// calibrationGames is how many games -dry-run plays to estimate a run.
+const calibrationGames = 200
+
+// countingWriter counts the bytes written to it and discards them.
+type countingWriter struct {
+    n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    c.n += int64(len(p))
+    return len(p), nil
+}
+
+// printDryRun describes the run cfg would play without playing it: its
+// parameters and deck, and, scaled up from a short calibration run, the
+// memory its games' stats take, the size of its results and how long it
+// would take.
+func printDryRun(w io.Writer, cfg Config, columns []Column) {
+    fmt.Fprintln(w, "Dry run: nothing is played or written")
+    for _, line := range newRunMetadata(cfg).lines() {
+        fmt.Fprintf(w, "  %s\n", line)
+    }
+    if cfg.Seed == 0 {
+        fmt.Fprintln(w, "  (seed 0: the run chooses a time-based seed)")
+    }
+    deck := war.CreateDeck(cfg.Deck, cfg.IncludeJokers)
+    fmt.Fprintf(w, "Deck: %d cards, %s to %s, %d of each rank", len(deck), war.RankName(cfg.Deck.MinRank), war.RankName(cfg.Deck.MaxRank), cfg.Deck.Copies)
+    if cfg.IncludeJokers {
+        fmt.Fprint(w, ", with jokers")
+    }
+    fmt.Fprintln(w)
+    if cfg.Deal != nil {
+        fmt.Fprintf(w, "Deal: every game starts from the %d and %d cards in %s\n", len(cfg.Deal[0]), len(cfg.Deal[1]), cfg.DealFile)
+    }
+
+    games := min(calibrationGames, cfg.Games)
+    start := time.Now()
+    stats := war.RunSimulations(games, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, cfg.Seed, cfg.Workers, cfg.Options.Options)
+    elapsed := time.Since(start)
+    scale := float64(cfg.Games) / float64(games)
+
+    bytes := 0
+    for _, game := range stats {
+        bytes += statsSize(game)
+    }
+    perGame := float64(bytes) / float64(games)
+    fmt.Fprintf(w, "Memory for the games' stats: about %.0f bytes per game, %s for %d games\n", perGame, formatBytes(perGame*float64(cfg.Games)), cfg.Games)
+    if cfg.Stream {
+        fmt.Fprintf(w, "  (-stream keeps at most %d games at a time)\n", streamChunkGames)
+    }
+
+    var size countingWriter
+    if err := WriteResults(&size, stats, resultsFormat(cfg.Options, columns, newRunMetadata(cfg))); err == nil {
+        format := cfg.Format
+        if cfg.Compress {
+            format = "gzipped " + format
+        }
+        fmt.Fprintf(w, "Results: about %s as %s\n", formatBytes(float64(size.n)*scale), format)
+    }
+    projected := time.Duration(float64(elapsed) * scale)
+    fmt.Fprintf(w, "Projected time: about %v with -workers %d (from %d games in %v)\n", projected.Round(time.Second), cfg.Workers, games, elapsed.Round(time.Millisecond))
+}
+
+// statsSize is the memory held by a game's stats, the lists they point to
+// included.
+func statsSize(game war.GameStats) int {
+    cards := len(game.InitialDealA) + len(game.InitialDealB)
+    ints := len(game.ShuffleTricksA) + len(game.ShuffleTricksB) + len(game.PlayerTricks)
+    return int(unsafe.Sizeof(game)) + cards*int(unsafe.Sizeof(war.Card{})) + ints*int(unsafe.Sizeof(0)) + len(game.PanicTrace)
+}
+
+// formatBytes writes a byte count with a binary unit, such as 1.5 MiB.
+func formatBytes(n float64) string {
+    units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+    i := 0
+    for n >= 1024 && i < len(units)-1 {
+        n /= 1024
+        i++
+    }
+    return fmt.Sprintf("%.1f %s", n, units[i])
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "strconv"
+
+    "wargames/war"
+)
+
+// Deal features are derived only from the opening hands, so they can be used
+// to predict a game's outcome. Every row has the same width whatever the deck:
+// one count per rank from 2 to 15 (jokers) for Player A, then the high-card
+// differential and each player's longest run of equal ranks, then the labels.
+const (
+    featureMinRank  = 2
+    featureMaxRank  = 15
+    featureHighRank = 11 // Jack and above count as high cards
+)
+
+func featureHeader() []string {
+    header := []string{"Game Number"}
+    for rank := featureMinRank; rank <= featureMaxRank; rank++ {
+        header = append(header, fmt.Sprintf("A Rank %d", rank))
+    }
+    return append(header, "High Card Differential", "Longest Run A", "Longest Run B", "Tricks", "Winner")
+}
+
+// dealFeatures returns the fixed-width feature vector for a pair of hands.
+func dealFeatures(handA, handB []war.Card) []int {
+    features := make([]int, featureMaxRank-featureMinRank+1, featureMaxRank-featureMinRank+4)
+    for _, card := range handA {
+        if card.Rank >= featureMinRank && card.Rank <= featureMaxRank {
+            features[card.Rank-featureMinRank]++
+        }
+    }
+    return append(features, highCards(handA)-highCards(handB), longestRankRun(handA), longestRankRun(handB))
+}
+
+func highCards(hand []war.Card) int {
+    count := 0
+    for _, card := range hand {
+        if card.Rank >= featureHighRank {
+            count++
+        }
+    }
+    return count
+}
+
+// longestRankRun is the length of the longest stretch of consecutive cards of
+// the same rank in hand.
+func longestRankRun(hand []war.Card) int {
+    longest, run := 0, 0
+    for i, card := range hand {
+        if i > 0 && card.Rank == hand[i-1].Rank {
+            run++
+        } else {
+            run = 1
+        }
+        longest = max(longest, run)
+    }
+    return longest
+}
+
+// writeFeatures writes one feature row per game to filename, creating any
+// directories leading to it. Games need their initial deal recorded; errored
+// games are skipped.
+func writeFeatures(filename string, stats []war.GameStats) error {
+    file, err := createOutputFile(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    if err := writer.Write(featureHeader()); err != nil {
+        return err
+    }
+    for _, game := range stats {
+        if game.Errored {
+            continue
+        }
+        row := []string{strconv.Itoa(game.GameNumber)}
+        for _, feature := range dealFeatures(game.InitialDealA, game.InitialDealB) {
+            row = append(row, strconv.Itoa(feature))
+        }
+        row = append(row, strconv.Itoa(game.Tricks), strconv.Itoa(game.Winner))
+        if err := writer.Write(row); err != nil {
+            return err
+        }
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return err
+    }
+    return file.Close()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+    "encoding/csv"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestDealFeatures(t *testing.T) {
+    handA := []war.Card{{Rank: 14}, {Rank: 14}, {Rank: 3}, {Rank: 11}, {Rank: 15}, {Rank: 7}, {Rank: 7}, {Rank: 7}}
+    handB := []war.Card{{Rank: 2}, {Rank: 13}, {Rank: 13}, {Rank: 4}}
+    features := dealFeatures(handA, handB)
+    if width := len(featureHeader()) - 3; len(features) != width {
+        t.Fatalf("%d features, want %d", len(features), width)
+    }
+
+    counts := features[:featureMaxRank-featureMinRank+1]
+    sum := 0
+    for _, n := range counts {
+        sum += n
+    }
+    if sum != len(handA) {
+        t.Errorf("rank counts sum to %d, want A's %d cards", sum, len(handA))
+    }
+    for rank, want := range map[int]int{3: 1, 7: 3, 11: 1, 14: 2, 15: 1, 2: 0} {
+        if got := counts[rank-featureMinRank]; got != want {
+            t.Errorf("A holds %d of rank %d, want %d", got, rank, want)
+        }
+    }
+    // A holds J, A, A and the joker high; B the two kings.
+    if rest := features[len(counts):]; !slices.Equal(rest, []int{4 - 2, 3, 2}) {
+        t.Errorf("high-card differential and longest runs %v, want [2 3 2]", rest)
+    }
+}
+
+func TestWriteFeatures(t *testing.T) {
+    opts := war.DefaultOptions()
+    opts.RecordDeal = true
+    stats := war.RunSimulations(20, 500, 15000, true, 3600000, 1, 2, opts)
+    filename := filepath.Join(t.TempDir(), "new", "dir", "features.csv")
+    if err := writeFeatures(filename, stats); err != nil {
+        t.Fatal(err)
+    }
+    file, err := os.Open(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    rows, err := csv.NewReader(file).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(rows) != 21 || !slices.Equal(rows[0], featureHeader()) {
+        t.Fatalf("wrote %d rows with header %v, want 21 with %v", len(rows), rows[0], featureHeader())
+    }
+    for _, row := range rows[1:] {
+        if len(row) != len(rows[0]) {
+            t.Errorf("row %v is %d wide, want %d", row, len(row), len(rows[0]))
+        }
+    }
+
+    cfg := testConfig()
+    cfg.OutputDir = "out"
+    if got := outputPath(cfg, "features.csv"); got != filepath.Join("out", "features.csv") {
+        t.Errorf("features go to %s, want out/features.csv", got)
+    }
+    if got := outputPath(cfg, "/tmp/features.csv"); got != "/tmp/features.csv" {
+        t.Errorf("an absolute path became %s", got)
+    }
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "sort"
+)
+
+// DistributionFit is a candidate distribution fitted to observed data by the
+// method of moments, with its Kolmogorov-Smirnov distance from the data.
+type DistributionFit struct {
+    Name       string
+    ParamNames [2]string
+    Params     [2]float64
+    KS         float64
+    cdf        func(x float64) float64
+}
+
+// fitDistributions fits log-normal and gamma distributions to the positive
+// values in data. Both are matched to the sample mean and variance.
+func fitDistributions(data []float64) []DistributionFit {
+    values := make([]float64, 0, len(data))
+    for _, v := range data {
+        if v > 0 {
+            values = append(values, v)
+        }
+    }
+    if len(values) < 2 {
+        return nil
+    }
+    sort.Float64s(values)
+
+    var acc Accumulator
+    for _, v := range values {
+        acc.Add(v)
+    }
+    mean, variance := acc.Mean(), acc.Variance()
+    if variance == 0 {
+        return nil
+    }
+
+    sigma2 := math.Log(1 + variance/(mean*mean))
+    mu := math.Log(mean) - sigma2/2
+    sigma := math.Sqrt(sigma2)
+    logNormal := DistributionFit{
+        Name:       "Log-normal",
+        ParamNames: [2]string{"mu", "sigma"},
+        Params:     [2]float64{mu, sigma},
+        cdf: func(x float64) float64 {
+            return 0.5 * math.Erfc(-(math.Log(x)-mu)/(sigma*math.Sqrt2))
+        },
+    }
+
+    shape := mean * mean / variance
+    scale := variance / mean
+    gamma := DistributionFit{
+        Name:       "Gamma",
+        ParamNames: [2]string{"shape", "scale"},
+        Params:     [2]float64{shape, scale},
+        cdf: func(x float64) float64 {
+            return regularizedGammaP(shape, x/scale)
+        },
+    }
+
+    fits := []DistributionFit{logNormal, gamma}
+    for i := range fits {
+        fits[i].KS = ksDistance(values, fits[i].cdf)
+    }
+    return fits
+}
+
+// ksDistance is the largest gap between the empirical CDF of sorted and cdf.
+func ksDistance(sorted []float64, cdf func(float64) float64) float64 {
+    n := float64(len(sorted))
+    maxGap := 0.0
+    for i, v := range sorted {
+        f := cdf(v)
+        maxGap = math.Max(maxGap, math.Max(math.Abs(float64(i+1)/n-f), math.Abs(f-float64(i)/n)))
+    }
+    return maxGap
+}
+
+// regularizedGammaP is the regularized lower incomplete gamma function P(a, x),
+// evaluated by series for small x and by continued fraction otherwise.
+func regularizedGammaP(a, x float64) float64 {
+    if x <= 0 {
+        return 0
+    }
+    lgammaA, _ := math.Lgamma(a)
+    prefix := math.Exp(a*math.Log(x) - x - lgammaA)
+
+    if x < a+1 {
+        sum, term := 1/a, 1/a
+        for n := 1; n < 1000; n++ {
+            term *= x / (a + float64(n))
+            sum += term
+            if math.Abs(term) < math.Abs(sum)*1e-14 {
+                break
+            }
+        }
+        return sum * prefix
+    }
+
+    // Lentz's method for the continued fraction of Q(a, x).
+    const tiny = 1e-300
+    b := x + 1 - a
+    c := 1 / tiny
+    d := 1 / b
+    h := d
+    for n := 1; n < 1000; n++ {
+        an := -float64(n) * (float64(n) - a)
+        b += 2
+        d = an*d + b
+        if math.Abs(d) < tiny {
+            d = tiny
+        }
+        c = b + an/c
+        if math.Abs(c) < tiny {
+            c = tiny
+        }
+        d = 1 / d
+        delta := d * c
+        h *= delta
+        if math.Abs(delta-1) < 1e-14 {
+            break
+        }
+    }
+    return 1 - prefix*h
+}
+
+func printDistributionFits(w io.Writer, name string, data []float64) {
+    fits := fitDistributions(data)
+    if len(fits) == 0 {
+        fmt.Fprintf(w, "Fit of %s: not enough varied positive data\n", name)
+        return
+    }
+    fmt.Fprintf(w, "Fit of %s:\n", name)
+    for _, fit := range fits {
+        fmt.Fprintf(w, "  %s(%s=%.4f, %s=%.4f): KS distance %.4f\n",
+            fit.Name, fit.ParamNames[0], fit.Params[0], fit.ParamNames[1], fit.Params[1], fit.KS)
+    }
+}
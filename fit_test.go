@@ -0,0 +1,40 @@
+package main
+
+import (
+    "math"
+    "math/rand"
+    "testing"
+)
+
+func TestFitRecoversLogNormal(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    data := make([]float64, 20000)
+    for i := range data {
+        data[i] = math.Exp(5.5 + 0.6*rng.NormFloat64())
+    }
+    fits := fitDistributions(data)
+    if len(fits) != 2 || fits[0].Name != "Log-normal" {
+        t.Fatalf("fits %+v, want log-normal then gamma", fits)
+    }
+    logNormal, gamma := fits[0], fits[1]
+    if mu := logNormal.Params[0]; math.Abs(mu-5.5) > 0.05 {
+        t.Errorf("mu %.3f, want about 5.5", mu)
+    }
+    if sigma := logNormal.Params[1]; math.Abs(sigma-0.6) > 0.05 {
+        t.Errorf("sigma %.3f, want about 0.6", sigma)
+    }
+    if logNormal.KS > 0.02 {
+        t.Errorf("log-normal KS distance %.3f from log-normal data, want under 0.02", logNormal.KS)
+    }
+    if gamma.KS <= logNormal.KS {
+        t.Errorf("gamma fits log-normal data better (KS %.3f) than the log-normal (KS %.3f)", gamma.KS, logNormal.KS)
+    }
+}
+
+func TestFitNeedsVariedData(t *testing.T) {
+    for _, data := range [][]float64{nil, {100}, {100, 100, 100}, {0, -1}} {
+        if fits := fitDistributions(data); fits != nil {
+            t.Errorf("fitDistributions(%v) = %+v, want nil", data, fits)
+        }
+    }
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "math"
+    "strconv"
+    "strings"
+
+    "wargames/war"
+)
+
+// histogramBarWidth is the length of the longest bar in a printed histogram.
+const histogramBarWidth = 50
+
+// HistogramMetric is a per-game value that -histogram can bucket.
+type HistogramMetric struct {
+    Name    string
+    Integer bool // Values are whole numbers, so bins get whole-number edges
+    Value   func(war.GameStats) float64
+}
+
+// histogramMetrics lists every metric -histogram accepts, by flag name.
+var histogramMetrics = []HistogramMetric{
+    {"tricks", true, func(g war.GameStats) float64 { return float64(g.Tricks) }},
+    {"wars", true, func(g war.GameStats) float64 { return float64(g.Wars) }},
+    {"deep-wars", true, func(g war.GameStats) float64 { return float64(g.DeepWars) }},
+    {"shuffles-a", true, func(g war.GameStats) float64 { return float64(g.ShufflesA) }},
+    {"shuffles-b", true, func(g war.GameStats) float64 { return float64(g.ShufflesB) }},
+    {"deal-balance", true, func(g war.GameStats) float64 { return float64(g.DealBalance()) }},
+    {"minutes", false, func(g war.GameStats) float64 { return g.GameDuration.Minutes() }},
+}
+
+// parseHistogramMetrics looks up each metric of a comma-separated -histogram
+// list.
+func parseHistogramMetrics(list string) ([]HistogramMetric, error) {
+    var metrics []HistogramMetric
+    for _, name := range strings.Split(list, ",") {
+        name = strings.TrimSpace(name)
+        found := false
+        for _, metric := range histogramMetrics {
+            if metric.Name == name {
+                metrics = append(metrics, metric)
+                found = true
+                break
+            }
+        }
+        if !found {
+            names := make([]string, len(histogramMetrics))
+            for i, metric := range histogramMetrics {
+                names[i] = metric.Name
+            }
+            return nil, fmt.Errorf("unknown histogram metric %q (want %s)", name, strings.Join(names, ", "))
+        }
+    }
+    return metrics, nil
+}
+
+// Histogram counts how many values fall in each of a run of equal-width bins.
+// Bin i holds the values from Edges[i] up to but not including Edges[i+1],
+// except that the last bin also holds its upper edge.
+type Histogram struct {
+    Metric string
+    Edges  []float64
+    Counts []int
+}
+
+// newHistogram buckets data into bins bins spanning its range. Integer data
+// gets a whole-number bin width wide enough to cover the range, so that no bin
+// straddles part of a value; it may then need fewer than bins bins.
+func newHistogram(metric string, data []float64, bins int, integer bool) Histogram {
+    h := Histogram{Metric: metric}
+    if len(data) == 0 {
+        return h
+    }
+    lo, hi := minMax(data)
+    width := (hi - lo) / float64(bins)
+    if integer {
+        width = math.Ceil((hi - lo + 1) / float64(bins))
+        bins = int(math.Ceil((hi - lo + 1) / width))
+    } else if width == 0 {
+        width, bins = 1, 1
+    }
+
+    h.Edges = make([]float64, bins+1)
+    for i := range h.Edges {
+        h.Edges[i] = lo + float64(i)*width
+    }
+    h.Counts = make([]int, bins)
+    for _, v := range data {
+        bin := min(int((v-lo)/width), bins-1)
+        h.Counts[bin]++
+    }
+    return h
+}
+
+// printHistogram draws h as rows of bars scaled to the fullest bin.
+func printHistogram(w io.Writer, h Histogram, integer bool) {
+    fmt.Fprintf(w, "\nHistogram of %s:\n", h.Metric)
+    if len(h.Counts) == 0 {
+        fmt.Fprintln(w, "  no completed games")
+        return
+    }
+    most := 0
+    for _, count := range h.Counts {
+        most = max(most, count)
+    }
+    precision := 2
+    if integer {
+        precision = 0
+    }
+    for i, count := range h.Counts {
+        // Integer bins are labelled with the whole numbers they hold.
+        upper, closing := h.Edges[i+1], ")"
+        if integer {
+            upper, closing = h.Edges[i+1]-1, "]"
+        } else if i == len(h.Counts)-1 {
+            closing = "]"
+        }
+        bar := strings.Repeat("#", int(math.Round(float64(count)*histogramBarWidth/float64(most))))
+        fmt.Fprintf(w, "  [%8.*f, %8.*f%s %8d %s\n", precision, h.Edges[i], precision, upper, closing, count, bar)
+    }
+}
+
+// writeHistograms writes the bins of every histogram to a CSV file, one row
+// per bin.
+func writeHistograms(filename string, histograms []Histogram) error {
+    file, err := createOutputFile(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    if err := writer.Write([]string{"Metric", "Bin Start", "Bin End", "Count"}); err != nil {
+        return err
+    }
+    for _, h := range histograms {
+        for i, count := range h.Counts {
+            row := []string{
+                h.Metric,
+                strconv.FormatFloat(h.Edges[i], 'f', -1, 64),
+                strconv.FormatFloat(h.Edges[i+1], 'f', -1, 64),
+                strconv.Itoa(count),
+            }
+            if err := writer.Write(row); err != nil {
+                return err
+            }
+        }
+    }
+    writer.Flush()
+    return writer.Error()
+}
+
+// reportHistograms prints a histogram of each metric over the completed games
+// and writes their bins to filename.
+func reportHistograms(w io.Writer, stats []war.GameStats, metrics []HistogramMetric, bins int, filename string) error {
+    games := completedGames(stats)
+    histograms := make([]Histogram, len(metrics))
+    for i, metric := range metrics {
+        data := make([]float64, len(games))
+        for j, game := range games {
+            data[j] = metric.Value(game)
+        }
+        histograms[i] = newHistogram(metric.Name, data, bins, metric.Integer)
+        printHistogram(w, histograms[i], metric.Integer)
+    }
+    return writeHistograms(filename, histograms)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestHistogramBins(t *testing.T) {
+    tests := []struct {
+        name    string
+        data    []float64
+        bins    int
+        integer bool
+        edges   []float64
+        counts  []int
+    }{
+        // 0 to 9 in 5 bins of 2 whole numbers: 9 goes in the last.
+        {"integer", []float64{0, 1, 1, 2, 3, 5, 8, 9}, 5, true, []float64{0, 2, 4, 6, 8, 10}, []int{3, 2, 1, 0, 2}},
+        // 10 to 12 in 2 bins: a width of 2 covers them in two.
+        {"integer, uneven", []float64{10, 11, 12}, 2, true, []float64{10, 12, 14}, []int{2, 1}},
+        // A value on an edge goes in the bin it starts; the maximum in the last.
+        {"fractional", []float64{0, 0.5, 1, 1.5, 2}, 2, false, []float64{0, 1, 2}, []int{2, 3}},
+        {"constant", []float64{4, 4}, 3, false, []float64{4, 5}, []int{2}},
+    }
+    for _, tt := range tests {
+        h := newHistogram("m", tt.data, tt.bins, tt.integer)
+        if !slices.Equal(h.Edges, tt.edges) || !slices.Equal(h.Counts, tt.counts) {
+            t.Errorf("%s: edges %v and counts %v, want %v and %v", tt.name, h.Edges, h.Counts, tt.edges, tt.counts)
+        }
+    }
+}
+
+func TestHistogramReport(t *testing.T) {
+    metrics, err := parseHistogramMetrics("tricks, wars")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := parseHistogramMetrics("tricks,cards"); err == nil {
+        t.Error("parseHistogramMetrics accepted an unknown metric")
+    }
+
+    stats := []war.GameStats{
+        {Tricks: 10, Wars: 1},
+        {Tricks: 12, Wars: 1},
+        {Tricks: 19, Wars: 3},
+        {Tricks: -1, Errored: true},
+    }
+    filename := filepath.Join(t.TempDir(), "histogram.csv")
+    var buf bytes.Buffer
+    if err := reportHistograms(&buf, stats, metrics, 2, filename); err != nil {
+        t.Fatal(err)
+    }
+    for _, line := range []string{
+        "Histogram of tricks:",
+        "[      10,       14]        2 " + strings.Repeat("#", histogramBarWidth),
+        "[      15,       19]        1 " + strings.Repeat("#", histogramBarWidth/2),
+        "Histogram of wars:",
+    } {
+        if !strings.Contains(buf.String(), line+"\n") {
+            t.Errorf("histograms lack %q:\n%s", line, buf.String())
+        }
+    }
+    written, _ := os.ReadFile(filename)
+    want := "Metric,Bin Start,Bin End,Count\ntricks,10,15,2\ntricks,15,20,1\nwars,1,3,2\nwars,3,5,1\n"
+    if string(written) != want {
+        t.Errorf("histogram file is\n%s\nwant\n%s", written, want)
+    }
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+
+    "wargames/war"
+)
+
+// playInteractive plays game gameNumber of the seeded run one trick at a time,
+// showing the cards turned up, how many each player holds and Player A's
+// estimated chance of winning from there. Between tricks
+// it waits for Enter on in, or for the Autoplay delay when that is set; "q"
+// or the end of in stops the game early.
+func playInteractive(in io.Reader, w io.Writer, gameNumber int, cfg Config) {
+    rng := war.NewSeededRand(cfg.gameSeed(gameNumber), cfg.RNGWarmup)
+    opts := cfg.Options.Options
+    opts.RecordDeal = true
+    game := war.NewGame(nil, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, opts, rng)
+    deal := game.Stats()
+    checkAndSaveReplay(cfg, gameNumber, deal.InitialDealA, deal.InitialDealB)
+    scanner := bufio.NewScanner(in)
+
+    cardsA, cardsB := game.CardsLeft()
+    deckSize := cardsA + cardsB
+    if cfg.LoadReplay != "" {
+        fmt.Fprintf(w, "Game in %s: ", cfg.LoadReplay)
+    } else {
+        fmt.Fprintf(w, "Game %d with seed %d: ", gameNumber, cfg.Seed)
+    }
+    fmt.Fprintf(w, "Player A holds %d cards, Player B holds %d\n", cardsA, cardsB)
+    for {
+        trick, ok := game.PlayTrick()
+        if !ok {
+            break
+        }
+        printTrick(w, trick)
+        cardsA, cardsB = game.CardsLeft()
+        fmt.Fprintf(w, "  Cards: A %d, B %d (A wins %.0f%%)\n", cardsA, cardsB, 100*winProbability(cardsA-cardsB, deckSize))
+        if game.Over() {
+            break
+        }
+
+        if cfg.Autoplay > 0 {
+            time.Sleep(cfg.Autoplay)
+            continue
+        }
+        fmt.Fprint(w, "Press Enter for the next trick, or q to quit: ")
+        if !scanner.Scan() {
+            fmt.Fprintln(w)
+            return
+        }
+        if strings.TrimSpace(scanner.Text()) == "q" {
+            return
+        }
+    }
+
+    stats := game.Stats()
+    fmt.Fprintf(w, "Game over after %d tricks (%s): ", stats.Tricks, stats.TerminationReason)
+    switch {
+    case stats.Winner == 1:
+        fmt.Fprintln(w, "Player A wins")
+    case stats.Winner == 2:
+        fmt.Fprintln(w, "Player B wins")
+    case stats.Finished:
+        fmt.Fprintln(w, "a draw")
+    default:
+        fmt.Fprintln(w, "no winner")
+    }
+}
+
+// printTrick describes one trick: the cards turned up, every card laid in a
+// war, and who took the cards.
+func printTrick(w io.Writer, trick war.TrickResult) {
+    fmt.Fprintf(w, "Trick %d: A turns up %s, B turns up %s\n", trick.Trick, trick.CardA, trick.CardB)
+    taken := 2
+    if trick.WarDepth > 0 {
+        fmt.Fprintf(w, "  War, %d round(s)!\n", trick.WarDepth)
+        fmt.Fprintf(w, "  A laid: %s\n", handCards(trick.CardsA))
+        fmt.Fprintf(w, "  B laid: %s\n", handCards(trick.CardsB))
+        taken = len(trick.CardsA) + len(trick.CardsB)
+    }
+    switch trick.Winner {
+    case 1:
+        fmt.Fprintf(w, "  Player A takes %d cards\n", taken)
+    case 2:
+        fmt.Fprintf(w, "  Player B takes %d cards\n", taken)
+    default:
+        fmt.Fprintln(w, "  Time ran out; nobody takes the cards")
+    }
+}
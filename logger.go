@@ -0,0 +1,63 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+)
+
+// Level is how much a Logger reports.
+type Level int
+
+const (
+    LevelError Level = iota // Errors only
+    LevelWarn               // Errors and warnings
+    LevelInfo               // Also the progress messages around a run
+)
+
+// Logger reports the CLI's messages by level: errors and warnings on Err,
+// information on Out. Messages above Level are dropped. Summaries and
+// reports are the run's output, not messages, and are written directly.
+type Logger struct {
+    Out   io.Writer
+    Err   io.Writer
+    Level Level
+}
+
+// logger is the CLI's Logger, set up from -quiet and -silent by main.
+var logger = &Logger{Out: os.Stdout, Err: os.Stderr, Level: LevelInfo}
+
+// newLogger returns a Logger for the -quiet and -silent flags: -quiet drops
+// information, and -silent warnings too.
+func newLogger(quiet, silent bool) *Logger {
+    level := LevelInfo
+    if silent {
+        level = LevelError
+    } else if quiet {
+        level = LevelWarn
+    }
+    return &Logger{Out: os.Stdout, Err: os.Stderr, Level: level}
+}
+
+// Errorf reports an error. It also receives the reports of games that
+// panic, as a war.Logger.
+func (l *Logger) Errorf(format string, args ...any) {
+    l.logf(LevelError, l.Err, "Error: ", format, args)
+}
+
+// Warnf reports a problem that does not stop the run.
+func (l *Logger) Warnf(format string, args ...any) {
+    l.logf(LevelWarn, l.Err, "Warning: ", format, args)
+}
+
+// Infof reports progress around a run.
+func (l *Logger) Infof(format string, args ...any) {
+    l.logf(LevelInfo, l.Out, "", format, args)
+}
+
+func (l *Logger) logf(level Level, w io.Writer, prefix, format string, args []any) {
+    if level > l.Level {
+        return
+    }
+    fmt.Fprintf(w, prefix+format+"\n", args...)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestLoggerLevels(t *testing.T) {
+    tests := []struct {
+        quiet, silent bool
+        out, err      string
+    }{
+        {false, false, "starting\n", "Error: broken\nWarning: odd\n"},
+        {true, false, "", "Error: broken\nWarning: odd\n"},
+        {false, true, "", "Error: broken\n"},
+        {true, true, "", "Error: broken\n"},
+    }
+    for _, tt := range tests {
+        var out, errOut bytes.Buffer
+        l := newLogger(tt.quiet, tt.silent)
+        l.Out, l.Err = &out, &errOut
+        l.Errorf("broken")
+        l.Warnf("odd")
+        l.Infof("starting")
+        if out.String() != tt.out || errOut.String() != tt.err {
+            t.Errorf("quiet %v, silent %v: wrote %q and %q, want %q and %q", tt.quiet, tt.silent, out.String(), errOut.String(), tt.out, tt.err)
+        }
+    }
+}
+
+// A game that panics is reported at error level, with its number and the
+// stack, however quiet the logger is, and the run goes on.
+func TestGamePanicIsLoggedAsAnError(t *testing.T) {
+    var out, errOut bytes.Buffer
+    opts := war.DefaultOptions()
+    opts.Deal = [][]war.Card{{{Rank: 5, Suit: war.Clubs}}} // No hand for Player B
+    opts.Logger = &Logger{Out: &out, Err: &errOut, Level: LevelError}
+    stats := war.RunSimulations(2, 500, 15000, false, 3600000, 1, 1, opts)
+    if len(stats) != 2 || !stats[0].Errored || !stats[1].Errored {
+        t.Fatalf("games with a faulty deal: %+v, want two errored games", stats)
+    }
+    report := errOut.String()
+    for _, want := range []string{"Error: game 1 panicked: ", "Error: game 2 panicked: ", "index out of range", "goroutine "} {
+        if !strings.Contains(report, want) {
+            t.Errorf("panic report lacks %q:\n%s", want, report)
+        }
+    }
+    if out.Len() != 0 {
+        t.Errorf("panic reported on standard output: %q", out.String())
+    }
+}
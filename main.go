@@ -1,389 +1,1283 @@
 package main
 
 import (
-    "encoding/csv"
+    "context"
+    "encoding/json"
     "flag"
     "fmt"
+    "io"
     "math"
-    "math/rand"
     "os"
+    "os/signal"
+    "path/filepath"
+    "runtime"
+    "sort"
     "strconv"
     "time"
+
+    "wargames/war"
 )
 
-type Card struct {
-    Rank int
+// Options holds settings beyond the core simulation parameters: the game
+// rules, which are passed on to the war package, and the CLI's own output and
+// reporting settings.
+type Options struct {
+    war.Options
+    Columns          string // Output column preset: minimal, standard or full
+    Out              string // s3:// or gs:// object to upload results to instead of a local file
+    Fit              bool   // Fit candidate distributions to the trick counts
+    Checksum         bool   // Print a platform-independent checksum of the results
+    REPL             bool   // Start an interactive session instead of a single run
+    Coordinator      string // Address to serve game ranges to workers on
+    Worker           string // Coordinator address to fetch game ranges from
+    Serve            string // Address to serve /simulate on
+    Analytic         bool   // Compare the simulated game length to a random-walk estimate
+    WinRates         bool   // Test Player A's and B's win rates against an even split
+    RankWins         bool   // Report how many tricks each rank decided
+    Features         string // File to write per-game deal features to
+    SummaryFile      string // File to write the run's summary to as JSON
+    Stream           bool   // Keep running totals instead of every game's stats
+    Format           string // Results format: csv, md or json
+    MarkdownGames    bool   // With the md format, write per-game rows instead of the summary
+    MaxTimeSweep     string // start,stop,step cutoffs in milliseconds to report finish rates for
+    SeedSweep        string // start:end seeds to repeat the run with, one summary row each
+    Sweep            string // name=values of a parameter to repeat the run with, one summary row each
+    Surprise         int    // Number of most surprising games to list; 0 disables the report
+    Workers          int    // Number of goroutines playing games
+    ReplayGame       int    // Number of a single game of the seeded run to play and describe
+    TranscriptFile   string // File to write a trick-by-trick transcript of the replayed game to
+    SaveReplay       string // File to save the single game played to as a replay
+    LoadReplay       string // Replay file of the game to play in place of a game of a seeded run
+    ProgressInterval time.Duration // How often to report progress on stderr; 0 disables it
+    Histogram        string // Comma-separated metrics to print histograms of
+    HistogramBins    int    // Number of bins in each histogram
+    OutputDir        string // Directory the output files are written to
+    OutputFile       string // Path of the results file, replacing the generated name
+    DealFile         string // File with fixed starting hands to play every game from
+    DeckRanks        string // Lowest and highest rank of the deck, such as 2-A
+    DryRun           bool   // Describe the run and estimate its cost instead of playing it
+    Profile          string // Profile to write while running: cpu or mem
+    ProfileFile      string // File to write the profile to
+    Quiet            bool   // Print only the summary and reports, not progress messages
+    Silent           bool   // Print nothing but errors
+    Interactive      bool   // Play a single game trick by trick at the console
+    Autoplay         time.Duration // Delay between tricks of an interactive game; 0 waits for Enter
+    Append           bool   // Add the games to the end of an existing CSV OutputFile
+    Compress         bool   // Gzip the results
+    MatchWins        int    // Play matches to this many game wins, Games of them; 0 plays single games
+    MatchDeal        string // Seats in a match's games: redeal (player 1 always A) or alternate
 }
 
-type Player struct {
-    DrawPile     []Card
-    WinningsPile []Card
+// Config holds every parameter of a run: the simulation parameters that the
+// war package takes one by one, and the Options.
+type Config struct {
+    HandTime      int   // Time to play a hand, in milliseconds
+    ShuffleTime   int   // Time to shuffle, in milliseconds
+    IncludeJokers bool
+    Seed          int64 // Run seed; 0 until a time-based seed is chosen
+    Games         int
+    MaxGameTime   int   // Time limit of a game, in milliseconds
+    ReplayDeal    [2][]war.Card // Starting hands held in the -load-replay file
+    ReplaySeed    int64         // Seed of the -load-replay game's own random source
+    Options
 }
 
-type GameStats struct {
-    GameNumber    int
-    Tricks        int
-    Wars          int
-    DeepWars      int
-    TotalWarDepth int
-    ShufflesA     int
-    ShufflesB     int
-    GameDuration  time.Duration
-    Finished      bool
-    PlayerATricks int  // Renamed from PlayerAWins
-    PlayerBTricks int  // Renamed from PlayerBWins
-    Winner        int // 1 for Player A, 2 for Player B
+// gameSeed returns the seed of game gameNumber's random source: the one saved
+// in the -load-replay file, or else the one the seeded run gives it.
+func (cfg Config) gameSeed(gameNumber int) int64 {
+    if cfg.LoadReplay != "" {
+        return cfg.ReplaySeed
+    }
+    return war.GameSeed(cfg.Seed, gameNumber)
 }
 
-
-type WarResult struct {
-    Winner        int // 1 for Player A, 2 for Player B
-    PlayerATricks int // Renamed from PlayerAWins
-    PlayerBTricks int // Renamed from PlayerBWins
+func main() {
+    os.Exit(run())
 }
 
-func main() {
-    handTime, shuffleTime, includeJokers, seed, gamesToPlay, maxGameTime := parseArgs()
+// run runs the command and returns its exit status. Returning rather than
+// exiting lets the deferred profile be written however the run ends.
+func run() (status int) {
+    cfg := parseArgs()
+    logger = newLogger(cfg.Quiet, cfg.Silent)
+    if cfg.OutputFile == "-" {
+        logger.Out = os.Stderr // Standard output carries the results
+    }
+    cfg.Logger = logger
 
-    if seed != 0 {
-        rand.Seed(int64(seed))
-    } else {
-        rand.Seed(time.Now().UnixNano())
+    spec, err := parseDeckSpec(cfg.DeckRanks, cfg.Deck.Copies)
+    if err != nil {
+        logger.Errorf("%v", err)
+        return 1
+    }
+    cfg.Deck = spec
+
+    if cfg.DealFile != "" {
+        deal, err := loadDeal(cfg.DealFile, cfg.Deck, cfg.IncludeJokers)
+        if err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+        cfg.Deal = deal
+    }
+    if err := validateConfig(cfg); err != nil {
+        logger.Errorf("%v", err)
+        return 1
     }
+    if cfg.HandTime == 0 {
+        logger.Warnf("with -hand 0 tricks take no time, so only shuffles count towards -maxtime")
+    }
+    columns, _ := columnsForPreset(cfg.Columns)
 
-    deck := createDeck(includeJokers)
-    fmt.Printf("Deck size: %d\n", len(deck))
+    if cfg.Profile != "" {
+        filename := cfg.ProfileFile
+        if filename == "" {
+            filename = filepath.Join(cfg.OutputDir, "war_"+cfg.Profile+".pprof")
+        }
+        stop, err := startProfile(cfg.Profile, filename)
+        if err != nil {
+            logger.Errorf("starting profile: %v", err)
+            return 1
+        }
+        defer func() {
+            if err := stop(); err != nil {
+                logger.Errorf("writing profile: %v", err)
+                status = 1
+            }
+        }()
+    }
+
+    if cfg.DryRun {
+        printDryRun(os.Stdout, cfg, columns)
+        return 0
+    }
+
+    // A sweep plays to its largest cutoff and reports the smaller ones from
+    // the same games.
+    var sweepCutoffs []int
+    if cfg.MaxTimeSweep != "" {
+        sweepCutoffs, _ = parseMaxTimeSweep(cfg.MaxTimeSweep)
+        cfg.MaxGameTime = sweepCutoffs[len(sweepCutoffs)-1]
+    }
+
+    if cfg.Worker != "" {
+        if err := runWorker(cfg.Worker, cfg.Workers); err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+        return 0
+    }
+
+    if cfg.Serve != "" {
+        if err := runServer(cfg.Serve, cfg); err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+        return 0
+    }
+
+    if cfg.LoadReplay != "" {
+        deal, seed, settings, err := readReplayFile(cfg.LoadReplay)
+        if err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+        if err := checkReplaySettings(cfg.LoadReplay, settings, cfg); err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+        // The replay is played as game 1, from the game's own seed.
+        cfg.ReplayGame, cfg.ReplaySeed, cfg.ReplayDeal = 1, seed, deal
+    }
+
+    if cfg.Interactive {
+        gameNumber := max(cfg.ReplayGame, 1)
+        if cfg.Seed == 0 {
+            cfg.Seed = time.Now().UnixNano()
+        }
+        playInteractive(os.Stdin, os.Stdout, gameNumber, cfg)
+        return 0
+    }
 
-    fmt.Printf("Starting simulation of %d games...\n", gamesToPlay)
+    // A transcript is of a single game: the one being replayed, or else the
+    // first game of the run.
+    if cfg.TranscriptFile != "" && cfg.ReplayGame == 0 {
+        cfg.ReplayGame = 1
+        if cfg.Seed == 0 {
+            cfg.Seed = time.Now().UnixNano()
+        }
+    }
+
+    if cfg.ReplayGame > 0 {
+        if cfg.Seed == 0 && cfg.LoadReplay == "" {
+            logger.Errorf("-replay-game needs the -seed of the run to replay")
+            return 1
+        }
+        replayGame(os.Stdout, cfg)
+        return 0
+    }
+
+    if cfg.REPL {
+        runREPL(os.Stdin, os.Stdout, cfg)
+        return 0
+    }
+
+    if cfg.SeedSweep != "" {
+        seeds, _ := parseSeedSweep(cfg.SeedSweep)
+        filename := cfg.OutputFile
+        if filename == "" {
+            cfg.Seed = seeds[0] // The file is named after the first seed
+            filename = runFileName(fmt.Sprintf("seedsweep%d", len(seeds)), "csv", cfg)
+        }
+        if err := runSeedSweep(os.Stdout, cfg, seeds, filename); err != nil {
+            logger.Errorf("writing seed sweep: %v", err)
+            return 1
+        }
+        return 0
+    }
+
+    if cfg.Sweep != "" {
+        name, values, _ := parseParameterSweep(cfg.Sweep)
+        if cfg.Seed == 0 {
+            cfg.Seed = time.Now().UnixNano()
+        }
+        logger.Infof("Seed: %d", cfg.Seed)
+        filename := cfg.OutputFile
+        if filename == "" {
+            filename = runFileName("sweep_"+name, "csv", cfg)
+        }
+        if err := runParameterSweep(os.Stdout, cfg, name, values, filename); err != nil {
+            logger.Errorf("writing sweep: %v", err)
+            return 1
+        }
+        return 0
+    }
+
+    // Open the upload destination before simulating so that credential or
+    // permission problems surface immediately.
+    var upload *objectWriter
+    if cfg.Out != "" {
+        var err error
+        upload, err = openObjectWriter(cfg.Out)
+        if err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+    }
+
+    // Appending continues the run already in the file after its last game,
+    // with its seed. A -seed other than the file's is refused by check, as
+    // is any other difference from its metadata.
+    first := 0
+    if cfg.Append {
+        target, err := readAppendTarget(cfg.OutputFile)
+        if err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+        if target != nil {
+            if cfg.Seed == 0 {
+                cfg.Seed, _ = strconv.ParseInt(target.Metadata["seed"], 10, 64)
+            }
+            if err := target.check(newRunMetadata(cfg), columns); err != nil {
+                logger.Errorf("%v", err)
+                return 1
+            }
+            first = target.LastGame
+        }
+    }
+
+    // Every game is seeded from the run seed and its game number. A
+    // time-based seed is fixed here, so that the run is named after and can
+    // be repeated with the seed it actually used.
+    if cfg.Seed == 0 {
+        cfg.Seed = time.Now().UnixNano()
+    }
+
+    // Silent drops the summary and reports too, leaving only the results
+    // file; the logger already drops the messages around the run. Results
+    // streamed to standard output move them to standard error.
+    var out io.Writer = os.Stdout
+    if cfg.OutputFile == "-" {
+        out = os.Stderr
+    }
+    if cfg.Silent {
+        out = io.Discard
+    }
+
+    deck := war.CreateDeck(cfg.Deck, cfg.IncludeJokers)
+    logger.Infof("Deck size: %d", len(deck))
+    logger.Infof("Seed: %d", cfg.Seed)
+
+    if cfg.NoReshuffle {
+        logger.Infof("Reshuffling disabled: winnings piles are played in the order won")
+    }
+    unit := "games"
+    if cfg.MatchWins > 0 {
+        unit = "matches"
+    }
+    // Interrupting the run stops it cleanly, and the games finished so far
+    // are written and summarized as a shorter run. A coordinator cannot
+    // stop its workers, so it is left to be killed as before. The handler
+    // is in place before the run is announced or reports its progress, so
+    // an interrupt sent on seeing either is never lost.
+    ctx := context.Background()
+    stopSignals := func() {}
+    if cfg.Coordinator == "" {
+        ctx, stopSignals = signal.NotifyContext(ctx, os.Interrupt)
+    }
+    logger.Infof("Starting simulation of %d %s...", cfg.Games, unit)
     startTime := time.Now()
-    stats := runSimulations(gamesToPlay, handTime, shuffleTime, includeJokers, maxGameTime)
-    fmt.Printf("Simulation completed in %v\n", time.Since(startTime))
+    stopProgress := func() {}
+    if cfg.ProgressInterval > 0 {
+        cfg.Progress, stopProgress = startProgress(os.Stderr, cfg.Games, unit, cfg.ProgressInterval)
+    }
+    // A streamed run writes its results as it goes and keeps only its
+    // summary, so no reports that need every game are made. Interrupted, it
+    // stops after the chunk in progress.
+    if cfg.Stream {
+        summary, err := runStream(ctx, cfg, columns)
+        stopProgress()
+        if err != nil {
+            logger.Errorf("writing results: %v", err)
+            return 1
+        }
+        if ctx.Err() != nil {
+            logger.Warnf("interrupted after %d of %d games; the results so far are written", summary.Games, cfg.Games)
+        }
+        stopSignals()
+        logger.Infof("Simulation completed in %v", time.Since(startTime))
+        if cfg.SummaryFile != "" {
+            if err := writeSummaryFile(cfg.SummaryFile, summary); err != nil {
+                logger.Errorf("writing summary: %v", err)
+                status = 1
+            }
+        }
+        printSummary(out, summary)
+        return status
+    }
 
-    writeResultsToFile(stats, handTime, shuffleTime, includeJokers, seed, gamesToPlay, maxGameTime)
-    printSummaryStatistics(stats)
+    stats, matches, err := playRun(ctx, cfg, first)
+    stopProgress()
+    if err != nil {
+        logger.Errorf("%v", err)
+        return 1
+    }
+    if ctx.Err() != nil {
+        played := len(stats)
+        if cfg.MatchWins > 0 {
+            played = len(matches)
+        }
+        logger.Warnf("interrupted after %d of %d %s; writing the results so far", played, cfg.Games, unit)
+        cfg.Games = played
+    }
+    stopSignals() // A second interrupt kills the process
+    logger.Infof("Simulation completed in %v", time.Since(startTime))
+
+    meta := newRunMetadata(cfg)
+    if cfg.Checksum {
+        meta.Checksum = runChecksum(stats)
+    }
+    if upload != nil {
+        err := WriteResults(upload, stats, resultsFormat(cfg.Options, columns, meta))
+        if err != nil {
+            upload.Abort()
+        } else {
+            err = upload.Close()
+        }
+        if err != nil {
+            logger.Errorf("uploading results: %v", err)
+            return 1
+        }
+    } else if cfg.OutputFile == "-" {
+        if err := WriteResults(os.Stdout, stats, resultsFormat(cfg.Options, columns, meta)); err != nil {
+            logger.Errorf("writing results: %v", err)
+            return 1
+        }
+    } else {
+        if err := writeResultsToFile(resultsFileName(cfg), stats, resultsFormat(cfg.Options, columns, meta), cfg.Append); err != nil {
+            logger.Errorf("writing results: %v", err)
+            return 1
+        }
+    }
+    // A file that cannot be written fails the run, but only once the
+    // reports that do not depend on it have been made.
+    if cfg.SummaryFile != "" {
+        if err := writeSummaryFile(cfg.SummaryFile, newSummary(stats)); err != nil {
+            logger.Errorf("writing summary: %v", err)
+            status = 1
+        }
+    }
+    printSummaryStatistics(out, stats)
+    if cfg.DeterminismProbe {
+        printDeterminismProbe(out, stats)
+    }
+    if cfg.VerifySuits {
+        if err := checkSuitBlindness(out, stats); err != nil {
+            logger.Errorf("%v", err)
+            return 1
+        }
+    }
+    if cfg.TrackLead {
+        printComebacks(out, stats)
+    }
+    if cfg.RankWins {
+        printRankWins(out, stats)
+    }
+    if cfg.Fit {
+        tricks := make([]float64, 0, len(stats))
+        for _, game := range stats {
+            if !game.Errored {
+                tricks = append(tricks, float64(game.Tricks))
+            }
+        }
+        printDistributionFits(out, "Tricks", tricks)
+    }
+    if cfg.Analytic {
+        printAnalyticEstimate(out, stats, deck, cfg.WarDown)
+    }
+    if cfg.WinRates {
+        printWinRateReport(out, WinRateReport(stats))
+    }
+    if cfg.Deal != nil {
+        printDealStrength(out, WinRateReport(stats))
+    }
+    if matches != nil {
+        printMatchReport(out, matches, cfg.MatchWins)
+    }
+    if cfg.Features != "" {
+        if err := writeFeatures(outputPath(cfg, cfg.Features), stats); err != nil {
+            logger.Errorf("writing features: %v", err)
+            status = 1
+        }
+    }
+    if cfg.Surprise > 0 {
+        printSurpriseReport(out, stats, cfg.Surprise)
+    }
+    if sweepCutoffs != nil {
+        printMaxTimeSweep(out, stats, sweepCutoffs)
+    }
+    if cfg.Histogram != "" {
+        metrics, _ := parseHistogramMetrics(cfg.Histogram)
+        filename := runFileName("histogram", "csv", cfg)
+        if err := reportHistograms(out, stats, metrics, cfg.HistogramBins, filename); err != nil {
+            logger.Errorf("writing histograms: %v", err)
+            status = 1
+        }
+    }
+    if cfg.Checksum {
+        fmt.Fprintf(out, "Results checksum: %s\n", meta.Checksum)
+    }
+    return status
 }
 
 
-func parseArgs() (int, int, bool, int64, int, int) {
+// parseArgs reads the command line into a Config.
+func parseArgs() Config {
     handTime := flag.Int("hand", 500, "Time to play a hand (in milliseconds)")
     shuffleTime := flag.Int("shuffle", 15000, "Time to shuffle (in milliseconds)")
     includeJokers := flag.Bool("jokers", false, "Include jokers in the deck")
     seed := flag.Int64("seed", 0, "Random seed (0 for current time)")
     gamesToPlay := flag.Int("games", 100, "Number of games to play")
     maxGameTime := flag.Int("maxtime", 3600000, "Maximum game time in milliseconds (default 1 hour)")
+    columns := flag.String("columns", "standard", "Output column preset: minimal, standard or full")
+    determinismProbe := flag.Bool("determinism-probe", false, "Also play each deal without reshuffling and report how often the winners agree")
+    verify := flag.Bool("verify", false, "Also play each game with the suits permuted within every rank and fail unless the outcome is the same")
+    out := flag.String("out", "", "Upload results to an s3://bucket/key or gs://bucket/key object instead of a local file")
+    rngWarmup := flag.Int("rng-warmup", 0, "Number of outputs to discard from each game's random source after seeding")
+    replayGame := flag.Int("replay-game", 0, "Play only this game number of the run given by -seed and describe it in detail")
+    transcript := flag.String("transcript", "", "Play a single game (the -replay-game one, or game 1) and write every trick to this file as JSON lines")
+    saveReplay := flag.String("save-replay", "", "Save the single game played by -replay-game, -transcript, -interactive or -load-replay to this .war replay file")
+    loadReplay := flag.String("load-replay", "", "Play the game saved in this .war replay file, as -replay-game would, or step through it with -interactive")
+    workers := flag.Int("workers", runtime.NumCPU(), "Number of games to play in parallel")
+    deckRanks := flag.String("deck-ranks", "2-A", "Lowest and highest rank in the deck, such as 2-A or 9-10")
+    deckCopies := flag.Int("deck-copies", 4, "Number of cards of each rank in the deck")
+    deal := flag.String("deal", "", "Play every game from the starting hands in this file: Player A's ranks on the first line, Player B's on the second, top card first")
+    profile := flag.String("profile", "", "Write a pprof profile of the whole invocation: cpu, or mem for the heap at the end")
+    profileFile := flag.String("profile-file", "", "File to write the -profile profile to instead of war_<kind>.pprof in -output-dir")
+    dryRun := flag.Bool("dry-run", false, "Check the settings and print the run's parameters, deck and estimated memory, results size and time from a short calibration run, without playing it")
+    quiet := flag.Bool("quiet", false, "Print only the summary and reports, not the messages around the run")
+    interactive := flag.Bool("interactive", false, "Play a single game (the -replay-game one, or game 1) trick by trick, pausing after each")
+    autoplay := flag.Duration("autoplay", 0, "With -interactive, play on after this delay, such as 500ms, instead of waiting for Enter")
+    silent := flag.Bool("silent", false, "Print nothing but errors, which go to stderr; only the results file is written")
+    outputDir := flag.String("output-dir", ".", "Directory to write the results and other generated files to, created if missing")
+    matchWins := flag.Int("match-wins", 0, "Play -games matches, each until a player has won this many games, and report who won them (0 plays single games)")
+    matchDeal := flag.String("match-deal", "redeal", "Seats in a match: redeal (player 1 is always Player A) or alternate (the players change seats every game)")
+    compress := flag.Bool("compress", false, "Gzip the results, adding .gz to the generated file name")
+    appendResults := flag.Bool("append", false, "Add the games to the end of the existing CSV -output-file, continuing its run and game numbers, instead of overwriting it")
+    outputFile := flag.String("output-file", "", "Write the results to this file instead of a generated name in -output-dir")
+    histogram := flag.String("histogram", "", "Comma-separated metrics to print histograms of and write bin counts for: tricks, wars, deep-wars, shuffles-a, shuffles-b, deal-balance, minutes")
+    histogramBins := flag.Int("histogram-bins", 20, "Number of bins in each -histogram")
+    progress := flag.Duration("progress", 0, "Report the games completed and an ETA on stderr at this interval, such as 10s (0 disables)")
+    features := flag.String("features", "", "Write a per-game feature vector derived from the initial deal to this CSV file")
+    stream := flag.Bool("stream", false, "Write the results as the games are played and keep only running totals for the summary, so memory stays bounded however many games are played")
+    summaryFile := flag.String("summary-file", "", "Write the summary statistics, outcomes and termination reasons of the run to this JSON file")
+    format := flag.String("format", "csv", "Results file format: csv, md for Markdown tables, or json")
+    markdownGames := flag.Bool("md-games", false, "With -format md, write a per-game table (first 1000 games) instead of the summary")
+    sweep := flag.String("sweep", "", "Play the run once for each value of hand, shuffle or maxtime, such as hand=100,500,1000, and write a summary row per value instead of the results")
+    seedSweep := flag.String("seed-sweep", "", "Play the run once for each seed from start to end, stepping by 1 or by step in start:end:step, and write a summary row per seed instead of the results")
+    maxTimeSweep := flag.String("maxtime-sweep", "", "Report the finish rate at each maxtime start,stop,step (milliseconds); games are played once with the largest")
+    surprise := flag.Int("surprise", 0, "Score how unexpected each game's winner was given the deal and list this many of the most surprising games")
+    initialSortedness := flag.Float64("initial-sortedness", 0, "Fraction of each new deck left in factory order before dealing: 0 is fully shuffled, 1 is unshuffled")
+    fairDeal := flag.Bool("fair-deal", false, "Swap cards between the shuffled halves of the deck until the players' starting pip sums are within -fair-deal-tolerance")
+    fairDealTolerance := flag.Int("fair-deal-tolerance", 0, "Largest difference between the starting pip sums that -fair-deal leaves")
+    captureTo := flag.String("capture-to", "winnings", "Where won cards go: winnings, a separate pile shuffled in when the draw pile runs out, or bottom, the bottom of the draw pile, the same as -variant single-pile")
+    noShuffle := flag.Bool("no-shuffle", false, "Deal every game from an unshuffled deck in factory order, the same as -initial-sortedness 1")
+    warDown := flag.Int("war-down", 3, "Number of cards each player lays face down in a round of war")
+    maxTricks := flag.Int("max-tricks", war.DefaultOptions().MaxTricks, "Tricks after which a game is stopped unfinished as a trick-limit (0 for no limit)")
+    maxWarDepth := flag.Int("max-war-depth", 0, "Rounds after which a war that keeps tying is decided in favour of the player holding more cards (0 for no limit)")
+    shortWar := flag.String("short-war", "play-out", "A player without enough cards for a round of war: play-out (lays what they have) or forfeit (loses the war and its whole pot)")
+    shuffleModel := flag.String("shuffle-model", "uniform", "How players shuffle their winnings: uniform, riffle (one clumpy riffle) or overhand (one overhand shuffle)")
+    players := flag.Int("players", 2, "Number of players (more than 2 plays the standard variant with war tie-breaks only)")
+    tieBreak := flag.String("tiebreak", "war", "How cards of equal rank are settled: war, or suit to give the trick to the higher suit")
+    suitOrder := flag.String("suit-order", "cdhs", "Suits from lowest to highest for -tiebreak suit (c, d, h, s)")
+    analytic := flag.Bool("analytic", false, "Compare the simulated mean game length to an analytical estimate from the war rate")
+    rankWins := flag.Bool("rank-wins", false, "Report how many tricks were decided by each rank of card")
+    winRates := flag.Bool("win-rates", false, "Report Player A's and B's win rates with a confidence interval and a chi-square test against 50/50")
+    checksum := flag.Bool("checksum", false, "Print a checksum of the per-game results for reproducibility checks")
+    reshuffle := flag.Bool("reshuffle", true, "Shuffle a player's winnings pile before playing it; false turns it over and plays it in order")
+    trackLead := flag.Bool("track-lead", false, "Follow who holds more cards after every trick and report lead changes and comebacks")
+    logEvents := flag.Bool("log", false, "Record per-game events (the trick of every reshuffle) in the results")
+    coordinator := flag.String("coordinator", "", "Listen on this address and distribute games to workers")
+    worker := flag.String("worker", "", "Play games assigned by the coordinator at this address")
+    serve := flag.String("serve", "", "Serve runs over HTTP on this address, such as :8080, at /simulate?games=N&seed=S, returning JSON")
+    simulEnd := flag.String("simul-end", "b", "Outcome when both players run out of cards in the same trick: a, b, draw, or pile (more tricks won)")
+    repl := flag.Bool("repl", false, "Start an interactive session for adjusting parameters and running batches")
+    variant := flag.String("variant", "standard", "Rule variant: standard, count-tricks (won cards are discarded and the most tricks wins), central-discard (won cards go to a shared discard) or single-pile (won cards go to the bottom of the draw pile)")
+    fit := flag.Bool("fit", false, "Fit log-normal and gamma distributions to the trick counts")
+    jokerRule := flag.String("joker-rule", "high", "How a joker compares with -jokers: high (beats everything), wild (ties any card, forcing a war) or low (loses to everything)")
+    collectOrder := flag.String("collect-order", "fixed", "Whose cards the winner of a trick or war takes first: fixed (Player A's), random or winner-first")
+    warCollectOrder := flag.String("war-collect-order", "interleaved", "Order a won war pile is added to the winner's pile: interleaved, owner-grouped or shuffled")
+
+    configFile := flag.String("config", "", "Read settings from this JSON file of flag names and values; flags on the command line take precedence")
 
     flag.Parse()
+    if *configFile != "" {
+        if err := applyConfigFile(*configFile, flag.CommandLine); err != nil {
+            logger.Errorf("%v", err)
+            os.Exit(1)
+        }
+    }
 
-    return *handTime, *shuffleTime, *includeJokers, *seed, *gamesToPlay, *maxGameTime
-}
-
-func runSimulations(gamesToPlay, handTime, shuffleTime int, includeJokers bool, maxGameTime int) []GameStats {
-    stats := make([]GameStats, gamesToPlay)
-    for i := 0; i < gamesToPlay; i++ {
-        func() {
-            defer func() {
-                if r := recover(); r != nil {
-                    fmt.Printf("Panic occurred in game %d: %v\n", i+1, r)
-                    stats[i] = GameStats{GameNumber: i + 1, Tricks: -1, Finished: false} // Use -1 to indicate an error
-                }
-            }()
-            stats[i] = playGame(handTime, shuffleTime, includeJokers, maxGameTime)
-            stats[i].GameNumber = i + 1
-        }()
+    if *noShuffle {
+        *initialSortedness = 1
     }
-    return stats
-}
-
-func playGame(handTime, shuffleTime int, includeJokers bool, maxGameTime int) GameStats {
-    deck := createDeck(includeJokers)
-    shuffleDeck(deck)
-
-    playerA := Player{DrawPile: deck[:len(deck)/2]}
-    playerB := Player{DrawPile: deck[len(deck)/2:]}
-
-    stats := GameStats{}
-    totalTime := 0 // in milliseconds
-    maxTricks := 10000000 // Safety mechanism to prevent infinite games
-
-    for len(playerA.DrawPile) + len(playerA.WinningsPile) > 0 && 
-        len(playerB.DrawPile) + len(playerB.WinningsPile) > 0 && 
-        stats.Tricks < maxTricks && totalTime < maxGameTime {
-        
-        stats.Tricks++
-        totalTime += handTime
-
-        // Check if we've exceeded the time limit
-        if totalTime >= maxGameTime {
-            result := timeoutResult(&playerA, &playerB)
-            stats.PlayerATricks += result.PlayerATricks
-            stats.PlayerBTricks += result.PlayerBTricks
-            stats.Winner = result.Winner
-            stats.Finished = true
-            break
-        }
-
-        cardA, shuffledA := drawCard(&playerA)
-        cardB, shuffledB := drawCard(&playerB)
-        stats.ShufflesA += shuffledA
-        stats.ShufflesB += shuffledB
-
-		// Only add shuffle time once if either or both players shuffled
-		if shuffledA == 1 || shuffledB == 1 {
-			totalTime += shuffleTime
-		}
-
-        if cardA.Rank == cardB.Rank {
-            warPile := []Card{cardA, cardB}
-            result := handleWar(&playerA, &playerB, warPile, &stats, &totalTime, handTime, shuffleTime, maxGameTime, 1)
-            stats.PlayerATricks += result.PlayerATricks
-            stats.PlayerBTricks += result.PlayerBTricks
-            if result.Winner == 1 {
-                playerA.WinningsPile = append(playerA.WinningsPile, warPile...)
-            } else if result.Winner == 2 {
-                playerB.WinningsPile = append(playerB.WinningsPile, warPile...)
-            }
-        } else if cardA.Rank > cardB.Rank {
-            playerA.WinningsPile = append(playerA.WinningsPile, cardA, cardB)
-            stats.PlayerATricks++
-        } else {
-            playerB.WinningsPile = append(playerB.WinningsPile, cardA, cardB)
-            stats.PlayerBTricks++
+    switch *captureTo {
+    case "winnings":
+    case "bottom":
+        if *variant != "standard" && *variant != "single-pile" {
+            logger.Errorf("-capture-to bottom is the single-pile variant and cannot be combined with -variant %s", *variant)
+            os.Exit(1)
         }
+        *variant = "single-pile"
+    default:
+        logger.Errorf("unknown capture-to %q (want bottom or winnings)", *captureTo)
+        os.Exit(1)
+    }
+    opts := Options{
+        Options: war.Options{
+            DeterminismProbe:  *determinismProbe,
+            VerifySuits:       *verify,
+            RNGWarmup:         *rngWarmup,
+            NoReshuffle:       !*reshuffle,
+            WarCollectOrder:   *warCollectOrder,
+            CollectOrder:      *collectOrder,
+            JokerRule:         *jokerRule,
+            Variant:           *variant,
+            Log:               *logEvents,
+            TrackLead:         *trackLead,
+            SimulEnd:          *simulEnd,
+            RecordDeal:        *features != "" || *surprise > 0,
+            InitialSortedness: *initialSortedness,
+            FairDeal:          *fairDeal,
+            FairDealTolerance: *fairDealTolerance,
+            TieBreak:          *tieBreak,
+            SuitOrder:         *suitOrder,
+            WarDown:           *warDown,
+            ShortWar:          *shortWar,
+            ShuffleModel:      *shuffleModel,
+            MaxWarDepth:       *maxWarDepth,
+            MaxTricks:         *maxTricks,
+            Players:           *players,
+            Deck:              war.DeckSpec{Copies: *deckCopies},
+        },
+        Columns:          *columns,
+        Out:              *out,
+        Fit:              *fit,
+        Checksum:         *checksum,
+        REPL:             *repl,
+        Coordinator:      *coordinator,
+        Worker:           *worker,
+        Serve:            *serve,
+        Analytic:         *analytic,
+        WinRates:         *winRates,
+        RankWins:         *rankWins,
+        Features:         *features,
+        SummaryFile:      *summaryFile,
+        Stream:           *stream,
+        Format:           *format,
+        MarkdownGames:    *markdownGames,
+        MaxTimeSweep:     *maxTimeSweep,
+        SeedSweep:        *seedSweep,
+        Sweep:            *sweep,
+        Surprise:         *surprise,
+        Workers:          *workers,
+        ReplayGame:       *replayGame,
+        TranscriptFile:   *transcript,
+        SaveReplay:       *saveReplay,
+        LoadReplay:       *loadReplay,
+        ProgressInterval: *progress,
+        Histogram:        *histogram,
+        HistogramBins:    *histogramBins,
+        OutputDir:        *outputDir,
+        OutputFile:       *outputFile,
+        Append:           *appendResults,
+        Compress:         *compress,
+        MatchWins:        *matchWins,
+        MatchDeal:        *matchDeal,
+        DealFile:         *deal,
+        DeckRanks:        *deckRanks,
+        DryRun:           *dryRun,
+        Profile:          *profile,
+        ProfileFile:      *profileFile,
+        Quiet:            *quiet,
+        Silent:           *silent,
+        Interactive:      *interactive,
+        Autoplay:         *autoplay,
     }
 
-    if !stats.Finished {
-        stats.Finished = len(playerA.DrawPile) + len(playerA.WinningsPile) == 0 || 
-            len(playerB.DrawPile) + len(playerB.WinningsPile) == 0
-        if stats.Finished {
-            if len(playerA.DrawPile) + len(playerA.WinningsPile) == 0 {
-                stats.Winner = 2 // Player B wins
-            } else {
-                stats.Winner = 1 // Player A wins
-            }
-        }
+    return Config{
+        HandTime:      *handTime,
+        ShuffleTime:   *shuffleTime,
+        IncludeJokers: *includeJokers,
+        Seed:          *seed,
+        Games:         *gamesToPlay,
+        MaxGameTime:   *maxGameTime,
+        Options:       opts,
     }
+}
 
-    stats.GameDuration = time.Duration(totalTime) * time.Millisecond
-    return stats
+// playRun plays the run's games from game first+1, or with -match-wins its
+// matches, until they are all played or ctx is cancelled. A cancelled run
+// returns the games, or matches, completed before the first that was not.
+func playRun(ctx context.Context, cfg Config, first int) ([]war.GameStats, []MatchStats, error) {
+    cfg.Context = ctx
+    switch {
+    case cfg.MatchWins > 0:
+        matches := playMatches(cfg)
+        return matchGames(matches), matches, nil
+    case cfg.Coordinator != "":
+        stats, err := runCoordinator(cfg.Coordinator, cfg)
+        return stats, nil, err
+    }
+    return war.RunGameRange(first, cfg.Games, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, cfg.Seed, cfg.Workers, cfg.Options.Options), nil, nil
 }
 
-func drawWarCards(player *Player, shuffles *int, totalTime *int, handTime, shuffleTime int) []Card {
-    cards := make([]Card, 0, 4)
-    for i := 0; i < 4; i++ {
-        card, shuffled := drawCard(player)
-        if shuffled > 0 {
-            *shuffles++
-            *totalTime += shuffleTime
-        }
-        *totalTime += handTime // Time for drawing each card
-        if (card == Card{}) {
-            break // No more cards available
-        }
-        cards = append(cards, card)
+// validateConfig checks the run's times and game count, then its options.
+func validateConfig(cfg Config) error {
+    if cfg.HandTime < 0 {
+        return fmt.Errorf("hand time must not be negative, got %d", cfg.HandTime)
+    }
+    if cfg.ShuffleTime < 0 {
+        return fmt.Errorf("shuffle time must not be negative, got %d", cfg.ShuffleTime)
+    }
+    if cfg.Games < 1 {
+        return fmt.Errorf("games must be at least 1, got %d", cfg.Games)
     }
-    return cards
+    if cfg.MaxGameTime <= 0 {
+        return fmt.Errorf("maxtime must be positive, got %d", cfg.MaxGameTime)
+    }
+    if cfg.LoadReplay != "" && (cfg.Seed != 0 || cfg.ReplayGame != 0) {
+        return fmt.Errorf("-load-replay plays the game saved in its file and cannot be combined with -seed or -replay-game")
+    }
+    if cfg.MaxTricks == 0 && cfg.HandTime == 0 {
+        return fmt.Errorf("-max-tricks 0 needs a positive hand time, or a game that never ends is never stopped")
+    }
+    return validateOptions(cfg.Options)
 }
 
-func handleWar(playerA, playerB *Player, warPile []Card, stats *GameStats, totalTime *int, handTime, shuffleTime, maxGameTime, depth int) WarResult {
-    stats.Wars++
-    stats.TotalWarDepth += depth
-    *totalTime += handTime // Time for the initial war comparison
+// validateOptions checks that every named option has a known value.
+func validateOptions(opts Options) error {
+    if _, err := columnsForPreset(opts.Columns); err != nil {
+        return err
+    }
+    switch opts.Profile {
+    case "", "cpu", "mem":
+    default:
+        return fmt.Errorf("unknown profile %q (want cpu or mem)", opts.Profile)
+    }
+
+    if err := opts.Options.Validate(); err != nil {
+        return err
+    }
+
+    switch opts.Format {
+    case "csv", "md", "json":
+    default:
+        return fmt.Errorf("unknown format %q (want csv, md or json)", opts.Format)
+    }
+
+    if opts.ReplayGame < 0 {
+        return fmt.Errorf("replay game must be a game number, got %d", opts.ReplayGame)
+    }
+
+    if opts.Workers < 1 {
+        return fmt.Errorf("workers must be at least 1, got %d", opts.Workers)
+    }
 
-    if *totalTime >= maxGameTime {
-        return timeoutResult(playerA, playerB)
+    if opts.Surprise < 0 {
+        return fmt.Errorf("surprise must not be negative, got %d", opts.Surprise)
     }
 
-    cardsA := drawWarCards(playerA, &stats.ShufflesA, totalTime, handTime, shuffleTime)
-    cardsB := drawWarCards(playerB, &stats.ShufflesB, totalTime, handTime, shuffleTime)
+    if opts.Histogram != "" {
+        if _, err := parseHistogramMetrics(opts.Histogram); err != nil {
+            return err
+        }
+        if opts.HistogramBins < 1 {
+            return fmt.Errorf("histogram bins must be at least 1, got %d", opts.HistogramBins)
+        }
+    }
 
-    if len(cardsA) == 0 || len(cardsB) == 0 {
-        return determineWarWinner(cardsA, cardsB)
+    if opts.Append && (opts.OutputFile == "" || opts.OutputFile == "-" || opts.Format != "csv" || opts.Compress || opts.Out != "" || opts.Coordinator != "") {
+        return fmt.Errorf("-append needs an uncompressed CSV -output-file and a local run")
     }
 
-    warPile = append(warPile, cardsA[:len(cardsA)-1]...)
-    warPile = append(warPile, cardsB[:len(cardsB)-1]...)
+    if opts.MatchWins < 0 {
+        return fmt.Errorf("match wins must not be negative, got %d", opts.MatchWins)
+    }
+    switch opts.MatchDeal {
+    case "redeal", "alternate":
+    default:
+        return fmt.Errorf("unknown match deal %q (want redeal or alternate)", opts.MatchDeal)
+    }
+    if opts.MatchWins > 0 && (opts.Coordinator != "" || opts.Append || opts.Players > 2) {
+        return fmt.Errorf("matches are played by two players in a local run, without -append")
+    }
 
-    cardA, cardB := cardsA[len(cardsA)-1], cardsB[len(cardsB)-1]
-    warPile = append(warPile, cardA, cardB)
+    if opts.ProgressInterval < 0 {
+        return fmt.Errorf("progress interval must not be negative, got %v", opts.ProgressInterval)
+    }
 
-    if cardA.Rank == cardB.Rank {
-        return handleDeepWar(playerA, playerB, warPile, stats, totalTime, handTime, shuffleTime, maxGameTime, depth)
+    if opts.Interactive && opts.Players > 2 {
+        return fmt.Errorf("interactive play supports two players only")
+    }
+    if opts.SaveReplay != "" && opts.ReplayGame == 0 && opts.TranscriptFile == "" && !opts.Interactive && opts.LoadReplay == "" {
+        return fmt.Errorf("-save-replay saves a single game: use it with -replay-game, -transcript, -interactive or -load-replay")
+    }
+    if (opts.SaveReplay != "" || opts.LoadReplay != "") && opts.Players > 2 {
+        return fmt.Errorf("replays support two players only")
+    }
+    if opts.Autoplay < 0 {
+        return fmt.Errorf("autoplay delay must not be negative, got %v", opts.Autoplay)
     }
 
-    if cardA.Rank > cardB.Rank {
-        return WarResult{Winner: 1, PlayerATricks: 1}
+    if opts.MaxTimeSweep != "" {
+        if _, err := parseMaxTimeSweep(opts.MaxTimeSweep); err != nil {
+            return err
+        }
+    }
+    if opts.SeedSweep != "" {
+        if _, err := parseSeedSweep(opts.SeedSweep); err != nil {
+            return err
+        }
+    }
+    if opts.Sweep != "" {
+        name, values, err := parseParameterSweep(opts.Sweep)
+        if err != nil {
+            return err
+        }
+        for _, value := range values {
+            if value < 0 || (name == "maxtime" && value == 0) {
+                return fmt.Errorf("invalid %s %d in sweep", name, value)
+            }
+        }
     }
-    return WarResult{Winner: 2, PlayerBTricks: 1}
+    if opts.Stream {
+        if opts.Format != "csv" {
+            return fmt.Errorf("-stream writes CSV results only")
+        }
+        needGames := []struct {
+            flag string
+            set  bool
+        }{
+            {"-out", opts.Out != ""},
+            {"-append", opts.Append},
+            {"-coordinator", opts.Coordinator != ""},
+            {"-match-wins", opts.MatchWins > 0},
+            {"-determinism-probe", opts.DeterminismProbe},
+            {"-verify", opts.VerifySuits},
+            {"-track-lead", opts.TrackLead},
+            {"-rank-wins", opts.RankWins},
+            {"-fit", opts.Fit},
+            {"-analytic", opts.Analytic},
+            {"-win-rates", opts.WinRates},
+            {"-features", opts.Features != ""},
+            {"-surprise", opts.Surprise > 0},
+            {"-maxtime-sweep", opts.MaxTimeSweep != ""},
+            {"-histogram", opts.Histogram != ""},
+            {"-checksum", opts.Checksum},
+            {"-seed-sweep", opts.SeedSweep != ""},
+            {"-sweep", opts.Sweep != ""},
+        }
+        for _, f := range needGames {
+            if f.set {
+                return fmt.Errorf("%s needs every game, which -stream does not keep", f.flag)
+            }
+        }
+    }
+    if opts.SeedSweep != "" || opts.Sweep != "" {
+        if opts.SeedSweep != "" && opts.Sweep != "" {
+            return fmt.Errorf("-seed-sweep and -sweep cannot be combined")
+        }
+        if opts.OutputFile == "-" || opts.Append || opts.Out != "" || opts.Coordinator != "" || opts.MatchWins > 0 {
+            return fmt.Errorf("sweeps write their own summary file and play single games locally")
+        }
+    }
+    return nil
 }
 
-func timeoutResult(playerA, playerB *Player) WarResult {
-    totalCardsA := len(playerA.DrawPile) + len(playerA.WinningsPile)
-    totalCardsB := len(playerB.DrawPile) + len(playerB.WinningsPile)
-    
-    if totalCardsA > totalCardsB {
-        return WarResult{Winner: 1, PlayerATricks: 1}
-    } else if totalCardsB > totalCardsA {
-        return WarResult{Winner: 2, PlayerBTricks: 1}
-    } else {
-        // In case of a tie, we'll consider it a draw
-        return WarResult{Winner: 0, PlayerATricks: 0, PlayerBTricks: 0}
+// runFileName names an output file of a run after its parameters, such as
+// war_results_hand500_..._maxtime3600000.csv for kind "results", in the
+// output directory.
+func runFileName(kind, extension string, cfg Config) string {
+    filename := fmt.Sprintf("war_%s_hand%d_shuffle%d_jokers%v_seed%d_games%d_maxtime%d", kind, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.Seed, cfg.Games, cfg.MaxGameTime)
+    // The warmup changes which random stream a seed maps to, so it is part of
+    // the run's identity. It is left out when unused to keep existing names.
+    if cfg.RNGWarmup != 0 {
+        filename += fmt.Sprintf("_warmup%d", cfg.RNGWarmup)
     }
+    if cfg.Deck != war.StandardDeck {
+        filename += fmt.Sprintf("_deck%d-%dx%d", cfg.Deck.MinRank, cfg.Deck.MaxRank, cfg.Deck.Copies)
+    }
+    if cfg.MatchWins > 0 {
+        filename += fmt.Sprintf("_match%d", cfg.MatchWins)
+    }
+    // Playing winnings in order changes the games completely, so it is marked.
+    if cfg.NoReshuffle {
+        filename += "_noreshuffle"
+    } else if cfg.ShuffleModel != "uniform" {
+        filename += "_" + cfg.ShuffleModel
+    }
+    return filepath.Join(cfg.OutputDir, filename+"."+extension)
 }
 
-func determineWarWinner(cardsA, cardsB []Card) WarResult {
-    if len(cardsA) == 0 {
-        return WarResult{Winner: 2, PlayerBTricks: 1}
+// resultsFileName is the -output-file, or else the generated name of the
+// results file in -output-dir.
+func resultsFileName(cfg Config) string {
+    if cfg.OutputFile != "" {
+        return cfg.OutputFile
+    }
+    filename := runFileName("results", cfg.Format, cfg)
+    if cfg.Compress {
+        filename += ".gz"
     }
-    return WarResult{Winner: 1, PlayerATricks: 1}
+    return filename
 }
 
-func handleDeepWar(playerA, playerB *Player, warPile []Card, stats *GameStats, totalTime *int, handTime, shuffleTime, maxGameTime, depth int) WarResult {
-    stats.DeepWars++
-    remainingCardsA := len(playerA.DrawPile) + len(playerA.WinningsPile)
-    remainingCardsB := len(playerB.DrawPile) + len(playerB.WinningsPile)
-    
-    if remainingCardsA == 0 {
-        return WarResult{Winner: 2, PlayerBTricks: 1}
-    } else if remainingCardsB == 0 {
-        return WarResult{Winner: 1, PlayerATricks: 1}
+// outputPath places a file named on the command line in -output-dir, unless
+// its path is absolute.
+func outputPath(cfg Config, filename string) string {
+    if filepath.IsAbs(filename) {
+        return filename
     }
-    
-    return handleWar(playerA, playerB, warPile, stats, totalTime, handTime, shuffleTime, maxGameTime, depth+1)
+    return filepath.Join(cfg.OutputDir, filename)
 }
 
-func drawCard(player *Player) (Card, int) {
-    if len(player.DrawPile) == 0 {
-        if len(player.WinningsPile) == 0 {
-            return Card{}, 0
-        }
-        player.DrawPile = player.WinningsPile
-        player.WinningsPile = []Card{}
-        shuffleDeck(player.DrawPile)
-        return player.DrawPile[0], 1
+// createOutputFile creates filename, and any directories leading to it that
+// do not exist yet.
+func createOutputFile(filename string) (*os.File, error) {
+    if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+        return nil, err
     }
-    card := player.DrawPile[0]
-    player.DrawPile = player.DrawPile[1:]
-    return card, 0
+    return os.Create(filename)
 }
 
-func createDeck(includeJokers bool) []Card {
-    deck := make([]Card, 0, 52)
-    for rank := 2; rank <= 14; rank++ { // 11=Jack, 12=Queen, 13=King, 14=Ace
-        for suit := 0; suit < 4; suit++ {
-            deck = append(deck, Card{Rank: rank})
+// writeResultsToFile writes the results to filename in format. With
+// appendRows, the rows of an existing CSV file are added to instead.
+// Closing the file is checked too, since a full disk may only show up then.
+func writeResultsToFile(filename string, stats []war.GameStats, format Format, appendRows bool) error {
+    open, appending := createOutputFile, false
+    if appendRows {
+        if _, err := os.Stat(filename); err == nil {
+            format.RowsOnly, appending = true, true
+            open = func(name string) (*os.File, error) { return os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0) }
         }
     }
-    if includeJokers {
-        deck = append(deck, Card{Rank: 15}, Card{Rank: 15}) // Two jokers
+    file, err := open(filename)
+    if err != nil {
+        return err
+    }
+    if err := WriteResults(file, stats, format); err != nil {
+        file.Close()
+        return err
+    }
+    if err := file.Close(); err != nil {
+        return err
     }
-    return deck
+    if appending {
+        return recountGames(filename, len(stats), false)
+    }
+    return nil
 }
 
-func shuffleDeck(deck []Card) {
-    rand.Shuffle(len(deck), func(i, j int) {
-        deck[i], deck[j] = deck[j], deck[i]
-    })
+// Summary is everything printSummaryStatistics reports about a run, for
+// -summary-file and -serve to write as JSON.
+type Summary struct {
+    Games              int
+    Outcomes           Outcomes
+    Statistics         []Statistic    // Over the games completed without an error
+    DealWinCorrelation float64        // Of Player A's pip advantage with A winning
+    TerminationReasons map[string]int // Games ended for each reason that occurred
 }
-func writeResultsToFile(stats []GameStats, handTime, shuffleTime int, includeJokers bool, seed int64, gamesToPlay, maxGameTime int) {
-    filename := fmt.Sprintf("war_results_hand%d_shuffle%d_jokers%v_seed%d_games%d_maxtime%d.csv", handTime, shuffleTime, includeJokers, seed, gamesToPlay, maxGameTime)
-    file, err := os.Create(filename)
+
+func newSummary(stats []war.GameStats) Summary {
+    return Summary{
+        Games:              len(stats),
+        Outcomes:           countOutcomes(stats),
+        Statistics:         summaryStatistics(stats),
+        DealWinCorrelation: dealWinCorrelation(stats),
+        TerminationReasons: countTerminationReasons(stats),
+    }
+}
+
+// writeSummaryFile writes the summary of the run to filename as JSON.
+func writeSummaryFile(filename string, summary Summary) error {
+    file, err := createOutputFile(filename)
     if err != nil {
-        fmt.Println("Error creating file:", err)
+        return err
+    }
+    encoder := json.NewEncoder(file)
+    encoder.SetIndent("", "  ")
+    if err := encoder.Encode(summary); err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+func printSummaryStatistics(w io.Writer, stats []war.GameStats) {
+    printSummary(w, newSummary(stats))
+}
+
+func printSummary(w io.Writer, summary Summary) {
+    fmt.Fprintf(w, "Total number of games played: %d\n", summary.Games)
+    completed := summary.Games - summary.Outcomes.Errors
+    if completed == 0 {
+        fmt.Fprintln(w, "No completed games to summarize.")
+        if summary.Games > 0 {
+            fmt.Fprintf(w, "Errors: %d (100.00%%)\n", summary.Games)
+        }
         return
     }
-    defer file.Close()
+    if summary.Outcomes.Errors > 0 {
+        fmt.Fprintf(w, "Errored games (left out of the statistics below): %d\n", summary.Outcomes.Errors)
+    }
+
+    for _, statistic := range summary.Statistics {
+        printStatistic(w, statistic)
+    }
+    fmt.Fprintf(w, "Correlation of A's pip advantage with A winning: %.3f\n", summary.DealWinCorrelation)
+
+    outcomes := summary.Outcomes
+    fmt.Fprintf(w, "Finished games: %d (%.2f%%)\n", outcomes.Finished(), outcomes.Percent(outcomes.Finished()))
+    playedOut := outcomes.Finished() - outcomes.TimedOut
+    fmt.Fprintf(w, "  Played out: %d (%.2f%%)\n", playedOut, outcomes.Percent(playedOut))
+    fmt.Fprintf(w, "  Decided at the time limit: %d (%.2f%%): Player A %d, Player B %d\n",
+        outcomes.TimedOut, outcomes.Percent(outcomes.TimedOut), outcomes.TimedOutWinsA, outcomes.TimedOutWinsB)
+
+    // Every game falls in exactly one of these, so the percentages sum to 100.
+    fmt.Fprintf(w, "Player A Total Wins: %d (%.2f%%)\n", outcomes.WinsA, outcomes.Percent(outcomes.WinsA))
+    fmt.Fprintf(w, "Player B Total Wins: %d (%.2f%%)\n", outcomes.WinsB, outcomes.Percent(outcomes.WinsB))
+    if outcomes.WinsOther > 0 {
+        fmt.Fprintf(w, "Other Players' Wins: %d (%.2f%%)\n", outcomes.WinsOther, outcomes.Percent(outcomes.WinsOther))
+    }
+    fmt.Fprintf(w, "Draws: %d (%.2f%%)\n", outcomes.Draws, outcomes.Percent(outcomes.Draws))
+    fmt.Fprintf(w, "Unfinished: %d (%.2f%%)\n", outcomes.Unfinished, outcomes.Percent(outcomes.Unfinished))
+    fmt.Fprintf(w, "Errors: %d (%.2f%%)\n", outcomes.Errors, outcomes.Percent(outcomes.Errors))
+
+    fmt.Fprintln(w, "Termination reasons:")
+    reasons := summary.TerminationReasons
+    for _, reason := range terminationReasons {
+        if reasons[reason] > 0 {
+            fmt.Fprintf(w, "  %s: %d (%.2f%%)\n", reason, reasons[reason], outcomes.Percent(reasons[reason]))
+        }
+    }
+}
 
-    writer := csv.NewWriter(file)
-    defer writer.Flush()
+// completedGames returns the games that were played without an error.
+func completedGames(stats []war.GameStats) []war.GameStats {
+    completed := make([]war.GameStats, 0, len(stats))
+    for _, game := range stats {
+        if !game.Errored {
+            completed = append(completed, game)
+        }
+    }
+    return completed
+}
 
-    headers := []string{"Game Number", "Tricks", "Wars", "Deep Wars", "Shuffles A", "Shuffles B", "Game Duration (ms)", "Finished", "Player A Tricks", "Player B Tricks", "Winner"}
-    writer.Write(headers)
+// terminationReasons lists every GameStats.TerminationReason in report order.
+var terminationReasons = []string{"exhaustion", "simultaneous", "draw", "timeout", "trick-limit", "cycle", "early-draw", "error"}
 
+func countTerminationReasons(stats []war.GameStats) map[string]int {
+    counts := make(map[string]int)
     for _, game := range stats {
-        row := []string{
-            strconv.Itoa(game.GameNumber),
-            strconv.Itoa(game.Tricks),
-            strconv.Itoa(game.Wars),
-            strconv.Itoa(game.DeepWars),
-            strconv.Itoa(game.ShufflesA),
-            strconv.Itoa(game.ShufflesB),
-            strconv.FormatInt(game.GameDuration.Milliseconds(), 10),
-            strconv.FormatBool(game.Finished),
-            strconv.Itoa(game.PlayerATricks),
-            strconv.Itoa(game.PlayerBTricks),
-            strconv.Itoa(game.Winner),
-        }
-        writer.Write(row)
-    }
-}
-
-func printSummaryStatistics(stats []GameStats) {
-    fmt.Printf("Total number of games played: %d\n", len(stats))
-
-	tricks := make([]float64, len(stats))
-    wars := make([]float64, len(stats))
-    deepWars := make([]float64, len(stats))
-    avgWarDepths := make([]float64, len(stats))
-    shufflesA := make([]float64, len(stats))
-    shufflesB := make([]float64, len(stats))
-    gameTimes := make([]float64, len(stats))
-    playerATricks := make([]float64, len(stats))
-    playerBTricks := make([]float64, len(stats))
-    finishedGames := 0
-    playerATotalWins := 0
-    playerBTotalWins := 0
-
-    for i, game := range stats {
-        tricks[i] = float64(game.Tricks)
-        wars[i] = float64(game.Wars)
-        deepWars[i] = float64(game.DeepWars)
-        if game.Wars > 0 {
-            avgWarDepths[i] = float64(game.TotalWarDepth) / float64(game.Wars)
-        }
-        shufflesA[i] = float64(game.ShufflesA)
-        shufflesB[i] = float64(game.ShufflesB)
-        gameTimes[i] = float64(game.GameDuration.Minutes())
-        playerATricks[i] = float64(game.PlayerATricks)
-        playerBTricks[i] = float64(game.PlayerBTricks)
-        if game.Finished {
-            finishedGames++
-            if game.Winner == 1 {
-                playerATotalWins++
-            } else if game.Winner == 2 {
-                playerBTotalWins++
+        counts[game.TerminationReason]++
+    }
+    return counts
+}
+
+// Statistic summarizes one per-game metric across a run.
+type Statistic struct {
+    Name      string
+    Avg       float64
+    Min       float64
+    Max       float64
+    StdDev    float64
+    Median    float64
+    P90       float64
+    P99       float64
+    Precision int // Decimal places used to print Min and Max
+}
+
+func newStatistic(name string, data []float64, precision int) Statistic {
+    var acc Accumulator
+    for _, v := range data {
+        acc.Add(v)
+    }
+    sorted := append([]float64(nil), data...)
+    sort.Float64s(sorted)
+    return newStatisticFrom(name, acc, sorted, precision)
+}
+
+// newStatisticFrom makes a Statistic from the accumulated values and sorted,
+// all of them or a sample for the percentiles.
+func newStatisticFrom(name string, acc Accumulator, sorted []float64, precision int) Statistic {
+    return Statistic{
+        Name:      name,
+        Avg:       acc.Mean(),
+        Min:       acc.Min(),
+        Max:       acc.Max(),
+        StdDev:    acc.StdDev(),
+        Median:    percentile(sorted, 50),
+        P90:       percentile(sorted, 90),
+        P99:       percentile(sorted, 99),
+        Precision: precision,
+    }
+}
+
+// summaryStatistics computes every per-game metric reported for a run, in
+// report order, over the games that completed without an error.
+func summaryStatistics(stats []war.GameStats) []Statistic {
+    stats = completedGames(stats)
+    statistics := make([]Statistic, len(summaryMetrics))
+    for i, metric := range summaryMetrics {
+        data := make([]float64, 0, len(stats))
+        for _, game := range stats {
+            if v, ok := metric.Value(game); ok {
+                data = append(data, v)
+            }
+        }
+        statistics[i] = newStatistic(metric.Name, data, metric.Precision)
+    }
+    return statistics
+}
+
+// summaryMetric is a per-game metric of the summary statistics. Value
+// reports false for a game the metric leaves out.
+type summaryMetric struct {
+    Name      string
+    Precision int
+    Value     func(war.GameStats) (float64, bool)
+}
+
+// summaryMetrics lists the metrics of the summary statistics in report order.
+var summaryMetrics = []summaryMetric{
+    {"Tricks", 0, func(g war.GameStats) (float64, bool) { return float64(g.Tricks), true }},
+    {"Wars", 0, func(g war.GameStats) (float64, bool) { return float64(g.Wars), true }},
+    {"Deep Wars", 0, func(g war.GameStats) (float64, bool) { return float64(g.DeepWars), true }},
+    {"Average War Depth", 0, func(g war.GameStats) (float64, bool) {
+        if g.Wars == 0 {
+            return 0, true
+        }
+        return float64(g.TotalWarDepth) / float64(g.Wars), true
+    }},
+    {"Longest War (rounds)", 0, func(g war.GameStats) (float64, bool) { return float64(g.MaxWarDepth), true }},
+    {"Shuffles A", 0, func(g war.GameStats) (float64, bool) { return float64(g.ShufflesA), true }},
+    {"Shuffles B", 0, func(g war.GameStats) (float64, bool) { return float64(g.ShufflesB), true }},
+    {"Player A Tricks (per game)", 0, func(g war.GameStats) (float64, bool) { return float64(g.PlayerATricks), true }},
+    {"Player B Tricks (per game)", 0, func(g war.GameStats) (float64, bool) { return float64(g.PlayerBTricks), true }},
+    {"Deal Balance (pip difference)", 0, func(g war.GameStats) (float64, bool) { return float64(g.DealBalance()), true }},
+    {"Winner Margin (cards, games with a winner)", 0, func(g war.GameStats) (float64, bool) { return float64(g.WinnerMargin), g.Winner != 0 }},
+    {"Game Time (minutes)", 2, func(g war.GameStats) (float64, bool) { return g.GameDuration.Minutes(), true }},
+}
+
+// dealWinCorrelation correlates Player A's starting pip advantage with A
+// winning, over the games that one of the players won.
+func dealWinCorrelation(stats []war.GameStats) float64 {
+    var pipDiffs, winsA []float64
+    for _, game := range stats {
+        if game.Finished && (game.Winner == 1 || game.Winner == 2) {
+            pipDiffs = append(pipDiffs, float64(game.PipsA-game.PipsB))
+            winsA = append(winsA, float64(2-game.Winner))
+        }
+    }
+    return correlation(pipDiffs, winsA)
+}
+
+// Outcomes splits a run's games into mutually exclusive outcome categories.
+type Outcomes struct {
+    Games      int
+    WinsA      int
+    WinsB      int
+    WinsOther  int // Wins by the third and later players (-players only)
+    Draws      int // Finished games without a winner
+    Unfinished int // Games stopped without a result
+    Errors     int // Games that panicked
+
+    // Of the finished games, those decided on the cards held when the time
+    // limit stopped them rather than played out, and the wins among them.
+    TimedOut      int
+    TimedOutWinsA int
+    TimedOutWinsB int
+}
+
+func countOutcomes(stats []war.GameStats) Outcomes {
+    outcomes := Outcomes{Games: len(stats)}
+    for _, game := range stats {
+        switch {
+        case game.Errored:
+            outcomes.Errors++
+        case !game.Finished:
+            outcomes.Unfinished++
+        case game.Winner == 1:
+            outcomes.WinsA++
+        case game.Winner == 2:
+            outcomes.WinsB++
+        case game.Winner > 2:
+            outcomes.WinsOther++
+        default:
+            outcomes.Draws++
+        }
+        if game.Finished && game.TerminationReason == "timeout" {
+            outcomes.TimedOut++
+            switch game.Winner {
+            case 1:
+                outcomes.TimedOutWinsA++
+            case 2:
+                outcomes.TimedOutWinsB++
             }
         }
     }
+    return outcomes
+}
+
+// Finished is the number of games that reached a result, draws included.
+func (o Outcomes) Finished() int {
+    return o.WinsA + o.WinsB + o.WinsOther + o.Draws
+}
+
+// Percent expresses count as a percentage of all games played.
+func (o Outcomes) Percent(count int) float64 {
+    if o.Games == 0 {
+        return 0
+    }
+    return float64(count) / float64(o.Games) * 100
+}
+
+// printDeterminismProbe reports how often a deal's winner is unchanged when
+// the same deal is replayed without reshuffling the winnings pile.
+func printDeterminismProbe(w io.Writer, stats []war.GameStats) {
+    compared, agreed := 0, 0
+    for _, game := range stats {
+        if game.Errored || game.Winner == 0 || game.NoReshuffleWinner == 0 {
+            continue
+        }
+        compared++
+        if game.Winner == game.NoReshuffleWinner {
+            agreed++
+        }
+    }
+    if compared == 0 {
+        fmt.Fprintln(w, "Determinism probe: no games with a winner in both runs")
+        return
+    }
+    fmt.Fprintf(w, "Determinism probe: winners agree in %d of %d games (%.2f%%)\n", agreed, compared, float64(agreed)/float64(compared)*100)
+}
 
-    printStatistic("Tricks", tricks)
-    printStatistic("Wars", wars)
-    printStatistic("Deep Wars", deepWars)
-    printStatistic("Average War Depth", avgWarDepths)
-    printStatistic("Shuffles A", shufflesA)
-    printStatistic("Shuffles B", shufflesB)
-    printStatistic("Player A Tricks (per game)", playerATricks)
-    printStatistic("Player B Tricks (per game)", playerBTricks)
-    
-    avgGameTime := average(gameTimes)
-    minGameTime, maxGameTime := minMax(gameTimes)
-    stdDevGameTime := standardDeviation(gameTimes, avgGameTime)
-    
-    fmt.Printf("Game Time (minutes): Avg %.2f (Min: %.2f, Max: %.2f, StdDev: %.2f)\n", 
-               avgGameTime, minGameTime, maxGameTime, stdDevGameTime)
-    fmt.Printf("Finished games: %d (%.2f%%)\n", finishedGames, float64(finishedGames)/float64(len(stats))*100)
-    fmt.Printf("Player A Total Wins: %d (%.2f%%)\n", playerATotalWins, float64(playerATotalWins)/float64(finishedGames)*100)
-    fmt.Printf("Player B Total Wins: %d (%.2f%%)\n", playerBTotalWins, float64(playerBTotalWins)/float64(finishedGames)*100)
+// checkSuitBlindness reports whether every game had the same winner and trick
+// count when replayed with the suits permuted within each rank, as it must
+// when play compares ranks only. It fails on the first game that did not.
+func checkSuitBlindness(w io.Writer, stats []war.GameStats) error {
+    checked := 0
+    for _, game := range stats {
+        if game.Errored {
+            continue
+        }
+        if game.Winner != game.PermutedWinner || game.Tricks != game.PermutedTricks {
+            return fmt.Errorf("suit verification failed: game %d had winner %d in %d tricks, but winner %d in %d tricks with the suits permuted",
+                game.GameNumber, game.Winner, game.Tricks, game.PermutedWinner, game.PermutedTricks)
+        }
+        checked++
+    }
+    fmt.Fprintf(w, "Suit verification: all %d games played the same with the suits permuted\n", checked)
+    return nil
 }
 
-func printStatistic(name string, data []float64) {
-    avg := average(data)
-    min, max := minMax(data)
-    stdDev := standardDeviation(data, avg)
+// printComebacks prints how often the lead changed hands and how often the
+// winner came back from behind.
+func printComebacks(w io.Writer, stats []war.GameStats) {
+    var leadChanges []float64
+    won, comebacks := 0, 0
+    for _, game := range completedGames(stats) {
+        leadChanges = append(leadChanges, float64(game.LeadChanges))
+        if game.Winner != 0 {
+            won++
+            if game.WinnerWasBehind {
+                comebacks++
+            }
+        }
+    }
+    if len(leadChanges) == 0 {
+        fmt.Fprintln(w, "Comebacks: no games to report")
+        return
+    }
+    printStatistic(w, newStatistic("Lead Changes", leadChanges, 0))
+    if won > 0 {
+        fmt.Fprintf(w, "Comebacks: the winner was behind at some point in %d of %d games won (%.2f%%)\n", comebacks, won, float64(comebacks)/float64(won)*100)
+    }
+}
+
+// printRankWins prints how many tricks, wars included, each rank decided
+// over the run, and its share of all the tricks decided by a card.
+func printRankWins(w io.Writer, stats []war.GameStats) {
+    var counts [16]int
+    total := 0
+    for _, game := range completedGames(stats) {
+        for rank, n := range game.RankWins {
+            counts[rank] += n
+            total += n
+        }
+    }
+    if total == 0 {
+        fmt.Fprintln(w, "Tricks by deciding rank: no tricks to report")
+        return
+    }
+    fmt.Fprintln(w, "Tricks by deciding rank:")
+    for rank, n := range counts {
+        if n > 0 {
+            fmt.Fprintf(w, "%6s %10d %7.2f%%\n", war.RankName(rank), n, float64(n)/float64(total)*100)
+        }
+    }
+}
 
-    fmt.Printf("%s: Avg %.2f (Min: %.0f, Max: %.0f, StdDev: %.2f)\n", name, avg, min, max, stdDev)
+func printStatistic(w io.Writer, s Statistic) {
+    fmt.Fprintf(w, "%s: Avg %.2f (Min: %.*f, Max: %.*f, StdDev: %.2f, Median: %.2f, P90: %.2f, P99: %.2f)\n", s.Name, s.Avg, s.Precision, s.Min, s.Precision, s.Max, s.StdDev, s.Median, s.P90, s.P99)
 }
 
 func average(data []float64) float64 {
+    if len(data) == 0 {
+        return 0
+    }
     sum := 0.0
     for _, v := range data {
         sum += v
@@ -392,6 +1286,9 @@ func average(data []float64) float64 {
 }
 
 func minMax(data []float64) (float64, float64) {
+    if len(data) == 0 {
+        return 0, 0
+    }
     min, max := data[0], data[0]
     for _, v := range data[1:] {
         if v < min {
@@ -404,11 +1301,35 @@ func minMax(data []float64) (float64, float64) {
     return min, max
 }
 
-func standardDeviation(data []float64, mean float64) float64 {
-    sum := 0.0
-    for _, v := range data {
-        sum += math.Pow(v-mean, 2)
+// percentile returns the pth percentile (0 to 100) of sorted, which must be in
+// ascending order, interpolating linearly between the two nearest values.
+func percentile(sorted []float64, p float64) float64 {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := p / 100 * float64(len(sorted)-1)
+    lower := int(math.Floor(rank))
+    if lower >= len(sorted)-1 {
+        return sorted[len(sorted)-1]
+    }
+    return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// correlation is the Pearson correlation of x and y, or 0 if either is constant.
+func correlation(x, y []float64) float64 {
+    if len(x) < 2 {
+        return 0
+    }
+    meanX, meanY := average(x), average(y)
+    var sxy, sxx, syy float64
+    for i := range x {
+        dx, dy := x[i]-meanX, y[i]-meanY
+        sxy += dx * dy
+        sxx += dx * dx
+        syy += dy * dy
     }
-    variance := sum / float64(len(data))
-    return math.Sqrt(variance)
-}
\ No newline at end of file
+    if sxx == 0 || syy == 0 {
+        return 0
+    }
+    return sxy / math.Sqrt(sxx*syy)
+}
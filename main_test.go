@@ -0,0 +1,720 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "context"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "reflect"
+    "runtime"
+    "slices"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "wargames/war"
+)
+
+// TestMain runs the command itself, instead of the tests, in the child
+// processes runMain starts.
+func TestMain(m *testing.M) {
+    if args, ok := os.LookupEnv("WARGAMES_MAIN_ARGS"); ok {
+        os.Args = append([]string{"wargames"}, strings.Split(args, "\n")...)
+        main()
+        os.Exit(0)
+    }
+    os.Exit(m.Run())
+}
+
+// runMain runs the command with args in a child process, in dir, and returns
+// what it wrote to standard output and standard error. err is set if it
+// exited with an error.
+func runMain(t *testing.T, dir string, args ...string) (stdout, stderr string, err error) {
+    t.Helper()
+    cmd := exec.Command(os.Args[0])
+    cmd.Dir = dir
+    cmd.Env = append(os.Environ(), "WARGAMES_MAIN_ARGS="+strings.Join(args, "\n"))
+    var out, errOut bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &out, &errOut
+    err = cmd.Run()
+    return out.String(), errOut.String(), err
+}
+
+// interruptMain runs the command with args as runMain does, and interrupts
+// it at the first line of its standard error that ready accepts. Those lines
+// should be ones it writes only once it handles interrupts, such as its
+// -progress reports. The command must exit without an error.
+func interruptMain(t *testing.T, dir string, ready func(line string) bool, args ...string) (stdout, stderr string) {
+    t.Helper()
+    if runtime.GOOS == "windows" {
+        t.Skip("an interrupt cannot be sent to a process on Windows")
+    }
+    cmd := exec.Command(os.Args[0])
+    cmd.Dir = dir
+    cmd.Env = append(os.Environ(), "WARGAMES_MAIN_ARGS="+strings.Join(args, "\n"))
+    var out, errOut bytes.Buffer
+    cmd.Stdout = &out
+    pipe, err := cmd.StderrPipe()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := cmd.Start(); err != nil {
+        t.Fatal(err)
+    }
+    interrupted := false
+    scanner := bufio.NewScanner(pipe)
+    for scanner.Scan() {
+        fmt.Fprintln(&errOut, scanner.Text())
+        if !interrupted && ready(scanner.Text()) {
+            if err := cmd.Process.Signal(os.Interrupt); err != nil {
+                t.Fatal(err)
+            }
+            interrupted = true
+        }
+    }
+    if err := cmd.Wait(); err != nil {
+        t.Fatalf("interrupted run: %v\n%s", err, errOut.String())
+    }
+    if !interrupted {
+        t.Fatalf("run was never ready to interrupt:\n%s", errOut.String())
+    }
+    return out.String(), errOut.String()
+}
+
+// gamesPlayed accepts a -progress report of at least one game played.
+func gamesPlayed(line string) bool {
+    var done int
+    _, err := fmt.Sscanf(line, "Progress: %d/", &done)
+    return err == nil && done > 0
+}
+
+func TestDeterminismProbeAgreement(t *testing.T) {
+    stats := []war.GameStats{
+        {Winner: 1, NoReshuffleWinner: 1},
+        {Winner: 2, NoReshuffleWinner: 1},
+        {Winner: 2, NoReshuffleWinner: 2},
+        {Winner: 1, NoReshuffleWinner: 1},
+        {Winner: 0, NoReshuffleWinner: 1}, // A draw has no winner to compare
+        {Winner: 1, NoReshuffleWinner: 0}, // Nor does an unfinished counterpart
+        {Winner: 1, NoReshuffleWinner: 1, Errored: true},
+    }
+    var buf bytes.Buffer
+    printDeterminismProbe(&buf, stats)
+    if want := "winners agree in 3 of 4 games (75.00%)"; !strings.Contains(buf.String(), want) {
+        t.Errorf("probe report %q does not say %q", buf.String(), want)
+    }
+
+    buf.Reset()
+    printDeterminismProbe(&buf, nil)
+    if !strings.Contains(buf.String(), "no games with a winner in both runs") {
+        t.Errorf("empty probe report %q", buf.String())
+    }
+}
+
+func TestDeterminismProbeRun(t *testing.T) {
+    opts := war.DefaultOptions()
+    opts.DeterminismProbe = true
+    stats := war.RunSimulations(20, 500, 15000, false, 3600000, 3, 2, opts)
+    // A counterpart can cycle without a winner, but not all of them do.
+    counterparts := 0
+    for _, game := range stats {
+        if game.NoReshuffleWinner != 0 {
+            counterparts++
+        }
+    }
+    if counterparts == 0 {
+        t.Error("no game recorded a counterpart winner")
+    }
+    var buf bytes.Buffer
+    printDeterminismProbe(&buf, stats)
+    if !strings.Contains(buf.String(), "Determinism probe: winners agree in") {
+        t.Errorf("probe report %q", buf.String())
+    }
+}
+
+// runGames plays a small run with the default rules and seed 1.
+func runGames(t *testing.T, games int) []war.GameStats {
+    t.Helper()
+    return war.RunSimulations(games, 500, 15000, false, 3600000, 1, 2, war.DefaultOptions())
+}
+
+// testConfig returns a valid configuration of a small run with the default
+// rules and seed 1, as parseArgs would give it.
+func testConfig() Config {
+    return Config{
+        HandTime:    500,
+        ShuffleTime: 15000,
+        Seed:        1,
+        Games:       10,
+        MaxGameTime: 3600000,
+        Options: Options{
+            Options:       war.DefaultOptions(),
+            Columns:       "standard",
+            Format:        "csv",
+            Workers:       2,
+            MatchDeal:     "redeal",
+            HistogramBins: 20,
+        },
+    }
+}
+
+func TestTestConfigIsValid(t *testing.T) {
+    if err := validateConfig(testConfig()); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestOutcomePercentages(t *testing.T) {
+    stats := []war.GameStats{
+        {Finished: true, Winner: 1, TerminationReason: "exhaustion"},
+        {Finished: true, Winner: 1, TerminationReason: "exhaustion"},
+        {Finished: true, Winner: 1, TerminationReason: "timeout"},
+        {Finished: true, Winner: 2, TerminationReason: "exhaustion"},
+        {Finished: true, Winner: 2, TerminationReason: "timeout"},
+        {Finished: true, TerminationReason: "draw"},
+        {Finished: true, TerminationReason: "timeout"},
+        {TerminationReason: "trick-limit"},
+        {Tricks: -1, TerminationReason: "error", Errored: true},
+        {Tricks: -1, TerminationReason: "error", Errored: true},
+    }
+    outcomes := countOutcomes(stats)
+    want := Outcomes{Games: 10, WinsA: 3, WinsB: 2, Draws: 2, Unfinished: 1, Errors: 2, TimedOut: 3, TimedOutWinsA: 1, TimedOutWinsB: 1}
+    if outcomes != want {
+        t.Errorf("countOutcomes = %+v, want %+v", outcomes, want)
+    }
+    total := 0.0
+    for _, count := range []int{outcomes.WinsA, outcomes.WinsB, outcomes.WinsOther, outcomes.Draws, outcomes.Unfinished, outcomes.Errors} {
+        total += outcomes.Percent(count)
+    }
+    if total != 100 {
+        t.Errorf("outcome percentages sum to %g, want 100", total)
+    }
+
+    var buf bytes.Buffer
+    printSummaryStatistics(&buf, stats)
+    for _, line := range []string{
+        "Player A Total Wins: 3 (30.00%)",
+        "Player B Total Wins: 2 (20.00%)",
+        "Draws: 2 (20.00%)",
+        "Unfinished: 1 (10.00%)",
+        "Errors: 2 (20.00%)",
+        "Errored games (left out of the statistics below): 2",
+    } {
+        if !strings.Contains(buf.String(), line+"\n") {
+            t.Errorf("summary lacks %q:\n%s", line, buf.String())
+        }
+    }
+}
+
+// A game won by a third or later player, whose Winner is their seat, counts
+// as another player's win.
+func TestOtherPlayersWins(t *testing.T) {
+    opts := war.DefaultOptions()
+    opts.Players = 3
+    stats := war.RunSimulations(200, 500, 15000, false, 3600000, 1, 2, opts)
+    others := 0
+    for _, game := range stats {
+        if game.Winner > 2 {
+            others++
+        }
+    }
+    outcomes := countOutcomes(stats)
+    if others == 0 || outcomes.WinsOther != others {
+        t.Errorf("%d games won by Player C counted as %d other players' wins", others, outcomes.WinsOther)
+    }
+    var buf bytes.Buffer
+    printSummaryStatistics(&buf, stats)
+    if line := fmt.Sprintf("Other Players' Wins: %d (", others); !strings.Contains(buf.String(), line) {
+        t.Errorf("summary lacks %q:\n%s", line, buf.String())
+    }
+}
+
+func TestDealWinCorrelation(t *testing.T) {
+    // A always wins with more pips, so the correlation is positive; games
+    // without a winner are left out.
+    stats := []war.GameStats{
+        {Finished: true, Winner: 1, PipsA: 220, PipsB: 196},
+        {Finished: true, Winner: 1, PipsA: 210, PipsB: 206},
+        {Finished: true, Winner: 2, PipsA: 200, PipsB: 216},
+        {Finished: true, Winner: 2, PipsA: 190, PipsB: 226},
+        {Finished: true, Winner: 0, PipsA: 100, PipsB: 316},
+    }
+    if r := dealWinCorrelation(stats); r < 0.8 || r > 1 {
+        t.Errorf("correlation %.3f, want strongly positive", r)
+    }
+}
+
+func TestCheckSuitBlindness(t *testing.T) {
+    stats := []war.GameStats{
+        {GameNumber: 1, Winner: 1, Tricks: 300, PermutedWinner: 1, PermutedTricks: 300},
+        {GameNumber: 2, Tricks: -1, Errored: true},
+        {GameNumber: 3, Winner: 2, Tricks: 120, PermutedWinner: 2, PermutedTricks: 120},
+    }
+    var buf bytes.Buffer
+    if err := checkSuitBlindness(&buf, stats); err != nil {
+        t.Fatal(err)
+    }
+    if want := "all 2 games played the same"; !strings.Contains(buf.String(), want) {
+        t.Errorf("report %q does not say %q", buf.String(), want)
+    }
+
+    stats = append(stats, war.GameStats{GameNumber: 4, Winner: 1, Tricks: 90, PermutedWinner: 1, PermutedTricks: 94})
+    if err := checkSuitBlindness(&buf, stats); err == nil || !strings.Contains(err.Error(), "game 4") {
+        t.Errorf("a changed trick count gave %v", err)
+    }
+}
+
+func TestPercentile(t *testing.T) {
+    sorted := []float64{10, 20, 30, 40, 50}
+    for _, tt := range []struct {
+        p, want float64
+    }{
+        {0, 10}, {25, 20}, {50, 30}, {60, 34}, {90, 46}, {99, 49.6}, {100, 50},
+    } {
+        if got := percentile(sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+            t.Errorf("percentile(%v, %g) = %g, want %g", sorted, tt.p, got, tt.want)
+        }
+    }
+    if got := percentile([]float64{7}, 90); got != 7 {
+        t.Errorf("percentile of a single value = %g, want 7", got)
+    }
+    if got := percentile(nil, 50); got != 0 {
+        t.Errorf("percentile of no values = %g, want 0", got)
+    }
+}
+
+// The statistics sort a copy of the data, so their percentiles do not depend
+// on its order, and go into the printed and the JSON summaries.
+func TestStatisticPercentiles(t *testing.T) {
+    data := []float64{50, 10, 40, 20, 30}
+    s := newStatistic("Tricks", data, 0)
+    if s.Median != 30 || s.P90 != 46 || math.Abs(s.P99-49.6) > 1e-9 {
+        t.Errorf("median %g, p90 %g, p99 %g, want 30, 46 and 49.6", s.Median, s.P90, s.P99)
+    }
+    if data[0] != 50 {
+        t.Error("newStatistic sorted the data in place")
+    }
+
+    var buf bytes.Buffer
+    printStatistic(&buf, s)
+    if want := "Median: 30.00, P90: 46.00, P99: 49.60"; !strings.Contains(buf.String(), want) {
+        t.Errorf("printed %q, want it to include %q", buf.String(), want)
+    }
+    filename := filepath.Join(t.TempDir(), "summary.json")
+    if err := writeSummaryFile(filename, newSummary(runGames(t, 10))); err != nil {
+        t.Fatal(err)
+    }
+    written, _ := os.ReadFile(filename)
+    for _, field := range []string{`"Median":`, `"P90":`, `"P99":`} {
+        if !strings.Contains(string(written), field) {
+            t.Errorf("JSON summary lacks %s", field)
+        }
+    }
+}
+
+func TestSummaryOfNoCompletedGames(t *testing.T) {
+    for _, tt := range []struct {
+        name  string
+        stats []war.GameStats
+        lines []string
+    }{
+        {"no games", nil, []string{"Total number of games played: 0", "No completed games to summarize."}},
+        {"all errored", []war.GameStats{
+            {GameNumber: 1, Tricks: -1, TerminationReason: "error", Errored: true},
+            {GameNumber: 2, Tricks: -1, TerminationReason: "error", Errored: true},
+        }, []string{"Total number of games played: 2", "No completed games to summarize.", "Errors: 2 (100.00%)"}},
+    } {
+        var buf bytes.Buffer
+        printSummaryStatistics(&buf, tt.stats)
+        for _, line := range tt.lines {
+            if !strings.Contains(buf.String(), line+"\n") {
+                t.Errorf("%s: summary lacks %q:\n%s", tt.name, line, buf.String())
+            }
+        }
+        if strings.Contains(buf.String(), "NaN") {
+            t.Errorf("%s: summary has NaN:\n%s", tt.name, buf.String())
+        }
+    }
+    if lo, hi := minMax(nil); lo != 0 || hi != 0 {
+        t.Errorf("minMax(nil) = %g, %g", lo, hi)
+    }
+    if avg := average(nil); avg != 0 {
+        t.Errorf("average(nil) = %g", avg)
+    }
+}
+
+func TestErroredGamesAreLeftOutOfTheStatistics(t *testing.T) {
+    stats := []war.GameStats{
+        {GameNumber: 1, Finished: true, Winner: 1, Tricks: 100, TerminationReason: "exhaustion"},
+        {GameNumber: 2, Tricks: -1, TerminationReason: "error", Errored: true},
+        {GameNumber: 3, Finished: true, Winner: 2, Tricks: 300, TerminationReason: "exhaustion"},
+        {GameNumber: 4, Tricks: -1, TerminationReason: "error", Errored: true},
+    }
+    var buf bytes.Buffer
+    printSummaryStatistics(&buf, stats)
+    for _, line := range []string{
+        "Errored games (left out of the statistics below): 2",
+        "Tricks: Avg 200.00 (Min: 100, Max: 300,",
+    } {
+        if !strings.Contains(buf.String(), line) {
+            t.Errorf("summary lacks %q:\n%s", line, buf.String())
+        }
+    }
+}
+
+func TestQuietAndSilent(t *testing.T) {
+    for _, tt := range []struct {
+        flag     string
+        progress bool
+        summary  bool
+    }{
+        {"-quiet=false", true, true},
+        {"-quiet", false, true},
+        {"-silent", false, false},
+    } {
+        dir := t.TempDir()
+        stdout, stderr, err := runMain(t, dir, tt.flag, "-games", "5", "-seed", "3")
+        if err != nil {
+            t.Fatalf("%s: %v\n%s", tt.flag, err, stderr)
+        }
+        if got := strings.Contains(stdout, "Starting simulation"); got != tt.progress {
+            t.Errorf("%s: progress messages printed: %v, want %v\n%s", tt.flag, got, tt.progress, stdout)
+        }
+        if got := strings.Contains(stdout, "Total number of games played: 5"); got != tt.summary {
+            t.Errorf("%s: summary printed: %v, want %v\n%s", tt.flag, got, tt.summary, stdout)
+        }
+        if tt.flag == "-silent" && stdout != "" {
+            t.Errorf("-silent printed %q", stdout)
+        }
+        if files, _ := filepath.Glob(filepath.Join(dir, "war_results_*.csv")); len(files) != 1 {
+            t.Errorf("%s: wrote results files %v, want one", tt.flag, files)
+        }
+    }
+
+    // Errors still go to standard error.
+    stdout, stderr, err := runMain(t, t.TempDir(), "-silent", "-games", "-1")
+    if err == nil || stdout != "" || !strings.Contains(stderr, "Error: ") {
+        t.Errorf("-silent with an invalid flag exited with %v, printed %q and reported %q", err, stdout, stderr)
+    }
+}
+
+// A run is played from its Config alone: every parameter in it reaches the
+// games, and the same Config plays the same games.
+func TestGameFromConfig(t *testing.T) {
+    cfg := testConfig()
+    cfg.Games = 1
+    cfg.Seed = 5
+    cfg.HandTime = 1000
+    cfg.IncludeJokers = true
+    cfg.Variant = "count-tricks"
+    if err := validateConfig(cfg); err != nil {
+        t.Fatal(err)
+    }
+    stats, _, err := playRun(context.Background(), cfg, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(stats) != 1 {
+        t.Fatalf("a one-game Config played %d games", len(stats))
+    }
+    game := stats[0]
+    if game.GameNumber != 1 || game.Seed != war.GameSeed(5, 1) {
+        t.Errorf("game %d was seeded %d, want game 1 seeded %d", game.GameNumber, game.Seed, war.GameSeed(5, 1))
+    }
+    if game.JokerTricks == 0 {
+        t.Error("no joker was played in a count-tricks game with jokers, which plays every card")
+    }
+    if game.TerminationReason != "exhaustion" {
+        t.Errorf("count-tricks game ended %s, want exhaustion", game.TerminationReason)
+    }
+    if want := time.Duration(game.Tricks*1000) * time.Millisecond; game.GameDuration < want {
+        t.Errorf("game of %d tricks took %v, want at least %v at -hand 1000", game.Tricks, game.GameDuration, want)
+    }
+
+    again, _, _ := playRun(context.Background(), cfg, 0)
+    if !reflect.DeepEqual(again, stats) {
+        t.Error("the same Config played a different game")
+    }
+}
+
+func TestValidateConfig(t *testing.T) {
+    tests := []struct {
+        name   string
+        change func(*Config)
+        want   string
+    }{
+        {"negative hand time", func(c *Config) { c.HandTime = -1 }, "hand time must not be negative, got -1"},
+        {"negative shuffle time", func(c *Config) { c.ShuffleTime = -5 }, "shuffle time must not be negative, got -5"},
+        {"no games", func(c *Config) { c.Games = 0 }, "games must be at least 1, got 0"},
+        {"negative games", func(c *Config) { c.Games = -3 }, "games must be at least 1, got -3"},
+        {"zero maxtime", func(c *Config) { c.MaxGameTime = 0 }, "maxtime must be positive, got 0"},
+        {"negative maxtime", func(c *Config) { c.MaxGameTime = -1 }, "maxtime must be positive, got -1"},
+        {"no limit at all", func(c *Config) { c.HandTime, c.MaxTricks = 0, 0 }, "-max-tricks 0 needs a positive hand time"},
+    }
+    for _, tt := range tests {
+        cfg := testConfig()
+        tt.change(&cfg)
+        if err := validateConfig(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("%s: got %v, want an error saying %q", tt.name, err, tt.want)
+        }
+    }
+
+    cfg := testConfig()
+    cfg.HandTime, cfg.ShuffleTime = 0, 0
+    if err := validateConfig(cfg); err != nil {
+        t.Errorf("zero times: %v", err)
+    }
+}
+
+// Invalid arguments stop the command with an error before anything is played,
+// and -hand 0 plays with a warning.
+func TestInvalidArguments(t *testing.T) {
+    dir := t.TempDir()
+    for _, args := range [][]string{
+        {"-hand", "-1"},
+        {"-shuffle", "-1"},
+        {"-games", "0"},
+        {"-maxtime", "0"},
+    } {
+        _, stderr, err := runMain(t, dir, append([]string{"-games", "1"}, args...)...)
+        if err == nil || !strings.Contains(stderr, "Error") {
+            t.Errorf("%v: err %v, stderr %q; want it rejected", args, err, stderr)
+        }
+    }
+    if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+        t.Errorf("rejected runs wrote %v", files)
+    }
+
+    _, stderr, err := runMain(t, dir, "-hand", "0", "-games", "2", "-seed", "1")
+    if err != nil || !strings.Contains(stderr, "-hand 0") {
+        t.Errorf("-hand 0: err %v, stderr %q; want it played with a warning", err, stderr)
+    }
+}
+
+// Interrupting a run writes the games finished so far as a shorter run,
+// named and described by the games it holds, and says how many there are.
+// A streamed run's metadata, written before it was interrupted, is rewritten
+// to count them, gzipped too.
+func TestInterruptedRunKeepsItsGames(t *testing.T) {
+    for _, tc := range []struct {
+        name string
+        args []string
+    }{
+        {"kept", []string{"-output-file", "run.csv"}},
+        {"streamed", []string{"-stream", "-output-file", "run.csv"}},
+        {"streamed compressed", []string{"-stream", "-compress", "-output-file", "run.csv.gz"}},
+    } {
+        t.Run(tc.name, func(t *testing.T) {
+            dir := t.TempDir()
+            args := append([]string{"-games", "300000", "-seed", "1", "-workers", "2", "-progress", "10ms"}, tc.args...)
+            stdout, stderr := interruptMain(t, dir, gamesPlayed, args...)
+
+            var played int
+            _, report, _ := strings.Cut(stderr, "Warning: ")
+            if _, err := fmt.Sscanf(report, "interrupted after %d of 300000 games", &played); err != nil || played == 0 {
+                t.Fatalf("interrupted run reported %q (%v), want how many games it kept", stderr, err)
+            }
+            filename := filepath.Join(dir, tc.args[len(tc.args)-1])
+            if strings.HasSuffix(filename, ".gz") {
+                filename = gunzipFile(t, filename)
+            }
+            target, err := readAppendTarget(filename)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if target.LastGame != played || target.Metadata["games"] != strconv.Itoa(played) {
+                t.Errorf("results end at game %d with games=%s, want the %d games kept", target.LastGame, target.Metadata["games"], played)
+            }
+            if !strings.Contains(stdout, fmt.Sprintf("Total number of games played: %d\n", played)) {
+                t.Errorf("summary is not of the %d games kept:\n%s", played, stdout)
+            }
+        })
+    }
+}
+
+// gunzipFile decompresses the gzipped filename beside it, returning the name
+// of the decompressed file.
+func gunzipFile(t *testing.T, filename string) string {
+    t.Helper()
+    file, err := os.Open(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    gz, err := gzip.NewReader(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    data, err := io.ReadAll(gz)
+    if err != nil {
+        t.Fatalf("%s: %v", filename, err)
+    }
+    plain := strings.TrimSuffix(filename, ".gz")
+    if err := os.WriteFile(plain, data, 0644); err != nil {
+        t.Fatal(err)
+    }
+    return plain
+}
+
+func TestPrintRankWins(t *testing.T) {
+    var a, b war.GameStats
+    a.RankWins[14], a.RankWins[2] = 3, 1
+    b.RankWins[15] = 4
+    var buf bytes.Buffer
+    printRankWins(&buf, []war.GameStats{a, b, {Errored: true, Tricks: -1}})
+    want := "Tricks by deciding rank:\n" +
+        "     2          1   12.50%\n" +
+        "     A          3   37.50%\n" +
+        " Joker          4   50.00%\n"
+    if buf.String() != want {
+        t.Errorf("rank breakdown:\n%s\nwant:\n%s", buf.String(), want)
+    }
+    buf.Reset()
+    printRankWins(&buf, nil)
+    if !strings.Contains(buf.String(), "no tricks to report") {
+        t.Errorf("rank breakdown of no games: %q", buf.String())
+    }
+}
+
+// Games stopped at the time limit and given to the player holding more cards
+// are counted apart from those played out, in the counts and the summary.
+func TestTimeoutWinsAreCountedApart(t *testing.T) {
+    stats := war.RunSimulations(50, 500, 15000, false, 60000, 1, 2, war.DefaultOptions())
+    timedOutWins, playedOut := 0, 0
+    for _, game := range stats {
+        switch {
+        case game.TerminationReason == "timeout" && game.Winner != 0:
+            timedOutWins++
+        case game.Finished && game.TerminationReason != "timeout":
+            playedOut++
+        }
+    }
+    if timedOutWins == 0 {
+        t.Fatal("no game was won at a 60-second time limit")
+    }
+
+    outcomes := countOutcomes(stats)
+    if outcomes.TimedOutWinsA+outcomes.TimedOutWinsB != timedOutWins || outcomes.Finished()-outcomes.TimedOut != playedOut {
+        t.Errorf("outcomes %+v, want %d wins at the time limit and %d games played out", outcomes, timedOutWins, playedOut)
+    }
+    var buf bytes.Buffer
+    printSummaryStatistics(&buf, stats)
+    for _, line := range []string{
+        fmt.Sprintf("  Played out: %d (", playedOut),
+        fmt.Sprintf("  Decided at the time limit: %d (", outcomes.TimedOut),
+        fmt.Sprintf("): Player A %d, Player B %d\n", outcomes.TimedOutWinsA, outcomes.TimedOutWinsB),
+    } {
+        if !strings.Contains(buf.String(), line) {
+            t.Errorf("summary lacks %q:\n%s", line, buf.String())
+        }
+    }
+}
+
+// -no-shuffle is -initial-sortedness 1, and with it every game is the same.
+func TestNoShuffleFlag(t *testing.T) {
+    dir := t.TempDir()
+    if _, stderr, err := runMain(t, dir, "-silent", "-no-shuffle", "-games", "3", "-output-file", "run.csv", "-columns", "full"); err != nil {
+        t.Fatalf("%v\n%s", err, stderr)
+    }
+    written, err := os.ReadFile(filepath.Join(dir, "run.csv"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(string(written), "# initial-sortedness=1\n") {
+        t.Errorf("-no-shuffle run is not recorded as unshuffled:\n%s", written)
+    }
+    target, _ := readAppendTarget(filepath.Join(dir, "run.csv"))
+    pips := slices.Index(target.Columns, "Pips A")
+    var rows [][]string
+    for _, line := range strings.Split(string(written), "\n") {
+        if fields := strings.Split(line, ","); len(fields) == len(target.Columns) && fields[0] != "Game Number" {
+            rows = append(rows, fields)
+        }
+    }
+    if len(rows) != 3 || rows[0][pips] != rows[1][pips] || rows[1][pips] != rows[2][pips] {
+        t.Errorf("unshuffled games were dealt different hands: %v", rows)
+    }
+}
+
+// -max-tricks reaches the games of a run.
+func TestMaxTricksFlag(t *testing.T) {
+    dir := t.TempDir()
+    if _, stderr, err := runMain(t, dir, "-silent", "-games", "3", "-seed", "1", "-max-tricks", "5", "-output-file", "run.csv"); err != nil {
+        t.Fatalf("%v\n%s", err, stderr)
+    }
+    written, _ := os.ReadFile(filepath.Join(dir, "run.csv"))
+    for i := 1; i <= 3; i++ {
+        if !strings.Contains(string(written), fmt.Sprintf("\n%d,5,", i)) {
+            t.Errorf("game %d was not stopped after 5 tricks:\n%s", i, written)
+        }
+    }
+    if !strings.Contains(string(written), "# max-tricks=5\n") {
+        t.Errorf("metadata does not record the trick limit:\n%s", written)
+    }
+}
+
+// A dry run describes the run and its cost but plays and writes nothing.
+func TestDryRun(t *testing.T) {
+    dir := t.TempDir()
+    stdout, stderr, err := runMain(t, dir, "-dry-run", "-games", "100000", "-seed", "1", "-hand", "250", "-output-file", "run.csv")
+    if err != nil {
+        t.Fatalf("dry run: %v\n%s", err, stderr)
+    }
+    for _, want := range []string{
+        "Dry run: nothing is played or written",
+        "  seed=1\n",
+        "  hand=250\n",
+        "Deck: 52 cards, 2 to A, 4 of each rank\n",
+        "for 100000 games",
+        "Results: about",
+        "Projected time: about",
+    } {
+        if !strings.Contains(stdout, want) {
+            t.Errorf("dry run output does not have %q:\n%s", want, stdout)
+        }
+    }
+    if strings.Contains(stdout, "Total number of games played") {
+        t.Errorf("dry run printed a summary:\n%s", stdout)
+    }
+    if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+        t.Errorf("dry run left files %v (%v)", entries, err)
+    }
+}
+
+func TestFormatBytes(t *testing.T) {
+    for n, want := range map[float64]string{0: "0.0 B", 1023: "1023.0 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB", 1 << 50: "1024.0 TiB"} {
+        if got := formatBytes(n); got != want {
+            t.Errorf("formatBytes(%v) = %q, want %q", n, got, want)
+        }
+    }
+}
+
+// -capture-to bottom is the single-pile variant, which no other variant can
+// be combined with.
+func TestCaptureToFlag(t *testing.T) {
+    if cfg := parseArgsOf(t, "-capture-to", "bottom"); cfg.Variant != "single-pile" {
+        t.Errorf("-capture-to bottom plays variant %s, want single-pile", cfg.Variant)
+    }
+    if cfg := parseArgsOf(t, "-capture-to", "winnings"); cfg.Variant != "standard" {
+        t.Errorf("-capture-to winnings plays variant %s, want standard", cfg.Variant)
+    }
+    dir := t.TempDir()
+    for _, tc := range []struct {
+        args []string
+        want string
+    }{
+        {[]string{"-capture-to", "bottom", "-variant", "count-tricks"}, "cannot be combined with -variant count-tricks"},
+        {[]string{"-capture-to", "top"}, `unknown capture-to "top"`},
+    } {
+        if _, stderr, err := runMain(t, dir, tc.args...); err == nil || !strings.Contains(stderr, tc.want) {
+            t.Errorf("%v: %v, %q, want an error with %q", tc.args, err, stderr, tc.want)
+        }
+    }
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "wargames/war"
+)
+
+// markdownMaxGames caps per-game Markdown output; beyond that a table is too
+// long to paste into a report and the CSV output is the better fit.
+const markdownMaxGames = 1000
+
+// writeMarkdownSummary renders the run summary as GitHub-flavored Markdown
+// tables: one of per-game metrics and one of outcomes. Without any completed
+// games there are no metrics, and a note takes the place of their table.
+func writeMarkdownSummary(w io.Writer, stats []war.GameStats) error {
+    if len(completedGames(stats)) == 0 {
+        if _, err := fmt.Fprint(w, "No completed games to summarize.\n\n"); err != nil {
+            return err
+        }
+        return writeMarkdownOutcomes(w, stats)
+    }
+
+    rows := [][]string{}
+    for _, s := range summaryStatistics(stats) {
+        rows = append(rows, []string{
+            s.Name,
+            fmt.Sprintf("%.2f", s.Avg),
+            fmt.Sprintf("%.*f", s.Precision, s.Min),
+            fmt.Sprintf("%.*f", s.Precision, s.Max),
+            fmt.Sprintf("%.2f", s.StdDev),
+            fmt.Sprintf("%.2f", s.Median),
+            fmt.Sprintf("%.2f", s.P90),
+            fmt.Sprintf("%.2f", s.P99),
+        })
+    }
+    if err := writeMarkdownTable(w, []string{"Metric", "Avg", "Min", "Max", "StdDev", "Median", "P90", "P99"}, []bool{false, true, true, true, true, true, true, true}, rows); err != nil {
+        return err
+    }
+    if _, err := fmt.Fprintln(w); err != nil {
+        return err
+    }
+    return writeMarkdownOutcomes(w, stats)
+}
+
+// writeMarkdownOutcomes renders the table of outcomes of the summary.
+func writeMarkdownOutcomes(w io.Writer, stats []war.GameStats) error {
+    outcomes := countOutcomes(stats)
+    outcomeRow := func(name string, count int) []string {
+        return []string{name, fmt.Sprint(count), fmt.Sprintf("%.2f%%", outcomes.Percent(count))}
+    }
+    rows := [][]string{
+        outcomeRow("Player A wins", outcomes.WinsA),
+        outcomeRow("Player B wins", outcomes.WinsB),
+        outcomeRow("Draws", outcomes.Draws),
+        outcomeRow("Unfinished", outcomes.Unfinished),
+        outcomeRow("Errors", outcomes.Errors),
+    }
+    if outcomes.WinsOther > 0 {
+        rows = append(rows[:2], append([][]string{outcomeRow("Other players' wins", outcomes.WinsOther)}, rows[2:]...)...)
+    }
+    return writeMarkdownTable(w, []string{"Outcome", "Games", "Percent"}, []bool{false, true, true}, rows)
+}
+
+// writeMarkdownGames renders the per-game results as a Markdown table, keeping
+// at most the first markdownMaxGames games.
+func writeMarkdownGames(w io.Writer, stats []war.GameStats, columns []Column) error {
+    if len(stats) > markdownMaxGames {
+        logger.Warnf("writing only the first %d of %d games as Markdown", markdownMaxGames, len(stats))
+        stats = stats[:markdownMaxGames]
+    }
+
+    headers := make([]string, len(columns))
+    numeric := make([]bool, len(columns))
+    for i, column := range columns {
+        headers[i] = column.Name
+        numeric[i] = true
+    }
+    rows := make([][]string, len(stats))
+    for i, game := range stats {
+        rows[i] = make([]string, len(columns))
+        for j, column := range columns {
+            rows[i][j] = column.Value(game)
+            if _, err := strconv.ParseFloat(rows[i][j], 64); err != nil && rows[i][j] != "" {
+                numeric[j] = false
+            }
+        }
+    }
+    return writeMarkdownTable(w, headers, numeric, rows)
+}
+
+// writeMarkdownTable writes a table with numeric columns right-aligned.
+func writeMarkdownTable(w io.Writer, headers []string, rightAlign []bool, rows [][]string) error {
+    separators := make([]string, len(headers))
+    for i := range headers {
+        if rightAlign[i] {
+            separators[i] = "---:"
+        } else {
+            separators[i] = ":---"
+        }
+    }
+
+    lines := []string{markdownRow(headers), markdownRow(separators)}
+    for _, row := range rows {
+        lines = append(lines, markdownRow(row))
+    }
+    _, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+    return err
+}
+
+func markdownRow(cells []string) string {
+    escaped := make([]string, len(cells))
+    for i, cell := range cells {
+        escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+    }
+    return "| " + strings.Join(escaped, " | ") + " |"
+}
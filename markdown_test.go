@@ -0,0 +1,63 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestMarkdownSummaryTables(t *testing.T) {
+    var buf bytes.Buffer
+    if err := writeMarkdownSummary(&buf, runGames(t, 20)); err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(buf.String(), "\n")
+    if lines[0] != "| Metric | Avg | Min | Max | StdDev | Median | P90 | P99 |" {
+        t.Errorf("metrics header %q", lines[0])
+    }
+    if lines[1] != "| :--- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |" {
+        t.Errorf("metrics separator %q, want the names left-aligned and the numbers right", lines[1])
+    }
+    if !strings.Contains(buf.String(), "| Outcome | Games | Percent |\n| :--- | ---: | ---: |\n| Player A wins | ") {
+        t.Errorf("no outcomes table:\n%s", buf.String())
+    }
+}
+
+func TestMarkdownGamesTable(t *testing.T) {
+    defer quietLogger()()
+    columns, _ := columnsForPreset("full")
+    stats := runGames(t, 3)
+    var buf bytes.Buffer
+    if err := writeMarkdownGames(&buf, stats, columns); err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    if len(lines) != 5 {
+        t.Fatalf("%d lines, want a header, a separator and 3 rows:\n%s", len(lines), buf.String())
+    }
+    separators := strings.Split(strings.Trim(lines[1], "| "), " | ")
+    if len(separators) != len(columns) {
+        t.Fatalf("%d separators for %d columns", len(separators), len(columns))
+    }
+    for i, column := range columns {
+        want := "---:"
+        if column.Name == "Finished" || column.Name == "Early Draw" || column.Name == "Termination Reason" || column.Name == "Player Tricks" || column.Name == "Rank Wins" || column.Name == "Winner Was Behind" {
+            want = ":---" // Not numbers
+        }
+        if separators[i] != want {
+            t.Errorf("column %s aligned %s, want %s", column.Name, separators[i], want)
+        }
+    }
+
+    buf.Reset()
+    writeMarkdownGames(&buf, runGames(t, markdownMaxGames+5), columns[:1])
+    if rows := strings.Count(buf.String(), "\n") - 2; rows != markdownMaxGames {
+        t.Errorf("wrote %d rows, want the first %d", rows, markdownMaxGames)
+    }
+}
+
+func TestMarkdownEscapesPipes(t *testing.T) {
+    if got := markdownRow([]string{"a|b", "c"}); got != `| a\|b | c |` {
+        t.Errorf("markdownRow = %q", got)
+    }
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+    "fmt"
+    "io"
+
+    "wargames/war"
+)
+
+// maxMatchGames ends a match that is still undecided after this many games,
+// such as one whose games keep ending without a winner.
+const maxMatchGames = 1000
+
+// MatchStats is one match: games played until one of the two players has won
+// MatchWins of them. Player 1 is Player A of the match's first game. With
+// -match-deal alternate the players change seats every game; otherwise
+// player 1 is always Player A.
+type MatchStats struct {
+    Games  []war.GameStats
+    Wins   [2]int // Games won by player 1 and player 2
+    Winner int    // 1 or 2 for the player who won the match, 0 if it was abandoned
+}
+
+// add records game as the match's next game and reports whether the match is
+// over.
+func (m *MatchStats) add(game war.GameStats, matchWins int, alternate bool) bool {
+    if game.Winner == 1 || game.Winner == 2 {
+        player := game.Winner
+        if alternate && len(m.Games)%2 == 1 {
+            player = 3 - player // Player 2 sits as Player A in the even games
+        }
+        m.Wins[player-1]++
+        if m.Wins[player-1] == matchWins {
+            m.Winner = player
+        }
+    }
+    m.Games = append(m.Games, game)
+    return m.Winner != 0 || len(m.Games) == maxMatchGames
+}
+
+// playMatches plays cfg.Games matches of the run's games in order: the first
+// match starts with game 1 and each match starts with the game after the
+// last one of the match before. Games are played in batches across the
+// workers, and any played after the last match ends are dropped. A cancelled
+// Context ends the matches early with those already decided.
+func playMatches(cfg Config) []MatchStats {
+    progress := cfg.Progress
+    cfg.Progress = nil // Matches are counted rather than games
+    alternate := cfg.MatchDeal == "alternate"
+
+    matches := make([]MatchStats, 0, cfg.Games)
+    var match MatchStats
+    next := 0
+    for len(matches) < cfg.Games {
+        // Enough games for the matches left if each takes about as many as
+        // a close one.
+        batch := (cfg.Games - len(matches)) * (2*cfg.MatchWins - 1)
+        games := war.RunGameRange(next, batch, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, cfg.Seed, cfg.Workers, cfg.Options.Options)
+        next += batch
+        for _, game := range games {
+            if !match.add(game, cfg.MatchWins, alternate) {
+                continue
+            }
+            matches = append(matches, match)
+            match = MatchStats{}
+            if progress != nil {
+                progress.Add(1)
+            }
+            if len(matches) == cfg.Games {
+                break
+            }
+        }
+        if cfg.Context != nil && cfg.Context.Err() != nil {
+            break // The match in progress is dropped
+        }
+    }
+    return matches
+}
+
+// matchGames returns the games of every match in the order played.
+func matchGames(matches []MatchStats) []war.GameStats {
+    var games []war.GameStats
+    for _, match := range matches {
+        games = append(games, match.Games...)
+    }
+    return games
+}
+
+// printMatchReport prints who won the matches and how many games they took.
+func printMatchReport(w io.Writer, matches []MatchStats, matchWins int) {
+    if len(matches) == 0 {
+        return
+    }
+    counts := [3]int{}
+    lengths := make([]float64, len(matches))
+    for i, match := range matches {
+        counts[match.Winner]++
+        lengths[i] = float64(len(match.Games))
+    }
+    percent := func(n int) float64 { return float64(n) / float64(len(matches)) * 100 }
+
+    fmt.Fprintf(w, "Matches (first to %d wins): %d\n", matchWins, len(matches))
+    fmt.Fprintf(w, "Player 1 Match Wins: %d (%.2f%%)\n", counts[1], percent(counts[1]))
+    fmt.Fprintf(w, "Player 2 Match Wins: %d (%.2f%%)\n", counts[2], percent(counts[2]))
+    if counts[0] > 0 {
+        fmt.Fprintf(w, "Abandoned after %d games: %d (%.2f%%)\n", maxMatchGames, counts[0], percent(counts[0]))
+    }
+    printStatistic(w, newStatistic("Games per Match", lengths, 0))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+// playScripted adds games won by the scripted seats, 0 for a draw, to a
+// best-of-3 match until it ends, and returns it with the games it took.
+func playScripted(winners []int, alternate bool) (MatchStats, int) {
+    var match MatchStats
+    for i, winner := range winners {
+        if match.add(war.GameStats{GameNumber: i + 1, Winner: winner}, 2, alternate) {
+            return match, i + 1
+        }
+    }
+    return match, len(winners)
+}
+
+func TestBestOfThree(t *testing.T) {
+    tests := []struct {
+        name      string
+        winners   []int // Seat that won each game: 1 for Player A
+        alternate bool
+        games     int
+        winner    int
+        wins      [2]int
+    }{
+        {"two straight", []int{1, 1, 2}, false, 2, 1, [2]int{2, 0}},
+        {"decider", []int{2, 1, 2, 1}, false, 3, 2, [2]int{1, 2}},
+        {"draws do not count", []int{0, 1, 0, 1}, false, 4, 1, [2]int{2, 0}},
+        // Player 2 sits as Player A in the second game, so A winning both
+        // is one game each, and B winning the third gives player 2 the match.
+        {"alternating seats", []int{1, 1, 2}, true, 3, 2, [2]int{1, 2}},
+        {"alternating sweep", []int{1, 2}, true, 2, 1, [2]int{2, 0}},
+    }
+    for _, tt := range tests {
+        match, played := playScripted(tt.winners, tt.alternate)
+        if played != tt.games || match.Winner != tt.winner || match.Wins != tt.wins || len(match.Games) != tt.games {
+            t.Errorf("%s: match of %d games won by %d with %v, want %d games won by %d with %v",
+                tt.name, played, match.Winner, match.Wins, tt.games, tt.winner, tt.wins)
+        }
+    }
+
+    var buf bytes.Buffer
+    first, _ := playScripted([]int{1, 1}, false)
+    second, _ := playScripted([]int{2, 1, 2}, false)
+    printMatchReport(&buf, []MatchStats{first, second}, 2)
+    for _, want := range []string{"Matches (first to 2 wins): 2", "Player 1 Match Wins: 1 (50.00%)", "Player 2 Match Wins: 1 (50.00%)", "Games per Match"} {
+        if !strings.Contains(buf.String(), want) {
+            t.Errorf("match report lacks %q:\n%s", want, buf.String())
+        }
+    }
+}
+
+// Matches are played from the run's games in order, each starting with the
+// game after the last of the match before, and the match mode is in the
+// results file's name.
+func TestPlayMatches(t *testing.T) {
+    cfg := testConfig()
+    cfg.Games = 5
+    cfg.MatchWins = 2
+    matches := playMatches(cfg)
+    if len(matches) != 5 {
+        t.Fatalf("played %d matches, want 5", len(matches))
+    }
+    next := 1
+    for _, match := range matches {
+        if match.Winner == 0 || match.Wins[match.Winner-1] != 2 {
+            t.Errorf("match %+v has no winner with 2 wins", match.Wins)
+        }
+        for _, game := range match.Games {
+            if game.GameNumber != next {
+                t.Fatalf("match game %d follows game %d", game.GameNumber, next-1)
+            }
+            next++
+        }
+    }
+    if name := runFileName("results", "csv", cfg); !strings.Contains(name, "_match2") {
+        t.Errorf("results file %s is not marked as a match run", name)
+    }
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+    "math"
+    "slices"
+
+    "wargames/war"
+)
+
+// standardDeckSize is the number of cards WinProbability's table is for.
+const standardDeckSize = 52
+
+// winRates holds, for each even card difference from 0 to standardDeckSize,
+// the share of the tricks after which Player A was that many cards ahead of
+// Player B that A went on to win, in standard-deck games played to the end
+// with the default rules. They are calibrateWinRates(200000, 1) rounded to
+// four places; TestWinRatesAreCalibrated plays a fresh calibration to check
+// them. Between tricks the difference is even, since the deck is.
+var winRates = [standardDeckSize/2 + 1]float64{
+    0.5000, 0.5194, 0.5382, 0.5571, 0.5761, 0.5951, 0.6146, 0.6337, 0.6529,
+    0.6722, 0.6909, 0.7098, 0.7291, 0.7483, 0.7670, 0.7858, 0.8049, 0.8240,
+    0.8437, 0.8632, 0.8809, 0.8987, 0.9166, 0.9348, 0.9549, 0.9781, 1.0000,
+}
+
+// WinProbability estimates Player A's chance of winning a standard-deck game
+// from the difference between the cards the players hold, A's less B's, by
+// the share of simulated games won from that difference.
+func WinProbability(diff int) float64 {
+    if diff < 0 {
+        return 1 - WinProbability(-diff)
+    }
+    if diff >= standardDeckSize {
+        return 1
+    }
+    // An odd difference lies between two the table holds.
+    return (winRates[diff/2] + winRates[(diff+1)/2]) / 2
+}
+
+// winProbability is WinProbability for a game dealt from deckSize cards,
+// scaling the difference to the standard deck's size, which the win rates of
+// simulated games follow closely for other decks too.
+func winProbability(diff, deckSize int) float64 {
+    if deckSize <= 0 {
+        return 0.5
+    }
+    scaled := float64(diff) * standardDeckSize / float64(deckSize)
+    lower := math.Floor(scaled)
+    p := WinProbability(int(lower))
+    return p + (WinProbability(int(lower)+1)-p)*(scaled-lower)
+}
+
+// calibrateWinRates plays the first games games of the run seeded with seed
+// to the end, with the standard deck and the default rules, and returns the
+// winRates they give. Every trick counts from both players' sides, a win for
+// one being a loss for the other, so the rate at 0 is one half. A rate below
+// the one before it, from sampling alone, is pooled with it, keeping the
+// rates rising with the difference.
+func calibrateWinRates(games int, seed int64) []float64 {
+    tricks := make([]int, standardDeckSize/2+1)
+    wins := make([]int, len(tricks))
+    opts := war.DefaultOptions()
+    var diffs []int
+    for n := 1; n <= games; n++ {
+        game := war.NewGame(nil, 500, 15000, false, math.MaxInt32, opts, war.NewGameRand(seed, n, 0))
+        diffs = diffs[:0]
+        for {
+            if _, ok := game.PlayTrick(); !ok {
+                break
+            }
+            cardsA, cardsB := game.CardsLeft()
+            diffs = append(diffs, cardsA-cardsB)
+        }
+        winner := game.Stats().Winner
+        if winner != 1 && winner != 2 {
+            continue
+        }
+        for _, diff := range diffs {
+            for _, side := range []int{1, -1} { // A's view and B's
+                i, won := side*diff/2, (winner == 1) == (side == 1)
+                if i < 0 || i >= len(tricks) {
+                    continue
+                }
+                tricks[i]++
+                if won {
+                    wins[i]++
+                }
+            }
+        }
+    }
+
+    // Pool adjacent violators, merging a block into the one before while
+    // its rate is lower.
+    type block struct{ wins, tricks, count int }
+    var blocks []block
+    for i := range tricks {
+        b := block{wins[i], tricks[i], 1}
+        for len(blocks) > 0 && b.tricks > 0 {
+            last := blocks[len(blocks)-1]
+            if last.tricks > 0 && float64(last.wins)/float64(last.tricks) <= float64(b.wins)/float64(b.tricks) {
+                break
+            }
+            b = block{last.wins + b.wins, last.tricks + b.tricks, last.count + b.count}
+            blocks = blocks[:len(blocks)-1]
+        }
+        blocks = append(blocks, b)
+    }
+    var rates []float64
+    for _, b := range blocks {
+        rate := 1.0 // Never reached, so as good as won
+        if b.tricks > 0 {
+            rate = float64(b.wins) / float64(b.tricks)
+        }
+        rates = append(rates, slices.Repeat([]float64{rate}, b.count)...)
+    }
+    return rates
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+    "math"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestWinProbabilityIsMonotonic(t *testing.T) {
+    prev := -1.0
+    for diff := -standardDeckSize - 2; diff <= standardDeckSize+2; diff++ {
+        p := WinProbability(diff)
+        if p < prev || p < 0 || p > 1 {
+            t.Fatalf("WinProbability(%d) = %v after %v", diff, p, prev)
+        }
+        prev = p
+    }
+    if WinProbability(0) != 0.5 || WinProbability(standardDeckSize) != 1 || WinProbability(-standardDeckSize) != 0 {
+        t.Error("an even count is not 0.5, or holding every card is not certain")
+    }
+    for _, deckSize := range []int{20, 27, 54, 104} {
+        prev := -1.0
+        for diff := -deckSize; diff <= deckSize; diff++ {
+            p := winProbability(diff, deckSize)
+            if p < prev || p < 0 || p > 1 {
+                t.Fatalf("deck of %d: winProbability(%d) = %v after %v", deckSize, diff, p, prev)
+            }
+            prev = p
+        }
+    }
+}
+
+// The bundled win rates are those a fresh calibration, from other games,
+// finds up to sampling error.
+func TestWinRatesAreCalibrated(t *testing.T) {
+    rates := calibrateWinRates(5000, 2)
+    if len(rates) != len(winRates) {
+        t.Fatalf("calibration gives %d rates, want %d", len(rates), len(winRates))
+    }
+    for i, rate := range rates {
+        if math.Abs(rate-winRates[i]) > 0.02 {
+            t.Errorf("%d cards ahead: A won %.4f of the tricks, the table says %.4f", 2*i, rate, winRates[i])
+        }
+    }
+}
+
+// Scaled to a short deck, the estimate tracks how often Player A goes on to
+// win from each card difference, over every trick of games played to the
+// end. Each difference seen at least minTricks times is within tolerance of
+// its rate.
+func TestWinProbabilityMatchesShortDeckPlay(t *testing.T) {
+    const (
+        games     = 3000
+        minTricks = 2000
+        tolerance = 0.03
+    )
+    opts := war.DefaultOptions()
+    opts.Deck = war.DeckSpec{MinRank: 2, MaxRank: 8, Copies: 4}
+    deckSize := len(war.CreateDeck(opts.Deck, false))
+    tricks, wins := make(map[int]int), make(map[int]int)
+    for n := 1; n <= games; n++ {
+        game := war.NewGame(nil, 500, 15000, false, math.MaxInt32, opts, war.NewGameRand(99, n, 0))
+        var diffs []int
+        for {
+            if _, ok := game.PlayTrick(); !ok {
+                break
+            }
+            cardsA, cardsB := game.CardsLeft()
+            diffs = append(diffs, cardsA-cardsB)
+        }
+        stats := game.Stats()
+        if stats.Winner == 0 {
+            continue
+        }
+        for _, diff := range diffs {
+            tricks[diff]++
+            if stats.Winner == 1 {
+                wins[diff]++
+            }
+        }
+    }
+    checked := 0
+    for diff, n := range tricks {
+        if n < minTricks {
+            continue
+        }
+        checked++
+        rate := float64(wins[diff]) / float64(n)
+        if p := winProbability(diff, deckSize); math.Abs(rate-p) > tolerance {
+            t.Errorf("deck of %d: A won %.3f of %d tricks %+d cards ahead, estimated %.3f", deckSize, rate, n, diff, p)
+        }
+    }
+    if checked < deckSize/3 {
+        t.Errorf("deck of %d: only %d differences seen %d times", deckSize, checked, minTricks)
+    }
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+    "runtime"
+    "runtime/pprof"
+)
+
+// startProfile starts the -profile profile of kind cpu or mem, to be written
+// to filename. The returned function stops it and writes the file: a CPU
+// profile covers everything in between, and a heap profile is a snapshot
+// taken when it is called, after a garbage collection.
+func startProfile(kind, filename string) (func() error, error) {
+    file, err := createOutputFile(filename)
+    if err != nil {
+        return nil, err
+    }
+    if kind == "cpu" {
+        if err := pprof.StartCPUProfile(file); err != nil {
+            file.Close()
+            return nil, err
+        }
+        return func() error {
+            pprof.StopCPUProfile()
+            return file.Close()
+        }, nil
+    }
+    return func() error {
+        runtime.GC()
+        if err := pprof.WriteHeapProfile(file); err != nil {
+            file.Close()
+            return err
+        }
+        return file.Close()
+    }, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// isProfile reports whether the file holds a written pprof profile, which is
+// gzip-compressed.
+func isProfile(t *testing.T, filename string) bool {
+    t.Helper()
+    data, err := os.ReadFile(filename)
+    if err != nil {
+        t.Error(err)
+        return false
+    }
+    return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+func TestProfileIsWritten(t *testing.T) {
+    dir := t.TempDir()
+    for _, kind := range []string{"cpu", "mem"} {
+        if _, stderr, err := runMain(t, dir, "-games", "200", "-seed", "1", "-profile", kind); err != nil {
+            t.Fatalf("-profile %s: %v\n%s", kind, err, stderr)
+        }
+        if filename := filepath.Join(dir, "war_"+kind+".pprof"); !isProfile(t, filename) {
+            t.Errorf("-profile %s did not write a profile to %s", kind, filename)
+        }
+    }
+
+    filename := filepath.Join(dir, "named.pprof")
+    if _, stderr, err := runMain(t, dir, "-games", "200", "-profile", "cpu", "-profile-file", filename); err != nil {
+        t.Fatalf("-profile-file: %v\n%s", err, stderr)
+    }
+    if !isProfile(t, filename) {
+        t.Errorf("-profile-file did not write a profile to %s", filename)
+    }
+
+    if _, stderr, err := runMain(t, dir, "-games", "1", "-profile", "block"); err == nil || !strings.Contains(stderr, `unknown profile "block"`) {
+        t.Errorf("-profile block: %v, %q", err, stderr)
+    }
+}
+
+// A run that fails after the profile has started still writes it.
+func TestFailedRunWritesItsProfile(t *testing.T) {
+    dir := t.TempDir()
+    for _, kind := range []string{"cpu", "mem"} {
+        _, stderr, err := runMain(t, dir, "-replay-game", "1", "-profile", kind)
+        if err == nil || !strings.Contains(stderr, "needs the -seed") {
+            t.Fatalf("-replay-game without -seed: %v, %q", err, stderr)
+        }
+        if filename := filepath.Join(dir, "war_"+kind+".pprof"); !isProfile(t, filename) {
+            t.Errorf("failed run left no %s profile in %s", kind, filename)
+        }
+    }
+}
+
+// A run stopped by an interrupt still stops and writes its CPU profile.
+func TestInterruptedRunWritesItsProfile(t *testing.T) {
+    dir := t.TempDir()
+    _, stderr := interruptMain(t, dir, gamesPlayed, "-games", "300000", "-seed", "1", "-workers", "2", "-quiet", "-profile", "cpu", "-progress", "10ms")
+    if !isProfile(t, filepath.Join(dir, "war_cpu.pprof")) {
+        t.Errorf("interrupted run left no CPU profile:\n%s", stderr)
+    }
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "sync/atomic"
+    "time"
+)
+
+// startProgress reports how many of total games, or other units such as
+// matches, have been played, and an estimate of the time left, on w every
+// interval. The returned counter is for each unit to increment as it
+// finishes. The returned stop function ends
+// the reports and waits for any report in progress, so nothing is written
+// after it returns.
+func startProgress(w io.Writer, total int, unit string, interval time.Duration) (*atomic.Int64, func()) {
+    completed := new(atomic.Int64)
+    stop := make(chan struct{})
+    stopped := make(chan struct{})
+    start := time.Now()
+
+    go func() {
+        defer close(stopped)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-stop:
+                return
+            case <-ticker.C:
+                done := int(completed.Load())
+                elapsed := time.Since(start)
+                eta := "unknown"
+                if done > 0 {
+                    eta = (elapsed * time.Duration(total-done) / time.Duration(done)).Round(time.Second).String()
+                }
+                fmt.Fprintf(w, "Progress: %d/%d %s (%.1f%%), elapsed %v, ETA %s\n", done, total, unit, 100*float64(done)/float64(max(total, 1)), elapsed.Round(time.Second), eta)
+            }
+        }
+    }()
+
+    return completed, func() {
+        close(stop)
+        <-stopped
+    }
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+
+    "wargames/war"
+)
+
+const replHelp = `Commands:
+  set <name> <value>  change a parameter (hand, shuffle, jokers, seed, games, maxtime, variant, war-collect-order)
+  show                print the current parameters
+  run                 play a batch with the current parameters and print the summary
+  help                print this message
+  quit                leave the session
+`
+
+// runREPL reads commands from in until it is exhausted or the user quits,
+// writing prompts, parameters and summaries to out.
+func runREPL(in io.Reader, out io.Writer, cfg Config) {
+    scanner := bufio.NewScanner(in)
+    fmt.Fprint(out, replHelp)
+    for {
+        fmt.Fprint(out, "> ")
+        if !scanner.Scan() {
+            fmt.Fprintln(out)
+            return
+        }
+        fields := strings.Fields(scanner.Text())
+        if len(fields) == 0 {
+            continue
+        }
+
+        switch fields[0] {
+        case "set":
+            if len(fields) != 3 {
+                fmt.Fprintln(out, "usage: set <name> <value>")
+                continue
+            }
+            if err := cfg.set(fields[1], fields[2]); err != nil {
+                fmt.Fprintln(out, "Error:", err)
+            }
+        case "show":
+            cfg.show(out)
+        case "run":
+            cfg.run(out)
+        case "help":
+            fmt.Fprint(out, replHelp)
+        case "quit", "exit":
+            return
+        default:
+            fmt.Fprintf(out, "unknown command %q (try help)\n", fields[0])
+        }
+    }
+}
+
+func (cfg *Config) set(name, value string) error {
+    var err error
+    updated := *cfg
+    switch name {
+    case "hand":
+        updated.HandTime, err = strconv.Atoi(value)
+    case "shuffle":
+        updated.ShuffleTime, err = strconv.Atoi(value)
+    case "jokers":
+        updated.IncludeJokers, err = strconv.ParseBool(value)
+    case "seed":
+        updated.Seed, err = strconv.ParseInt(value, 10, 64)
+    case "games":
+        updated.Games, err = strconv.Atoi(value)
+    case "maxtime":
+        updated.MaxGameTime, err = strconv.Atoi(value)
+    case "variant":
+        updated.Variant = value
+    case "war-collect-order":
+        updated.WarCollectOrder = value
+    default:
+        return fmt.Errorf("unknown parameter %q", name)
+    }
+    if err != nil {
+        return fmt.Errorf("invalid value %q for %s", value, name)
+    }
+    if err := validateConfig(updated); err != nil {
+        return err
+    }
+    *cfg = updated
+    return nil
+}
+
+func (cfg *Config) show(out io.Writer) {
+    fmt.Fprintf(out, "hand=%d shuffle=%d jokers=%v seed=%d games=%d maxtime=%d variant=%s war-collect-order=%s\n",
+        cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.Seed, cfg.Games, cfg.MaxGameTime, cfg.Variant, cfg.WarCollectOrder)
+}
+
+// run plays a batch and prints its summary. With a non-zero seed, repeating
+// a run reproduces it.
+func (cfg *Config) run(out io.Writer) {
+    seed := cfg.Seed
+    if seed == 0 {
+        seed = time.Now().UnixNano()
+    }
+    stats := war.RunSimulations(cfg.Games, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, seed, cfg.Workers, cfg.Options.Options)
+    printSummaryStatistics(out, stats)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestREPL(t *testing.T) {
+    script := strings.Join([]string{
+        "set games 5",
+        "set variant count-tricks",
+        "show",
+        "run",
+        "set games 12",
+        "set hand -1",
+        "set colour red",
+        "run",
+        "quit",
+        "run", // Never reached
+    }, "\n")
+    var out bytes.Buffer
+    runREPL(strings.NewReader(script), &out, testConfig())
+    output := out.String()
+
+    for _, want := range []string{
+        "hand=500 shuffle=15000 jokers=false seed=1 games=5 maxtime=3600000 variant=count-tricks",
+        "Error: hand time must not be negative, got -1",
+        `Error: unknown parameter "colour"`,
+    } {
+        if !strings.Contains(output, want) {
+            t.Errorf("session output lacks %q:\n%s", want, output)
+        }
+    }
+
+    // Each run's summary reflects the parameters set before it, and the one
+    // after quit is not played.
+    totals := strings.Count(output, "Total number of games played:")
+    if totals != 2 || !strings.Contains(output, "Total number of games played: 5\n") || !strings.Contains(output, "Total number of games played: 12\n") {
+        t.Errorf("session played %d runs, want one of 5 games and one of 12:\n%s", totals, output)
+    }
+    if strings.Index(output, "games played: 5\n") > strings.Index(output, "games played: 12\n") {
+        t.Error("the runs' summaries are out of order")
+    }
+}
+
+// A seeded session repeats its runs exactly.
+func TestREPLRunIsReproducible(t *testing.T) {
+    var first, second bytes.Buffer
+    runREPL(strings.NewReader("run\n"), &first, testConfig())
+    runREPL(strings.NewReader("run\n"), &second, testConfig())
+    if first.String() != second.String() {
+        t.Errorf("seeded runs differ:\n%s\n%s", first.String(), second.String())
+    }
+}
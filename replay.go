@@ -0,0 +1,174 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "slices"
+    "strings"
+
+    "wargames/war"
+)
+
+// replayGame plays game ReplayGame of a seeded run on its own, or the game in
+// the -load-replay file. Games are seeded by number, so it plays exactly as it
+// did inside the full run; event logging and the deal are switched on to
+// describe it in detail. With -transcript every trick is also written to that
+// file.
+func replayGame(w io.Writer, cfg Config) {
+    cfg.Log = true
+    cfg.RecordDeal = true
+    var transcript war.GameTranscript
+    if cfg.TranscriptFile != "" {
+        cfg.Transcript = &transcript
+    }
+    var game war.GameStats
+    if cfg.LoadReplay != "" {
+        game = war.PlaySeededGame(cfg.ReplaySeed, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, cfg.Options.Options)
+    } else {
+        game = war.RunGameRange(cfg.ReplayGame-1, 1, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, cfg.Seed, 1, cfg.Options.Options)[0]
+    }
+    checkAndSaveReplay(cfg, cfg.ReplayGame, game.InitialDealA, game.InitialDealB)
+    if cfg.TranscriptFile != "" {
+        if err := writeTranscript(cfg.TranscriptFile, transcript, len(game.InitialDealA)+len(game.InitialDealB)); err != nil {
+            logger.Errorf("writing transcript: %v", err)
+        }
+    }
+
+    if cfg.LoadReplay != "" {
+        fmt.Fprintf(w, "Replay of the game in %s\n", cfg.LoadReplay)
+    } else {
+        fmt.Fprintf(w, "Replay of game %d with seed %d\n", cfg.ReplayGame, cfg.Seed)
+    }
+    fmt.Fprintf(w, "Player A's hand: %s\n", handCards(game.InitialDealA))
+    fmt.Fprintf(w, "Player B's hand: %s\n", handCards(game.InitialDealB))
+    columns, _ := columnsForPreset("full")
+    for _, column := range columns {
+        fmt.Fprintf(w, "%s: %s\n", column.Name, column.Value(game))
+    }
+}
+
+// handCards lists the cards in hand, top card first.
+func handCards(hand []war.Card) string {
+    cards := make([]string, len(hand))
+    for i, card := range hand {
+        cards[i] = card.String()
+    }
+    return strings.Join(cards, " ")
+}
+
+// transcriptLine is a line of a transcript file: a trick, with Player A's
+// estimated chance of winning from the cards held after it.
+type transcriptLine struct {
+    war.TranscriptEntry
+    WinProbabilityA float64
+}
+
+// checkAndSaveReplay warns when a -load-replay game was not dealt the hands
+// its file holds, and with -save-replay saves game gameNumber of the run,
+// dealt handA and handB.
+func checkAndSaveReplay(cfg Config, gameNumber int, handA, handB []war.Card) {
+    if cfg.LoadReplay != "" && !(slices.Equal(handA, cfg.ReplayDeal[0]) && slices.Equal(handB, cfg.ReplayDeal[1])) {
+        logger.Warnf("the replay's seed deals other hands than it saved with these options; play it with the options its game was played with")
+    }
+    if cfg.SaveReplay != "" {
+        if err := writeReplayFile(cfg.SaveReplay, [2][]war.Card{handA, handB}, cfg.gameSeed(gameNumber), replaySettings(cfg)); err != nil {
+            logger.Errorf("saving replay: %v", err)
+        }
+    }
+}
+
+// replaySettings returns the settings a replay of a game played with cfg
+// saves: the results metadata lines that change how a single game plays.
+func replaySettings(cfg Config) []string {
+    var settings []string
+    for _, line := range newRunMetadata(cfg).lines() {
+        switch name, _, _ := strings.Cut(line, "="); name {
+        case "seed", "games", "match-wins", "match-deal", "checksum", "version":
+        default:
+            settings = append(settings, line)
+        }
+    }
+    return settings
+}
+
+// checkReplaySettings refuses to play the replay in filename, saved with
+// settings, with a cfg that sets any of them otherwise, since its seed would
+// then deal or reshuffle differently. A replay saved without settings is
+// only checked against its hands once dealt.
+func checkReplaySettings(filename string, settings []string, cfg Config) error {
+    if settings == nil {
+        return nil
+    }
+    saved := make(map[string]string)
+    for _, line := range settings {
+        name, value, _ := strings.Cut(line, "=")
+        saved[name] = value
+    }
+    var differences []string
+    for _, line := range replaySettings(cfg) {
+        name, value, _ := strings.Cut(line, "=")
+        if recorded, ok := saved[name]; !ok {
+            differences = append(differences, fmt.Sprintf("%s unset, not %s", name, value))
+        } else if recorded != value {
+            differences = append(differences, fmt.Sprintf("%s=%s, not %s", name, recorded, value))
+        }
+        delete(saved, name)
+    }
+    for name, recorded := range saved {
+        differences = append(differences, fmt.Sprintf("%s=%s, not unset", name, recorded))
+    }
+    if differences != nil {
+        slices.Sort(differences)
+        return fmt.Errorf("%s was played with %s; play it with the options it was saved with", filename, strings.Join(differences, "; "))
+    }
+    return nil
+}
+
+// readReplayFile reads the replay saved in filename.
+func readReplayFile(filename string) ([2][]war.Card, int64, []string, error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return [2][]war.Card{}, 0, nil, err
+    }
+    defer file.Close()
+    deal, seed, settings, err := war.LoadReplay(file)
+    if err != nil {
+        return deal, 0, nil, fmt.Errorf("%s: %v", filename, err)
+    }
+    return deal, seed, settings, nil
+}
+
+// writeReplayFile saves a replay of the game dealt initial from the source
+// seeded with seed and played with settings to filename.
+func writeReplayFile(filename string, initial [2][]war.Card, seed int64, settings []string) error {
+    file, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    if err := war.SaveReplayWithSettings(file, initial, seed, settings); err != nil {
+        return err
+    }
+    return file.Close()
+}
+
+// writeTranscript writes one JSON object per trick to filename, of a game
+// dealt deckSize cards.
+func writeTranscript(filename string, transcript war.GameTranscript, deckSize int) error {
+    file, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    encoder := json.NewEncoder(file)
+    for _, entry := range transcript.Entries {
+        line := transcriptLine{entry, winProbability(entry.CardsLeftA-entry.CardsLeftB, deckSize)}
+        if err := encoder.Encode(line); err != nil {
+            return err
+        }
+    }
+    return nil
+}
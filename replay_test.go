@@ -0,0 +1,97 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+// Replaying game N alone gives byte for byte the stats game N has in the
+// whole run.
+func TestReplayGameMatchesTheRun(t *testing.T) {
+    cfg := testConfig()
+    cfg.Games, cfg.Seed = 40, 23
+    cfg.Log, cfg.RecordDeal = true, true
+    run := war.RunSimulations(cfg.Games, cfg.HandTime, cfg.ShuffleTime, false, cfg.MaxGameTime, cfg.Seed, 4, cfg.Options.Options)
+    columns, _ := columnsForPreset("full")
+
+    for _, n := range []int{1, 17, 40} {
+        cfg.ReplayGame = n
+        var out bytes.Buffer
+        replayGame(&out, cfg)
+        for _, column := range columns {
+            want := column.Name + ": " + column.Value(run[n-1]) + "\n"
+            if !strings.Contains(out.String(), want) {
+                t.Errorf("replay of game %d lacks %q", n, want)
+            }
+        }
+    }
+}
+
+// A game saved to a replay file plays again from it exactly as it did, with
+// options that change how it deals and reshuffles, and a replay played with
+// other options is refused.
+func TestLoadReplayReproducesTheGame(t *testing.T) {
+    dir := t.TempDir()
+    dealFile := filepath.Join(dir, "deal.txt")
+    if err := os.WriteFile(dealFile, []byte("A K 7 7 3 9 10\n2 5 A J 7 Q Q 4\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    for _, options := range [][]string{
+        {"-rng-warmup", "7", "-variant", "single-pile", "-deck-ranks", "4-K", "-hand", "250"},
+        {"-fair-deal", "-fair-deal-tolerance", "2", "-jokers", "-shuffle-model", "riffle"},
+        {"-deal", dealFile, "-collect-order", "random", "-war-down", "1"},
+    } {
+        replayFile := filepath.Join(dir, "game.war")
+        played, stderr, err := runMain(t, dir, append([]string{"-replay-game", "5", "-seed", "9", "-save-replay", replayFile}, options...)...)
+        if err != nil {
+            t.Fatalf("%v: %v\n%s", options, err, stderr)
+        }
+        replayed, stderr, err := runMain(t, dir, append([]string{"-load-replay", replayFile}, options...)...)
+        if err != nil {
+            t.Fatalf("%v: loading the replay: %v\n%s", options, err, stderr)
+        }
+        // Only the heading, which names the game, and the game number
+        // differ: a replay is game 1 of its own run.
+        _, played, _ = strings.Cut(played, "\n")
+        _, replayed, _ = strings.Cut(replayed, "\n")
+        played = strings.Replace(played, "Game Number: 5\n", "Game Number: 1\n", 1)
+        if replayed != played || stderr != "" {
+            t.Errorf("%v: the replay played\n%s\nnot\n%s\n%s", options, replayed, played, stderr)
+        }
+
+        if _, stderr, err := runMain(t, dir, "-load-replay", replayFile); err == nil || !strings.Contains(stderr, options[0][1:]) {
+            t.Errorf("%v: replay played with the default options: %v, %q", options, err, stderr)
+        }
+    }
+}
+
+func TestReplaySettings(t *testing.T) {
+    cfg := testConfig()
+    settings := replaySettings(cfg)
+    if err := checkReplaySettings("game.war", settings, cfg); err != nil {
+        t.Errorf("replay refused with its own settings: %v", err)
+    }
+    if err := checkReplaySettings("game.war", nil, cfg); err != nil {
+        t.Errorf("replay without settings refused: %v", err)
+    }
+    for _, line := range settings {
+        if name, _, _ := strings.Cut(line, "="); name == "seed" || name == "games" || name == "version" {
+            t.Errorf("replay settings include %s", line)
+        }
+    }
+
+    other := cfg
+    other.HandTime, other.RNGWarmup = 250, 3
+    other.FairDeal = true
+    err := checkReplaySettings("game.war", settings, other)
+    for _, want := range []string{"hand=500, not 250", "rng-warmup=0, not 3", "fair-deal=false, not true", "fair-deal-tolerance unset, not 0"} {
+        if err == nil || !strings.Contains(err.Error(), want) {
+            t.Errorf("replay with other settings: %v, want %q", err, want)
+        }
+    }
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "runtime/debug"
+    "slices"
+    "strconv"
+    "strings"
+
+    "wargames/war"
+)
+
+// RunMetadata records the parameters a results file came from, so that the
+// run can be repeated from the file alone.
+type RunMetadata struct {
+    Seed        int64
+    HandTime    int
+    ShuffleTime int
+    Jokers      bool
+    Games       int
+    MaxTime     int
+    MatchWins   int // Games needed to win a match; 0 when Games counts games rather than matches
+    RNGWarmup   int // Outputs discarded from each game's random source, which changes every game
+    MatchDeal   string
+    Rules       war.Options // The rules the games were played by, which change their outcomes
+    Checksum    string // runChecksum of the results, with -checksum; empty otherwise
+    Version     string
+}
+
+// newRunMetadata describes the run cfg configures.
+func newRunMetadata(cfg Config) RunMetadata {
+    return RunMetadata{
+        Seed:        cfg.Seed,
+        HandTime:    cfg.HandTime,
+        ShuffleTime: cfg.ShuffleTime,
+        Jokers:      cfg.IncludeJokers,
+        Games:       cfg.Games,
+        MaxTime:     cfg.MaxGameTime,
+        MatchWins:   cfg.MatchWins,
+        RNGWarmup:   cfg.RNGWarmup,
+        MatchDeal:   cfg.MatchDeal,
+        Rules:       cfg.Options.Options,
+        Version:     buildVersion(),
+    }
+}
+
+// lines returns the metadata as name=value pairs named after the flags. Every
+// option that changes how the games come out has a line, so that -append can
+// tell a different run from the same one.
+func (m RunMetadata) lines() []string {
+    rules := m.Rules
+    lines := []string{
+        fmt.Sprintf("seed=%d", m.Seed),
+        fmt.Sprintf("hand=%d", m.HandTime),
+        fmt.Sprintf("shuffle=%d", m.ShuffleTime),
+        fmt.Sprintf("jokers=%v", m.Jokers),
+        fmt.Sprintf("games=%d", m.Games),
+        fmt.Sprintf("maxtime=%d", m.MaxTime),
+        fmt.Sprintf("rng-warmup=%d", m.RNGWarmup),
+        fmt.Sprintf("reshuffle=%v", !rules.NoReshuffle),
+        fmt.Sprintf("variant=%s", rules.Variant),
+        fmt.Sprintf("war-collect-order=%s", rules.WarCollectOrder),
+        fmt.Sprintf("collect-order=%s", rules.CollectOrder),
+        fmt.Sprintf("simul-end=%s", rules.SimulEnd),
+        fmt.Sprintf("tiebreak=%s", rules.TieBreak),
+        fmt.Sprintf("suit-order=%s", rules.SuitOrder),
+        fmt.Sprintf("joker-rule=%s", rules.JokerRule),
+        fmt.Sprintf("war-down=%d", rules.WarDown),
+        fmt.Sprintf("short-war=%s", rules.ShortWar),
+        fmt.Sprintf("max-war-depth=%d", rules.MaxWarDepth),
+        fmt.Sprintf("max-tricks=%d", rules.MaxTricks),
+        fmt.Sprintf("shuffle-model=%s", rules.ShuffleModel),
+        fmt.Sprintf("players=%d", rules.Players),
+        fmt.Sprintf("deck-ranks=%s-%s", war.RankName(rules.Deck.MinRank), war.RankName(rules.Deck.MaxRank)),
+        fmt.Sprintf("deck-copies=%d", rules.Deck.Copies),
+        fmt.Sprintf("initial-sortedness=%g", rules.InitialSortedness),
+        fmt.Sprintf("fair-deal=%v", rules.FairDeal),
+    }
+    if rules.FairDeal {
+        lines = append(lines, fmt.Sprintf("fair-deal-tolerance=%d", rules.FairDealTolerance))
+    }
+    if rules.Deal != nil {
+        hands := make([]string, len(rules.Deal))
+        for i, hand := range rules.Deal {
+            cards := make([]string, len(hand))
+            for j, card := range hand {
+                cards[j] = card.String()
+            }
+            hands[i] = strings.Join(cards, " ")
+        }
+        lines = append(lines, fmt.Sprintf("deal=%s", strings.Join(hands, " / ")))
+    }
+    if m.MatchWins > 0 {
+        lines = append(lines, fmt.Sprintf("match-wins=%d", m.MatchWins))
+        lines = append(lines, fmt.Sprintf("match-deal=%s", m.MatchDeal))
+    }
+    if m.Checksum != "" {
+        lines = append(lines, fmt.Sprintf("checksum=%s", m.Checksum))
+    }
+    return append(lines, fmt.Sprintf("version=%s", m.Version))
+}
+
+// appendTarget is what -append needs from an existing CSV results file: the
+// metadata of the run it holds, its columns and the last game in it.
+type appendTarget struct {
+    Metadata map[string]string // name=value metadata lines, by name
+    Columns  []string
+    LastGame int
+}
+
+// readAppendTarget reads the CSV results file filename for -append. It
+// returns nil, and no error, if the file does not exist yet.
+func readAppendTarget(filename string) (*appendTarget, error) {
+    file, err := os.Open(filename)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    target := appendTarget{Metadata: make(map[string]string)}
+    var last string
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := scanner.Text()
+        switch {
+        case strings.HasPrefix(line, "#"):
+            if target.Columns == nil {
+                if name, value, ok := strings.Cut(strings.TrimSpace(line[1:]), "="); ok {
+                    target.Metadata[name] = value
+                }
+            }
+        case target.Columns == nil:
+            target.Columns = strings.Split(line, ",")
+        case line != "":
+            last = line
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    if target.Columns == nil {
+        return nil, fmt.Errorf("%s has no header row to append to", filename)
+    }
+
+    if last != "" {
+        column := slices.Index(target.Columns, "Game Number")
+        if column < 0 {
+            return nil, fmt.Errorf("%s has no Game Number column to continue", filename)
+        }
+        row, err := csv.NewReader(strings.NewReader(last)).Read()
+        if err != nil || column >= len(row) {
+            return nil, fmt.Errorf("%s ends in an unreadable row", filename)
+        }
+        if target.LastGame, err = strconv.Atoi(row[column]); err != nil {
+            return nil, fmt.Errorf("%s ends in a row without a game number", filename)
+        }
+    }
+    return &target, nil
+}
+
+// check refuses to append a run described by meta, with columns, to the
+// target unless the target holds the same run: every metadata line but the
+// number of games, the checksum and the version must match, none may be
+// missing from either side, and the columns must be the same.
+func (t *appendTarget) check(meta RunMetadata, columns []Column) error {
+    ignored := func(name string) bool { return name == "games" || name == "checksum" || name == "version" }
+    described := make(map[string]bool)
+    for _, line := range meta.lines() {
+        name, value, _ := strings.Cut(line, "=")
+        described[name] = true
+        if ignored(name) {
+            continue
+        }
+        if recorded, ok := t.Metadata[name]; !ok || recorded != value {
+            return fmt.Errorf("cannot append: the file's %s is %q, this run's is %q", name, recorded, value)
+        }
+    }
+    for name, recorded := range t.Metadata {
+        if !described[name] && !ignored(name) {
+            return fmt.Errorf("cannot append: the file's %s is %q, this run has none", name, recorded)
+        }
+    }
+    names := make([]string, len(columns))
+    for i, column := range columns {
+        names[i] = column.Name
+    }
+    if !slices.Equal(names, t.Columns) {
+        return fmt.Errorf("cannot append: the file's columns differ from this run's -columns")
+    }
+    return nil
+}
+
+// recountGames adds added, which is negative to take games away, to the
+// games line of the metadata of the results file filename, gzipped if
+// compressed, so that it counts the games actually in the file: those
+// appended to it, or those an interrupted run played of the games it was
+// asked for. A checksum line is dropped, since it no longer covers the
+// games. The metadata heads the file, so the whole file is copied to a
+// temporary file beside it that then replaces it: this takes time in
+// proportion to the file's size, though only a line at a time is held in
+// memory. Only the replacement is atomic. If it fails, the file keeps its
+// games line as it was.
+func recountGames(filename string, added int, compressed bool) error {
+    in, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    var r io.Reader = in
+    if compressed {
+        gz, err := gzip.NewReader(in)
+        if err != nil {
+            return err
+        }
+        r = gz
+    }
+    temp, err := os.Create(filename + ".tmp")
+    if err != nil {
+        return err
+    }
+    if err := copyCompressed(temp, compressed, func(w io.Writer) error {
+        return copyCountingGames(w, bufio.NewReader(r), filename, added)
+    }); err != nil {
+        temp.Close()
+        os.Remove(temp.Name())
+        return err
+    }
+    if err := temp.Close(); err != nil {
+        os.Remove(temp.Name())
+        return err
+    }
+    return os.Rename(temp.Name(), filename)
+}
+
+// copyCountingGames copies the results file read from r to w, adding added
+// to its games line and leaving out its checksum line.
+func copyCountingGames(w io.Writer, r *bufio.Reader, filename string, added int) error {
+    for {
+        line, err := r.ReadString('\n')
+        if err != nil && err != io.EOF {
+            return err
+        }
+        if !strings.HasPrefix(line, "#") {
+            if _, err := io.WriteString(w, line); err != nil {
+                return err
+            }
+            _, err := io.Copy(w, r)
+            return err
+        }
+        name, value, _ := strings.Cut(strings.TrimSpace(line[1:]), "=")
+        switch name {
+        case "checksum":
+            continue
+        case "games":
+            games, err := strconv.Atoi(value)
+            if err != nil {
+                return fmt.Errorf("%s has an unreadable games line", filename)
+            }
+            line = fmt.Sprintf("# games=%d\n", games+added)
+        }
+        if _, err := io.WriteString(w, line); err != nil {
+            return err
+        }
+        if err == io.EOF {
+            return nil
+        }
+    }
+}
+
+// buildVersion describes the build by its module version, which names the
+// commit it was built from, or by that commit for a development build that
+// has no version.
+func buildVersion() string {
+    info, ok := debug.ReadBuildInfo()
+    if !ok {
+        return "unknown"
+    }
+    if info.Main.Version != "" && info.Main.Version != "(devel)" {
+        return info.Main.Version
+    }
+    for _, setting := range info.Settings {
+        if setting.Key == "vcs.revision" {
+            return setting.Value
+        }
+    }
+    return "(devel)"
+}
+
+// ResultWriter writes a run's results in one output format.
+type ResultWriter interface {
+    WriteResults(w io.Writer, stats []war.GameStats) error
+}
+
+// CSVWriter writes one row per game with the selected columns, after the
+// run's metadata as # comment lines. With RowsOnly set it writes just the
+// rows, for adding to an existing file.
+type CSVWriter struct {
+    Columns  []Column
+    Metadata RunMetadata
+    RowsOnly bool
+}
+
+// JSONWriter writes a JSON object holding the run's metadata, as an object
+// of the name=value pairs, under "metadata" and the full stats of every game
+// as an array under "games". Unlike the columns the stats include every
+// field; GameDuration is in nanoseconds.
+type JSONWriter struct {
+    Metadata RunMetadata
+}
+
+// jsonResults is the object JSONWriter writes.
+type jsonResults struct {
+    Metadata map[string]string `json:"metadata"`
+    Games    []war.GameStats   `json:"games"`
+}
+
+// MarkdownWriter writes the summary statistics as Markdown tables, or with
+// Games set a per-game table with the selected columns. The run's metadata
+// comes first in an HTML comment, which renders as nothing.
+type MarkdownWriter struct {
+    Columns  []Column
+    Games    bool
+    Metadata RunMetadata
+}
+
+// Format is how WriteResults writes a run's results: the output format, csv,
+// json or md, and what goes into it.
+type Format struct {
+    Name          string // csv, json or md; csv when empty
+    Columns       []Column // Per-game columns of CSV, and of Markdown with MarkdownGames
+    MarkdownGames bool
+    Metadata      RunMetadata
+    RowsOnly      bool // CSV rows without the metadata and header, for adding to an existing file
+    Compress      bool // Gzip the output
+}
+
+// resultsFormat returns the Format the -format, -markdown-games and
+// -compress options select, for the run meta describes.
+func resultsFormat(opts Options, columns []Column, meta RunMetadata) Format {
+    return Format{Name: opts.Format, Columns: columns, MarkdownGames: opts.MarkdownGames, Metadata: meta, Compress: opts.Compress}
+}
+
+// writer returns the ResultWriter for the format.
+func (f Format) writer() ResultWriter {
+    switch f.Name {
+    case "json":
+        return JSONWriter{Metadata: f.Metadata}
+    case "md":
+        return MarkdownWriter{Columns: f.Columns, Games: f.MarkdownGames, Metadata: f.Metadata}
+    }
+    return CSVWriter{Columns: f.Columns, Metadata: f.Metadata, RowsOnly: f.RowsOnly}
+}
+
+// WriteResults writes the stats of a run's games to w in format, such as
+// standard output, a network connection or a file. w is left open for the
+// caller to close.
+func WriteResults(w io.Writer, stats []war.GameStats, format Format) error {
+    writer := format.writer()
+    return copyCompressed(w, format.Compress, func(w io.Writer) error {
+        return writer.WriteResults(w, stats)
+    })
+}
+
+// copyCompressed calls write with w, or with a gzip stream into w if compress
+// is set. The stream is closed, writing the gzip trailer, before returning.
+func copyCompressed(w io.Writer, compress bool, write func(io.Writer) error) error {
+    if !compress {
+        return write(w)
+    }
+    gz := gzip.NewWriter(w)
+    if err := write(gz); err != nil {
+        gz.Close()
+        return err
+    }
+    return gz.Close()
+}
+
+func (c CSVWriter) WriteResults(w io.Writer, stats []war.GameStats) error {
+    writer := csv.NewWriter(w)
+    if !c.RowsOnly {
+        for _, line := range c.Metadata.lines() {
+            if _, err := fmt.Fprintf(w, "# %s\n", line); err != nil {
+                return err
+            }
+        }
+
+        headers := make([]string, len(c.Columns))
+        for i, column := range c.Columns {
+            headers[i] = column.Name
+        }
+        if err := writer.Write(headers); err != nil {
+            return err
+        }
+    }
+
+    for _, game := range stats {
+        row := make([]string, len(c.Columns))
+        for i, column := range c.Columns {
+            row[i] = column.Value(game)
+        }
+        if err := writer.Write(row); err != nil {
+            return err
+        }
+    }
+
+    writer.Flush()
+    return writer.Error()
+}
+
+func (j JSONWriter) WriteResults(w io.Writer, stats []war.GameStats) error {
+    metadata := make(map[string]string)
+    for _, line := range j.Metadata.lines() {
+        name, value, _ := strings.Cut(line, "=")
+        metadata[name] = value
+    }
+    return json.NewEncoder(w).Encode(jsonResults{Metadata: metadata, Games: stats})
+}
+
+func (m MarkdownWriter) WriteResults(w io.Writer, stats []war.GameStats) error {
+    if _, err := fmt.Fprintf(w, "<!-- %s -->\n\n", strings.Join(m.Metadata.lines(), " ")); err != nil {
+        return err
+    }
+    if m.Games {
+        return writeMarkdownGames(w, stats, m.Columns)
+    }
+    return writeMarkdownSummary(w, stats)
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "reflect"
+    "slices"
+    "strconv"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+func TestWarmupIsInTheMetadata(t *testing.T) {
+    cfg := Config{HandTime: 500, ShuffleTime: 15000, Seed: 9, Games: 10, MaxGameTime: 3600000}
+    cfg.RNGWarmup = 3
+    var buf bytes.Buffer
+    if err := (CSVWriter{Metadata: newRunMetadata(cfg)}).WriteResults(&buf, nil); err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(buf.String(), "# rng-warmup=3\n") {
+        t.Errorf("metadata does not record the warmup:\n%s", buf.String())
+    }
+    if name := runFileName("results", "csv", cfg); !strings.Contains(name, "_warmup3") {
+        t.Errorf("results file %s is not named after the warmup", name)
+    }
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+    opts := war.DefaultOptions()
+    opts.Log, opts.RecordDeal, opts.TrackLead = true, true, true
+    stats := war.RunSimulations(20, 500, 15000, true, 3600000, 4, 2, opts)
+    stats = append(stats, war.GameStats{GameNumber: 21, Tricks: -1, TerminationReason: "error", Errored: true, PanicTrace: "panic: boom"})
+
+    var buf bytes.Buffer
+    if err := (JSONWriter{}).WriteResults(&buf, stats); err != nil {
+        t.Fatal(err)
+    }
+    var read jsonResults
+    if err := json.Unmarshal(buf.Bytes(), &read); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(read.Games, stats) {
+        t.Errorf("stats read back differ from those written:\n%s", buf.String())
+    }
+    for _, field := range []string{`"TotalWarDepth":`, `"GameDuration":`} {
+        if !strings.Contains(buf.String(), field) {
+            t.Errorf("JSON lacks %s", field)
+        }
+    }
+}
+
+func TestNoReshuffleIsInTheFileName(t *testing.T) {
+    cfg := testConfig()
+    if name := runFileName("results", "csv", cfg); strings.Contains(name, "noreshuffle") {
+        t.Errorf("results file %s is marked as not reshuffled", name)
+    }
+    cfg.NoReshuffle = true
+    if name := runFileName("results", "csv", cfg); !strings.Contains(name, "_noreshuffle") {
+        t.Errorf("results file %s is not marked as not reshuffled", name)
+    }
+}
+
+func TestResultsGoToTheOutputDir(t *testing.T) {
+    cfg := testConfig()
+    cfg.OutputDir = filepath.Join(t.TempDir(), "runs", "today") // Not there yet
+    columns, _ := columnsForPreset(cfg.Columns)
+    filename := resultsFileName(cfg)
+    if filepath.Dir(filename) != cfg.OutputDir {
+        t.Fatalf("results file %s is not in %s", filename, cfg.OutputDir)
+    }
+    if err := writeResultsToFile(filename, runGames(t, 5), resultsFormat(cfg.Options, columns, newRunMetadata(cfg)), false); err != nil {
+        t.Fatal(err)
+    }
+    written, err := os.ReadFile(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if header := "\nGame Number,Tricks,Wars,"; !strings.Contains(string(written), header) {
+        t.Errorf("results file does not have the header row:\n%s", written)
+    }
+
+    cfg.OutputFile = filepath.Join(t.TempDir(), "mine.csv")
+    if got := resultsFileName(cfg); got != cfg.OutputFile {
+        t.Errorf("results go to %s, not the -output-file %s", got, cfg.OutputFile)
+    }
+}
+
+func TestUnwritableResultsFile(t *testing.T) {
+    // A directory cannot be made under a regular file.
+    blocker := filepath.Join(t.TempDir(), "file")
+    if err := os.WriteFile(blocker, nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+    columns, _ := columnsForPreset("standard")
+    for _, filename := range []string{filepath.Join(blocker, "results.csv"), t.TempDir()} {
+        if err := writeResultsToFile(filename, runGames(t, 2), Format{Columns: columns}, false); err == nil {
+            t.Errorf("writing results to %s gave no error", filename)
+        }
+    }
+}
+
+// A report file that cannot be written fails the run, once the results are
+// written and the summary printed.
+func TestUnwritableReportFails(t *testing.T) {
+    dir := t.TempDir()
+    blocker := filepath.Join(dir, "file")
+    if err := os.WriteFile(blocker, nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+    for _, args := range [][]string{
+        {"-summary-file", filepath.Join(blocker, "summary.json")},
+        {"-stream", "-summary-file", filepath.Join(blocker, "summary.json")},
+        {"-features", filepath.Join(blocker, "features.csv")},
+        {"-histogram", "tricks", "-output-dir", filepath.Join(blocker, "out")},
+    } {
+        args = append([]string{"-games", "5", "-seed", "1", "-output-file", filepath.Join(dir, "results.csv")}, args...)
+        stdout, stderr, err := runMain(t, dir, args...)
+        if err == nil {
+            t.Errorf("%v exited without an error:\n%s", args, stderr)
+        }
+        if !strings.Contains(stdout, "Total number of games played: 5") {
+            t.Errorf("%v printed no summary:\n%s", args, stdout)
+        }
+    }
+}
+
+// failingWriter accepts limit bytes and then fails.
+type failingWriter struct{ limit int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+    if len(p) > w.limit {
+        n := w.limit
+        w.limit = 0
+        return n, os.ErrClosed
+    }
+    w.limit -= len(p)
+    return len(p), nil
+}
+
+func TestFailedWriteIsReported(t *testing.T) {
+    columns, _ := columnsForPreset("standard")
+    stats := runGames(t, 200)
+    for _, limit := range []int{0, 100, 5000} {
+        if err := WriteResults(&failingWriter{limit}, stats, Format{Name: "csv", Columns: columns}); err == nil {
+            t.Errorf("a write failing after %d bytes gave no error", limit)
+        }
+    }
+}
+
+// Runs seeded from the time are named, and record, the seed they used, so
+// two of them never overwrite each other's results.
+func TestTimeSeededRunsHaveTheirOwnFiles(t *testing.T) {
+    dir := t.TempDir()
+    for range 2 {
+        if _, stderr, err := runMain(t, dir, "-silent", "-games", "3"); err != nil {
+            t.Fatalf("%v\n%s", err, stderr)
+        }
+    }
+    files, _ := filepath.Glob(filepath.Join(dir, "war_results_*.csv"))
+    if len(files) != 2 {
+        t.Fatalf("two runs wrote %v, want two files", files)
+    }
+    for _, file := range files {
+        if strings.Contains(file, "_seed0_") {
+            t.Errorf("results file %s is named after seed 0", file)
+        }
+        target, err := readAppendTarget(file)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if seed := target.Metadata["seed"]; !strings.Contains(filepath.Base(file), "_seed"+seed+"_") {
+            t.Errorf("results file %s records seed %s", file, seed)
+        }
+    }
+}
+
+// A results file alone says how to repeat its run: the metadata read back
+// from it has the run's parameters and the rules it was played by.
+// The metadata of CSV and JSON results files reads back as the options the
+// run was given.
+func TestMetadataReadsBack(t *testing.T) {
+    dir := t.TempDir()
+    args := []string{"-silent", "-games", "4", "-seed", "42", "-jokers", "-hand", "700",
+        "-variant", "central-discard", "-war-down", "2", "-deck-ranks", "5-A"}
+    if _, stderr, err := runMain(t, dir, append(args, "-output-file", "run.csv")...); err != nil {
+        t.Fatalf("%v\n%s", err, stderr)
+    }
+    target, err := readAppendTarget(filepath.Join(dir, "run.csv"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, stderr, err := runMain(t, dir, append(args, "-format", "json", "-output-file", "run.json")...); err != nil {
+        t.Fatalf("%v\n%s", err, stderr)
+    }
+    data, err := os.ReadFile(filepath.Join(dir, "run.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var results jsonResults
+    if err := json.Unmarshal(data, &results); err != nil {
+        t.Fatal(err)
+    }
+    if len(results.Games) != 4 {
+        t.Errorf("JSON results hold %d games, want 4", len(results.Games))
+    }
+    if !reflect.DeepEqual(results.Metadata, target.Metadata) {
+        t.Errorf("JSON metadata %v differs from the CSV's %v", results.Metadata, target.Metadata)
+    }
+    want := map[string]string{
+        "seed":       "42",
+        "hand":       "700",
+        "shuffle":    "15000",
+        "jokers":     "true",
+        "games":      "4",
+        "maxtime":    "3600000",
+        "rng-warmup": "0",
+        "variant":    "central-discard",
+        "war-down":   "2",
+        "deck-ranks": "5-A",
+        "joker-rule": "high",
+    }
+    for name, value := range want {
+        if got := target.Metadata[name]; got != value {
+            t.Errorf("metadata has %s=%q, want %q", name, got, value)
+        }
+    }
+    if target.Metadata["version"] == "" {
+        t.Error("metadata has no version")
+    }
+}
+
+// Appending 50 games to a 50-game file gives the file a 100-game run writes:
+// 100 rows numbered 1 to 100 under metadata that counts them all.
+func TestAppendContinuesTheRun(t *testing.T) {
+    dir := t.TempDir()
+    run := func(games string, file string, more ...string) {
+        t.Helper()
+        args := append([]string{"-silent", "-seed", "7", "-games", games, "-output-file", file}, more...)
+        if _, stderr, err := runMain(t, dir, args...); err != nil {
+            t.Fatalf("%v\n%s", err, stderr)
+        }
+    }
+    run("50", "grown.csv", "-checksum")
+    run("50", "grown.csv", "-append")
+    run("100", "whole.csv")
+
+    grown, err := os.ReadFile(filepath.Join(dir, "grown.csv"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    whole, err := os.ReadFile(filepath.Join(dir, "whole.csv"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(grown) != string(whole) {
+        t.Errorf("50 games appended to 50 differ from a 100-game run:\n%s\nwant:\n%s", grown, whole)
+    }
+
+    target, err := readAppendTarget(filepath.Join(dir, "grown.csv"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if target.LastGame != 100 || target.Metadata["games"] != "100" {
+        t.Errorf("appended file ends at game %d and records games=%s, want 100", target.LastGame, target.Metadata["games"])
+    }
+    if _, ok := target.Metadata["checksum"]; ok {
+        t.Error("appended file keeps the checksum of its first 50 games")
+    }
+    rows := 0
+    for _, line := range strings.Split(string(grown), "\n") {
+        if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Game Number") {
+            continue
+        }
+        rows++
+        if number := strings.SplitN(line, ",", 2)[0]; number != strconv.Itoa(rows) {
+            t.Fatalf("row %d is game %s", rows, number)
+        }
+    }
+    if rows != 100 {
+        t.Errorf("appended file has %d rows, want 100", rows)
+    }
+}
+
+// A run played by other rules, or missing a rule the file's run had, is
+// refused rather than appended, and the file is left as it was.
+func TestAppendRefusesADifferentRun(t *testing.T) {
+    for _, different := range [][]string{
+        {"-seed", "8"},
+        {"-variant", "single-pile"},
+        {"-war-down", "1"},
+        {"-rng-warmup", "2"},
+        {"-joker-rule", "wild", "-jokers"},
+        {"-deck-ranks", "3-A"},
+        {"-max-war-depth", "5"},
+        {"-fair-deal"},
+    } {
+        dir := t.TempDir()
+        if _, stderr, err := runMain(t, dir, "-silent", "-seed", "7", "-games", "5", "-output-file", "run.csv"); err != nil {
+            t.Fatalf("%v\n%s", err, stderr)
+        }
+        before, _ := os.ReadFile(filepath.Join(dir, "run.csv"))
+        args := append([]string{"-silent", "-seed", "7", "-games", "5", "-output-file", "run.csv", "-append"}, different...)
+        _, stderr, err := runMain(t, dir, args...)
+        if err == nil || !strings.Contains(stderr, "cannot append") {
+            t.Errorf("appending with %v: err %v, stderr %q; want it refused", different, err, stderr)
+        }
+        if after, _ := os.ReadFile(filepath.Join(dir, "run.csv")); string(after) != string(before) {
+            t.Errorf("refused append with %v changed the file", different)
+        }
+    }
+
+    target := &appendTarget{Metadata: map[string]string{"match-wins": "3"}}
+    for _, line := range newRunMetadata(testConfig()).lines() {
+        name, value, _ := strings.Cut(line, "=")
+        if name != "match-wins" {
+            target.Metadata[name] = value
+        }
+    }
+    columns, _ := columnsForPreset("standard")
+    target.Columns = make([]string, len(columns))
+    for i, column := range columns {
+        target.Columns[i] = column.Name
+    }
+    if err := target.check(newRunMetadata(testConfig()), columns); err == nil || !strings.Contains(err.Error(), "match-wins") {
+        t.Errorf("appending to a file with a rule this run lacks: %v", err)
+    }
+    delete(target.Metadata, "match-wins")
+    if err := target.check(newRunMetadata(testConfig()), columns); err != nil {
+        t.Errorf("appending the same run: %v", err)
+    }
+}
+
+// Each game's longest war goes in the full CSV columns and the JSON, and
+// the summary has a line with its percentiles.
+func TestMaxWarDepthIsReported(t *testing.T) {
+    stats := []war.GameStats{{GameNumber: 1, Tricks: 10, MaxWarDepth: 4}, {GameNumber: 2, Tricks: 12, MaxWarDepth: 2}}
+    columns, _ := columnsForPreset("full")
+    var rows bytes.Buffer
+    if err := (CSVWriter{Columns: columns}).WriteResults(&rows, stats); err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(rows.String(), ",Total War Depth,Max War Depth,") || !strings.Contains(rows.String(), "\n1,0,10,0,0,0,4,") {
+        t.Errorf("full columns lack Max War Depth:\n%s", rows.String())
+    }
+    var decoded jsonResults
+    var js bytes.Buffer
+    JSONWriter{}.WriteResults(&js, stats)
+    if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || decoded.Games[0].MaxWarDepth != 4 {
+        t.Errorf("JSON gives MaxWarDepth %+v (%v), want 4", decoded.Games, err)
+    }
+    var summary bytes.Buffer
+    printSummaryStatistics(&summary, stats)
+    if !strings.Contains(summary.String(), "Longest War (rounds)") {
+        t.Errorf("summary has no longest war line:\n%s", summary.String())
+    }
+}
+
+// WriteResults writes to any io.Writer in either format, and results go to
+// standard output with -output-file -, where the messages move to standard
+// error.
+func TestWriteResultsToAWriter(t *testing.T) {
+    cfg := testConfig()
+    stats := runGames(t, 3)
+    columns, _ := columnsForPreset("standard")
+
+    var csvOut bytes.Buffer
+    if err := WriteResults(&csvOut, stats, Format{Name: "csv", Columns: columns, Metadata: newRunMetadata(cfg)}); err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSuffix(csvOut.String(), "\n"), "\n")
+    if !strings.HasPrefix(lines[0], "# seed=1") || !strings.HasPrefix(lines[len(lines)-4], "Game Number,") {
+        t.Errorf("CSV does not have the metadata, header and 3 rows:\n%s", csvOut.String())
+    }
+    for i, row := range lines[len(lines)-3:] {
+        if !strings.HasPrefix(row, strconv.Itoa(i+1)+",") {
+            t.Errorf("CSV row %d is %q", i+1, row)
+        }
+    }
+
+    var jsonOut bytes.Buffer
+    if err := WriteResults(&jsonOut, stats, Format{Name: "json", Metadata: newRunMetadata(cfg)}); err != nil {
+        t.Fatal(err)
+    }
+    var read jsonResults
+    if err := json.Unmarshal(jsonOut.Bytes(), &read); err != nil || !reflect.DeepEqual(read.Games, stats) || read.Metadata["seed"] != "1" {
+        t.Errorf("JSON read back as %+v (%v)", read, err)
+    }
+
+    dir := t.TempDir()
+    stdout, stderr, err := runMain(t, dir, "-games", "3", "-seed", "1", "-output-file", "-")
+    if err != nil {
+        t.Fatalf("%v\n%s", err, stderr)
+    }
+    if !strings.HasPrefix(stdout, "# seed=1\n") || !strings.Contains(stdout, "\nGame Number,") || strings.Contains(stdout, "Total number of games played") {
+        t.Errorf("standard output is not just the CSV:\n%s", stdout)
+    }
+    if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+        t.Errorf("results streamed to standard output also wrote %v", files)
+    }
+}
+
+// A -compress results file decompresses to the CSV an uncompressed run
+// writes, so the gzip trailer is there.
+func TestCompressedResults(t *testing.T) {
+    dir := t.TempDir()
+    for _, args := range [][]string{
+        {"-silent", "-games", "20", "-seed", "3", "-compress"},
+        {"-silent", "-games", "20", "-seed", "3"},
+    } {
+        if _, stderr, err := runMain(t, dir, args...); err != nil {
+            t.Fatalf("%v\n%s", err, stderr)
+        }
+    }
+    compressed, _ := filepath.Glob(filepath.Join(dir, "*.csv.gz"))
+    plain, _ := filepath.Glob(filepath.Join(dir, "*.csv"))
+    if len(compressed) != 1 || len(plain) != 1 || compressed[0] != plain[0]+".gz" {
+        t.Fatalf("runs wrote %v and %v, want one file and its .gz", plain, compressed)
+    }
+
+    file, err := os.Open(compressed[0])
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    reader, err := gzip.NewReader(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    decompressed, err := io.ReadAll(reader) // Fails on a missing trailer
+    if err != nil {
+        t.Fatal(err)
+    }
+    want, _ := os.ReadFile(plain[0])
+    if string(decompressed) != string(want) {
+        t.Errorf("decompressed results differ from the uncompressed run's:\n%s\nwant:\n%s", decompressed, want)
+    }
+}
+
+// The cards held when a game stops go in the full CSV columns and the JSON.
+func TestFinalCardsAreWritten(t *testing.T) {
+    stats := []war.GameStats{{GameNumber: 1, Tricks: 50, TerminationReason: "trick-limit", FinalCardsA: 31, FinalCardsB: 21}}
+    columns, _ := columnsForPreset("full")
+    var rows bytes.Buffer
+    if err := (CSVWriter{Columns: columns, RowsOnly: true}).WriteResults(&rows, stats); err != nil {
+        t.Fatal(err)
+    }
+    names := make([]string, len(columns))
+    for i, column := range columns {
+        names[i] = column.Name
+    }
+    row := strings.Split(strings.TrimSpace(rows.String()), ",")
+    if a, b := slices.Index(names, "Final Cards A"), slices.Index(names, "Final Cards B"); a < 0 || b < 0 || row[a] != "31" || row[b] != "21" {
+        t.Errorf("full CSV row %q does not hold 31 and 21 final cards", rows.String())
+    }
+    var js bytes.Buffer
+    JSONWriter{}.WriteResults(&js, stats)
+    if !strings.Contains(js.String(), `"FinalCardsA":31,"FinalCardsB":21`) {
+        t.Errorf("JSON lacks the final cards: %s", js.String())
+    }
+}
+
+// The summary file holds, as JSON, every statistic of the printed summary
+// with its average, spread and percentiles, the outcomes and how the games
+// ended.
+func TestSummaryFile(t *testing.T) {
+    stats := runGames(t, 30)
+    filename := filepath.Join(t.TempDir(), "summary.json")
+    if err := writeSummaryFile(filename, newSummary(stats)); err != nil {
+        t.Fatal(err)
+    }
+    written, err := os.ReadFile(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var summary map[string]any
+    if err := json.Unmarshal(written, &summary); err != nil {
+        t.Fatal(err)
+    }
+    for _, key := range []string{"Games", "Outcomes", "Statistics", "DealWinCorrelation", "TerminationReasons"} {
+        if _, ok := summary[key]; !ok {
+            t.Errorf("summary file lacks %s:\n%s", key, written)
+        }
+    }
+    if summary["Games"] != 30.0 {
+        t.Errorf("summary file has %v games, want 30", summary["Games"])
+    }
+    outcomes, _ := summary["Outcomes"].(map[string]any)
+    for _, key := range []string{"WinsA", "WinsB", "Draws", "Unfinished", "Errors", "TimedOut"} {
+        if _, ok := outcomes[key]; !ok {
+            t.Errorf("summary outcomes lack %s: %v", key, outcomes)
+        }
+    }
+
+    statistics, _ := summary["Statistics"].([]any)
+    names := make(map[string]bool)
+    for _, s := range statistics {
+        statistic, _ := s.(map[string]any)
+        for _, key := range []string{"Name", "Avg", "Min", "Max", "StdDev", "Median", "P90", "P99"} {
+            if _, ok := statistic[key]; !ok {
+                t.Errorf("statistic %v lacks %s", statistic["Name"], key)
+            }
+        }
+        names[fmt.Sprint(statistic["Name"])] = true
+    }
+    var printed bytes.Buffer
+    printSummaryStatistics(&printed, stats)
+    for _, statistic := range summaryStatistics(stats) {
+        if !names[statistic.Name] || !strings.Contains(printed.String(), statistic.Name+": Avg") {
+            t.Errorf("%s is not in both the summary file and the printed summary", statistic.Name)
+        }
+    }
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "slices"
+    "strconv"
+    "strings"
+
+    "wargames/war"
+)
+
+// A run sweep plays the whole run once for each value of a parameter and
+// writes one row of summary statistics per run, to show how the statistics
+// move with the parameter, or, for a seed sweep, how much a run of -games
+// games varies by chance alone.
+
+// sweepRun is one run of a sweep: the value it was played with and its games.
+type sweepRun struct {
+    Value string
+    Stats []war.GameStats
+}
+
+// parseSeedSweep turns "start:end" or "start:end:step" into the seeds start,
+// start+step, ... up to end, the step defaulting to 1.
+func parseSeedSweep(spec string) ([]int64, error) {
+    parts := strings.Split(spec, ":")
+    if len(parts) < 2 || len(parts) > 3 {
+        return nil, fmt.Errorf("invalid seed sweep %q (want start:end or start:end:step)", spec)
+    }
+    values := []int64{0, 0, 1}
+    for i, part := range parts {
+        v, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid seed sweep %q: %v", spec, err)
+        }
+        values[i] = v
+    }
+    start, end, step := values[0], values[1], values[2]
+    if end < start || step <= 0 {
+        return nil, fmt.Errorf("invalid seed sweep %q (need start <= end and step > 0)", spec)
+    }
+
+    var seeds []int64
+    for seed := start; seed <= end; seed += step {
+        if seed != 0 { // Seed 0 stands for a time-based seed
+            seeds = append(seeds, seed)
+        }
+    }
+    if len(seeds) == 0 {
+        return nil, fmt.Errorf("invalid seed sweep %q (no seeds other than 0)", spec)
+    }
+    return seeds, nil
+}
+
+// runSeedSweep plays the run once with each seed, prints a line for each and
+// writes their summary rows to filename.
+func runSeedSweep(w io.Writer, cfg Config, seeds []int64, filename string) error {
+    runs := make([]sweepRun, len(seeds))
+    for i, seed := range seeds {
+        logger.Infof("Playing %d games with seed %d...", cfg.Games, seed)
+        stats := war.RunSimulations(cfg.Games, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, seed, cfg.Workers, cfg.Options.Options)
+        runs[i] = sweepRun{Value: strconv.FormatInt(seed, 10), Stats: stats}
+    }
+    printSweep(w, "Seed", runs)
+    return writeSweep(filename, "Seed", runs)
+}
+
+// sweepParameters lists the parameters -sweep can vary, by flag name.
+var sweepParameters = []string{"hand", "shuffle", "maxtime"}
+
+// parseParameterSweep turns "name=v1,v2,..." into the swept parameter's name
+// and its values.
+func parseParameterSweep(spec string) (string, []int, error) {
+    name, list, ok := strings.Cut(spec, "=")
+    if !ok {
+        return "", nil, fmt.Errorf("invalid sweep %q (want name=value,value,...)", spec)
+    }
+    name = strings.TrimSpace(name)
+    if !slices.Contains(sweepParameters, name) {
+        return "", nil, fmt.Errorf("cannot sweep %q (want one of %s)", name, strings.Join(sweepParameters, ", "))
+    }
+    var values []int
+    for _, part := range strings.Split(list, ",") {
+        v, err := strconv.Atoi(strings.TrimSpace(part))
+        if err != nil {
+            return "", nil, fmt.Errorf("invalid sweep %q: %v", spec, err)
+        }
+        values = append(values, v)
+    }
+    return name, values, nil
+}
+
+// withParameter returns cfg with the swept parameter name set to value.
+func withParameter(cfg Config, name string, value int) Config {
+    switch name {
+    case "hand":
+        cfg.HandTime = value
+    case "shuffle":
+        cfg.ShuffleTime = value
+    case "maxtime":
+        cfg.MaxGameTime = value
+    }
+    return cfg
+}
+
+// runParameterSweep plays the run once with each value of the parameter
+// name, all with the same seed so that the same deals are played, prints a
+// line for each and writes their summary rows to filename.
+func runParameterSweep(w io.Writer, cfg Config, name string, values []int, filename string) error {
+    runs := make([]sweepRun, len(values))
+    for i, value := range values {
+        logger.Infof("Playing %d games with %s %d...", cfg.Games, name, value)
+        run := withParameter(cfg, name, value)
+        stats := war.RunSimulations(run.Games, run.HandTime, run.ShuffleTime, run.IncludeJokers, run.MaxGameTime, run.Seed, run.Workers, run.Options.Options)
+        runs[i] = sweepRun{Value: strconv.Itoa(value), Stats: stats}
+    }
+    printSweep(w, name, runs)
+    return writeSweep(filename, name, runs)
+}
+
+// printSweep prints the headline numbers of every run of a sweep over the
+// parameter named key.
+func printSweep(w io.Writer, key string, runs []sweepRun) {
+    fmt.Fprintf(w, "%12s %10s %10s %10s %10s\n", key, "A Wins", "B Wins", "Tricks", "Minutes")
+    for _, run := range runs {
+        outcomes := countOutcomes(run.Stats)
+        averages := make(map[string]float64)
+        for _, statistic := range summaryStatistics(run.Stats) {
+            averages[statistic.Name] = statistic.Avg
+        }
+        fmt.Fprintf(w, "%12s %9.2f%% %9.2f%% %10.1f %10.2f\n", run.Value,
+            outcomes.Percent(outcomes.WinsA), outcomes.Percent(outcomes.WinsB),
+            averages["Tricks"], averages["Game Time (minutes)"])
+    }
+}
+
+// writeSweep writes one row per run of a sweep to filename: the swept value,
+// the mean, standard deviation and median of every summary statistic, and
+// the outcomes as percentages of the games played.
+func writeSweep(filename, key string, runs []sweepRun) error {
+    file, err := createOutputFile(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    headers := []string{key, "Games"}
+    for _, statistic := range summaryStatistics(nil) {
+        headers = append(headers, statistic.Name+" Avg", statistic.Name+" StdDev", statistic.Name+" Median")
+    }
+    headers = append(headers, "Finished %", "Player A Wins %", "Player B Wins %", "Draws %", "Unfinished %", "Errors %")
+    if err := writer.Write(headers); err != nil {
+        return err
+    }
+
+    format := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+    for _, run := range runs {
+        row := []string{run.Value, strconv.Itoa(len(run.Stats))}
+        for _, statistic := range summaryStatistics(run.Stats) {
+            row = append(row, format(statistic.Avg), format(statistic.StdDev), format(statistic.Median))
+        }
+        o := countOutcomes(run.Stats)
+        for _, count := range []int{o.Finished(), o.WinsA, o.WinsB, o.Draws, o.Unfinished, o.Errors} {
+            row = append(row, format(o.Percent(count)))
+        }
+        if err := writer.Write(row); err != nil {
+            return err
+        }
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return err
+    }
+    return file.Close()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+    "bytes"
+    "encoding/csv"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "testing"
+)
+
+func TestSeedSweep(t *testing.T) {
+    defer quietLogger()()
+    seeds, err := parseSeedSweep("1:3")
+    if err != nil || !slices.Equal(seeds, []int64{1, 2, 3}) {
+        t.Fatalf("1:3 gives seeds %v (%v), want 1, 2 and 3", seeds, err)
+    }
+    if apart, _ := parseSeedSweep("-10:25:10"); !slices.Equal(apart, []int64{-10, 10, 20}) {
+        t.Errorf("-10:25:10 gives seeds %v, want -10, 10 and 20 with seed 0 left out", apart)
+    }
+    for _, spec := range []string{"5", "7:5", "1:5:0", "0:0", "a:b"} {
+        if _, err := parseSeedSweep(spec); err == nil {
+            t.Errorf("seed sweep %q was accepted", spec)
+        }
+    }
+
+    cfg := testConfig()
+    filename := filepath.Join(t.TempDir(), "sweep.csv")
+    var out bytes.Buffer
+    if err := runSeedSweep(&out, cfg, seeds, filename); err != nil {
+        t.Fatal(err)
+    }
+    file, err := os.Open(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    rows, err := csv.NewReader(file).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(rows) != 4 || rows[0][0] != "Seed" || rows[0][1] != "Games" {
+        t.Fatalf("sweep file has %d rows under %v, want a header and 3 summary rows", len(rows), rows[0])
+    }
+    for i, row := range rows[1:] {
+        if want := []string{"1", "2", "3"}[i]; row[0] != want || row[1] != "10" || len(row) != len(rows[0]) {
+            t.Errorf("summary row %d is for seed %s with %s games and %d fields, want seed %s with 10 and %d", i+1, row[0], row[1], len(row), want, len(rows[0]))
+        }
+    }
+    // Adjacent seeds play different games, so every statistic's mean and
+    // spread comes out different from run to run.
+    for _, name := range []string{"Tricks Avg", "Tricks StdDev", "Game Time (minutes) Avg"} {
+        column := slices.Index(rows[0], name)
+        if column < 0 {
+            t.Fatalf("sweep header %v lacks %s", rows[0], name)
+        }
+        if a, b, c := rows[1][column], rows[2][column], rows[3][column]; a == b || b == c || a == c {
+            t.Errorf("%s is %s, %s and %s for seeds 1, 2 and 3", name, a, b, c)
+        }
+    }
+}
+
+// A sweep over two hand times writes a row for each, played from the same
+// deals, and the longer hand time makes the games take longer.
+func TestParameterSweep(t *testing.T) {
+    defer quietLogger()()
+    name, values, err := parseParameterSweep("hand=100, 1000")
+    if err != nil || name != "hand" || !slices.Equal(values, []int{100, 1000}) {
+        t.Fatalf("hand=100, 1000 parses as %s %v (%v)", name, values, err)
+    }
+    for _, spec := range []string{"hand", "colour=1,2", "hand=1,x"} {
+        if _, _, err := parseParameterSweep(spec); err == nil {
+            t.Errorf("sweep %q was accepted", spec)
+        }
+    }
+
+    filename := filepath.Join(t.TempDir(), "sweep.csv")
+    var out bytes.Buffer
+    if err := runParameterSweep(&out, testConfig(), name, values, filename); err != nil {
+        t.Fatal(err)
+    }
+    file, err := os.Open(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer file.Close()
+    rows, err := csv.NewReader(file).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(rows) != 3 || rows[0][0] != "hand" || rows[1][0] != "100" || rows[2][0] != "1000" {
+        t.Fatalf("sweep file rows %v, want a header and rows for hand 100 and 1000", rows)
+    }
+    minutes := slices.Index(rows[0], "Game Time (minutes) Avg")
+    tricks := slices.Index(rows[0], "Tricks Avg")
+    if minutes < 0 || tricks < 0 {
+        t.Fatalf("sweep header %v lacks the game time and tricks", rows[0])
+    }
+    if rows[1][tricks] != rows[2][tricks] {
+        t.Errorf("hand times played different games: %s and %s tricks on average", rows[1][tricks], rows[2][tricks])
+    }
+    if mustParseFloat(t, rows[1][minutes]) >= mustParseFloat(t, rows[2][minutes]) {
+        t.Errorf("games at hand 100 took %s minutes, at hand 1000 %s", rows[1][minutes], rows[2][minutes])
+    }
+    if !bytes.Contains(out.Bytes(), []byte("        hand")) || !bytes.Contains(out.Bytes(), []byte("\n         100 ")) || !bytes.Contains(out.Bytes(), []byte("\n        1000 ")) {
+        t.Errorf("sweep printout lacks a line per hand time:\n%s", out.String())
+    }
+}
+
+func mustParseFloat(t *testing.T, s string) float64 {
+    t.Helper()
+    v, err := strconv.ParseFloat(s, 64)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return v
+}
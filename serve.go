@@ -0,0 +1,99 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "wargames/war"
+)
+
+// A server (-serve :8080) plays runs on request over HTTP: GET
+// /simulate?games=1000&seed=42 plays the run with the server's other flags
+// and returns its summary as JSON, and with per-game=true the stats of every
+// game too.
+
+// maxServeGames caps the games of a single request, which are all held in
+// memory and sent back at once.
+const maxServeGames = 100000
+
+// simulateResponse is the JSON returned by /simulate.
+type simulateResponse struct {
+    Seed  int64
+    Summary
+    Stats []war.GameStats `json:",omitempty"` // With per-game=true
+}
+
+// runServer serves /simulate on addr until the server fails.
+func runServer(addr string, cfg Config) error {
+    mux := http.NewServeMux()
+    mux.Handle("/simulate", simulateHandler(cfg))
+    logger.Infof("Serving simulations on %s", addr)
+    return http.ListenAndServe(addr, mux)
+}
+
+// simulateHandler plays the run cfg describes, with the games and seed of
+// the request in place of cfg's. A request without a seed gets a time-based
+// one, returned in the response.
+func simulateHandler(cfg Config) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+            return
+        }
+        query := r.URL.Query()
+
+        games := cfg.Games
+        if s := query.Get("games"); s != "" {
+            n, err := strconv.Atoi(s)
+            if err != nil || n < 1 || n > maxServeGames {
+                http.Error(w, fmt.Sprintf("games must be from 1 to %d", maxServeGames), http.StatusBadRequest)
+                return
+            }
+            games = n
+        }
+        seed := cfg.Seed
+        if s := query.Get("seed"); s != "" {
+            n, err := strconv.ParseInt(s, 10, 64)
+            if err != nil {
+                http.Error(w, fmt.Sprintf("invalid seed %q", s), http.StatusBadRequest)
+                return
+            }
+            seed = n
+        }
+        if seed == 0 {
+            seed = time.Now().UnixNano()
+        }
+        perGame := false
+        if s := query.Get("per-game"); s != "" {
+            b, err := strconv.ParseBool(s)
+            if err != nil {
+                http.Error(w, fmt.Sprintf("invalid per-game %q", s), http.StatusBadRequest)
+                return
+            }
+            perGame = b
+        }
+
+        opts := cfg.Options.Options
+        opts.Context = r.Context() // Stop playing if the client goes away
+        response := simulateResponse{Seed: seed}
+        if perGame {
+            response.Stats = war.RunSimulations(games, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, seed, cfg.Workers, opts)
+            response.Summary = newSummary(response.Stats)
+        } else {
+            // Only the summary is sent, so it is built as the games finish.
+            aggregator := NewStatsAggregator(seed)
+            war.StreamGameRange(0, games, cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, seed, cfg.Workers, opts, aggregator.Games())
+            response.Summary = aggregator.Close()
+        }
+        if r.Context().Err() != nil {
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        if err := json.NewEncoder(w).Encode(response); err != nil {
+            logger.Warnf("writing simulation response: %v", err)
+        }
+    })
+}
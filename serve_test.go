@@ -0,0 +1,60 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+
+    "wargames/war"
+)
+
+// /simulate returns the JSON summary of the run requested, which is the run
+// the same games and seed play from the command line, and with per-game=true
+// every game's stats too.
+func TestSimulateHandler(t *testing.T) {
+    handler := simulateHandler(testConfig())
+    get := func(query string) (*httptest.ResponseRecorder, simulateResponse) {
+        t.Helper()
+        recorder := httptest.NewRecorder()
+        handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/simulate?"+query, nil))
+        var response simulateResponse
+        if recorder.Code == http.StatusOK {
+            if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+                t.Fatalf("%s: invalid JSON %v:\n%s", query, err, recorder.Body.String())
+            }
+        }
+        return recorder, response
+    }
+
+    recorder, response := get("games=50&seed=42")
+    if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/json" {
+        t.Fatalf("status %d, content type %q", recorder.Code, recorder.Header().Get("Content-Type"))
+    }
+    stats := war.RunSimulations(50, 500, 15000, false, 3600000, 42, 1, war.DefaultOptions())
+    want := newSummary(stats)
+    if response.Seed != 42 || response.Games != 50 || response.Outcomes != want.Outcomes || response.Stats != nil {
+        t.Errorf("summary %+v, want %+v for seed 42 and no games", response.Summary, want)
+    }
+
+    _, perGame := get("games=5&seed=42&per-game=true")
+    if len(perGame.Stats) != 5 || !reflect.DeepEqual(perGame.Stats, stats[:5]) {
+        t.Errorf("per-game stats %+v, want the first 5 games of the run", perGame.Stats)
+    }
+
+    if _, timed := get("games=2"); timed.Seed == 0 || timed.Games != 2 {
+        t.Errorf("request without a seed got seed %d and %d games", timed.Seed, timed.Games)
+    }
+
+    for _, query := range []string{"games=0", "games=100001", "games=lots", "seed=x", "per-game=maybe"} {
+        if recorder, _ := get(query); recorder.Code != http.StatusBadRequest {
+            t.Errorf("%s: status %d, want %d", query, recorder.Code, http.StatusBadRequest)
+        }
+    }
+    recorder = httptest.NewRecorder()
+    handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/simulate", nil))
+    if recorder.Code != http.StatusMethodNotAllowed {
+        t.Errorf("POST: status %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+    }
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+    "compress/gzip"
+    "context"
+    "io"
+    "math"
+    "math/rand"
+    "os"
+    "sort"
+
+    "wargames/war"
+)
+
+// A streamed run (-stream) never holds more than streamChunkGames games at a
+// time. Each chunk's rows are written to the results as soon as it has been
+// played, and its games are folded into running totals before they are
+// dropped. The totals give the summary's counts, means, standard deviations,
+// minimums and maximums exactly; its percentiles come from a uniform sample
+// of reservoirSize games per metric, so they are exact only for runs no
+// longer than that.
+
+const (
+    streamChunkGames = 10000
+    reservoirSize    = 100000
+)
+
+// runningStatistic accumulates one summary metric over a stream of values.
+type runningStatistic struct {
+    acc       Accumulator
+    reservoir []float64
+}
+
+// add folds v into the totals and keeps it in the reservoir with probability
+// reservoirSize over the number of values seen (Algorithm R).
+func (r *runningStatistic) add(v float64, rng *rand.Rand) {
+    r.acc.Add(v)
+    if len(r.reservoir) < reservoirSize {
+        r.reservoir = append(r.reservoir, v)
+    } else if i := rng.Intn(r.acc.Count()); i < reservoirSize {
+        r.reservoir[i] = v
+    }
+}
+
+// statistic returns the Statistic that newStatistic would give for every
+// value added, with the percentiles taken from the reservoir.
+func (r *runningStatistic) statistic(name string, precision int) Statistic {
+    sorted := append([]float64(nil), r.reservoir...)
+    sort.Float64s(sorted)
+    return newStatisticFrom(name, r.acc, sorted, precision)
+}
+
+// streamSummary is the running form of a Summary.
+type streamSummary struct {
+    outcomes Outcomes
+    reasons  map[string]int
+    metrics  []runningStatistic // One per summaryMetrics entry
+    rng      *rand.Rand         // Chooses the reservoir samples
+
+    // Sums over the games won by Player A or B for the deal correlation.
+    pairs               int
+    sumX, sumY          float64
+    sumXX, sumYY, sumXY float64
+}
+
+// newStreamSummary returns an empty summary whose reservoirs are sampled
+// with a source seeded with seed, so a streamed run is repeatable.
+func newStreamSummary(seed int64) *streamSummary {
+    return &streamSummary{
+        reasons: make(map[string]int),
+        metrics: make([]runningStatistic, len(summaryMetrics)),
+        rng:     rand.New(rand.NewSource(seed)),
+    }
+}
+
+// add folds a chunk of games into the summary.
+func (s *streamSummary) add(stats []war.GameStats) {
+    o := countOutcomes(stats)
+    s.outcomes.Games += o.Games
+    s.outcomes.WinsA += o.WinsA
+    s.outcomes.WinsB += o.WinsB
+    s.outcomes.WinsOther += o.WinsOther
+    s.outcomes.Draws += o.Draws
+    s.outcomes.Unfinished += o.Unfinished
+    s.outcomes.Errors += o.Errors
+    s.outcomes.TimedOut += o.TimedOut
+    s.outcomes.TimedOutWinsA += o.TimedOutWinsA
+    s.outcomes.TimedOutWinsB += o.TimedOutWinsB
+
+    for _, game := range stats {
+        s.reasons[game.TerminationReason]++
+        if game.Errored {
+            continue
+        }
+        for i, metric := range summaryMetrics {
+            if v, ok := metric.Value(game); ok {
+                s.metrics[i].add(v, s.rng)
+            }
+        }
+        if game.Finished && (game.Winner == 1 || game.Winner == 2) {
+            x, y := float64(game.PipsA-game.PipsB), float64(2-game.Winner)
+            s.pairs++
+            s.sumX += x
+            s.sumY += y
+            s.sumXX += x * x
+            s.sumYY += y * y
+            s.sumXY += x * y
+        }
+    }
+}
+
+// summary returns the Summary of the games added so far.
+func (s *streamSummary) summary() Summary {
+    statistics := make([]Statistic, len(summaryMetrics))
+    for i, metric := range summaryMetrics {
+        statistics[i] = s.metrics[i].statistic(metric.Name, metric.Precision)
+    }
+    return Summary{
+        Games:              s.outcomes.Games,
+        Outcomes:           s.outcomes,
+        Statistics:         statistics,
+        DealWinCorrelation: s.correlation(),
+        TerminationReasons: s.reasons,
+    }
+}
+
+// correlation is what correlation gives for the pip differences and wins.
+func (s *streamSummary) correlation() float64 {
+    if s.pairs < 2 {
+        return 0
+    }
+    n := float64(s.pairs)
+    sxx := s.sumXX - s.sumX*s.sumX/n
+    syy := s.sumYY - s.sumY*s.sumY/n
+    if sxx <= 0 || syy <= 0 {
+        return 0
+    }
+    return (s.sumXY - s.sumX*s.sumY/n) / math.Sqrt(sxx*syy)
+}
+
+// runStream plays a streamed run, writing its results to standard output
+// with -output-file - or else to the results file, and returns its summary.
+// Cancelling ctx ends the run early, as streamGames describes; the results
+// file's metadata, written before the first game, then has its games line
+// rewritten to count the games played.
+func runStream(ctx context.Context, cfg Config, columns []Column) (Summary, error) {
+    meta := newRunMetadata(cfg)
+    if cfg.OutputFile == "-" {
+        return streamGames(ctx, os.Stdout, cfg, columns, meta)
+    }
+    filename := resultsFileName(cfg)
+    file, err := createOutputFile(filename)
+    if err != nil {
+        return Summary{}, err
+    }
+    defer file.Close()
+    summary, err := streamGames(ctx, file, cfg, columns, meta)
+    if err != nil {
+        return Summary{}, err
+    }
+    if err := file.Close(); err != nil {
+        return Summary{}, err
+    }
+    if summary.Games < cfg.Games {
+        return summary, recountGames(filename, summary.Games-cfg.Games, cfg.Compress)
+    }
+    return summary, nil
+}
+
+// streamGames plays the run's games in chunks, writing each chunk's rows to
+// w as CSV, gzipped with -compress, and returns the summary of all of them.
+// When ctx is cancelled it stops after the chunk in progress, whose games
+// played to the end are kept, and still closes the gzip stream, so the
+// results and summary are those of a shorter run.
+func streamGames(ctx context.Context, w io.Writer, cfg Config, columns []Column, meta RunMetadata) (Summary, error) {
+    var gz *gzip.Writer
+    if cfg.Compress {
+        gz = gzip.NewWriter(w)
+        w = gz
+    }
+    cfg.Context = ctx
+    summary := newStreamSummary(cfg.Seed)
+    for start := 0; start < cfg.Games && ctx.Err() == nil; start += streamChunkGames {
+        games := war.RunGameRange(start, min(streamChunkGames, cfg.Games-start), cfg.HandTime, cfg.ShuffleTime, cfg.IncludeJokers, cfg.MaxGameTime, cfg.Seed, cfg.Workers, cfg.Options.Options)
+        writer := CSVWriter{Columns: columns, Metadata: meta, RowsOnly: start > 0}
+        if err := writer.WriteResults(w, games); err != nil {
+            return Summary{}, err
+        }
+        summary.add(games)
+    }
+    if gz != nil {
+        if err := gz.Close(); err != nil {
+            return Summary{}, err
+        }
+    }
+    return summary.summary(), nil
+}
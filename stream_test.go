@@ -0,0 +1,123 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "io"
+    "math"
+    "math/rand"
+    "reflect"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+// A summary folded together chunk by chunk from running totals matches the
+// one computed from every game kept.
+func TestStreamedSummaryMatchesFullRetention(t *testing.T) {
+    const games = 25000 // Three chunks, the last one partial
+    stats := war.RunSimulations(games, 500, 15000, false, 3600000, 1, 4, war.DefaultOptions())
+    stats[17] = war.GameStats{GameNumber: 18, Tricks: -1, TerminationReason: "error", Errored: true}
+
+    streamed := newStreamSummary(1)
+    for start := 0; start < games; start += streamChunkGames {
+        streamed.add(stats[start:min(start+streamChunkGames, games)])
+    }
+    sameSummary(t, streamed.summary(), newSummary(stats))
+}
+
+// cancelOnWrite cancels a context on the first write to it.
+type cancelOnWrite struct {
+    bytes.Buffer
+    cancel context.CancelFunc
+}
+
+func (w *cancelOnWrite) Write(p []byte) (int, error) {
+    w.cancel()
+    return w.Buffer.Write(p)
+}
+
+// A streamed run cancelled while its first chunk is written stops after that
+// chunk, closing the gzip stream and summarizing the games it played.
+func TestCancelledStreamKeepsItsChunks(t *testing.T) {
+    cfg := testConfig()
+    cfg.Games, cfg.Compress = 25000, true
+    columns, _ := columnsForPreset(cfg.Columns)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    w := &cancelOnWrite{cancel: cancel}
+    summary, err := streamGames(ctx, w, cfg, columns, newRunMetadata(cfg))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if summary.Games != streamChunkGames {
+        t.Errorf("summary of %d games, want the first chunk's %d", summary.Games, streamChunkGames)
+    }
+
+    gz, err := gzip.NewReader(&w.Buffer)
+    if err != nil {
+        t.Fatal(err)
+    }
+    data, err := io.ReadAll(gz)
+    if err != nil {
+        t.Fatalf("reading the cancelled run's results: %v", err)
+    }
+    rows := 0
+    for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+        if !strings.HasPrefix(line, "#") {
+            rows++
+        }
+    }
+    if rows != streamChunkGames+1 { // The header and a row per game
+        t.Errorf("%d lines of results, want a header and %d games", rows, streamChunkGames)
+    }
+}
+
+// sameSummary checks a summary built from running totals against one
+// computed from every game kept: exactly for the counts, extremes and, in a
+// run no longer than the reservoirs, the percentiles, and up to rounding for
+// the means, spreads and the deal correlation.
+func sameSummary(t *testing.T, got, want Summary) {
+    t.Helper()
+    if got.Games != want.Games || got.Outcomes != want.Outcomes || !reflect.DeepEqual(got.TerminationReasons, want.TerminationReasons) {
+        t.Errorf("counts %d %+v %v, want %d %+v %v", got.Games, got.Outcomes, got.TerminationReasons, want.Games, want.Outcomes, want.TerminationReasons)
+    }
+    close := func(a, b float64) bool { return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b)) }
+    if !close(got.DealWinCorrelation, want.DealWinCorrelation) {
+        t.Errorf("deal correlation %v, want %v", got.DealWinCorrelation, want.DealWinCorrelation)
+    }
+    if len(got.Statistics) != len(want.Statistics) {
+        t.Fatalf("%d statistics, want %d", len(got.Statistics), len(want.Statistics))
+    }
+    for i, s := range got.Statistics {
+        w := want.Statistics[i]
+        if s.Name != w.Name || s.Min != w.Min || s.Max != w.Max || s.Median != w.Median || s.P90 != w.P90 || s.P99 != w.P99 ||
+            !close(s.Avg, w.Avg) || !close(s.StdDev, w.StdDev) {
+            t.Errorf("%+v, want %+v", s, w)
+        }
+    }
+}
+
+// Past reservoirSize values the reservoir stays that size and holds a fair
+// sample, while the totals still cover every value.
+func TestReservoirStaysBounded(t *testing.T) {
+    var r runningStatistic
+    rng := rand.New(rand.NewSource(1))
+    const n = 3 * reservoirSize
+    for i := range n {
+        r.add(float64(i), rng)
+    }
+    if len(r.reservoir) != reservoirSize || r.acc.Count() != n {
+        t.Fatalf("reservoir holds %d values of %d counted, want %d of %d", len(r.reservoir), r.acc.Count(), reservoirSize, n)
+    }
+    s := r.statistic("Value", 0)
+    if s.Min != 0 || s.Max != n-1 || s.Avg != (n-1)/2.0 {
+        t.Errorf("totals give min %v, max %v and mean %v over 0 to %d", s.Min, s.Max, s.Avg, n-1)
+    }
+    // The sample's median is within a percent of the true one.
+    if math.Abs(s.Median-(n-1)/2.0) > 0.01*n {
+        t.Errorf("sampled median %v, want about %v", s.Median, (n-1)/2.0)
+    }
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "sort"
+
+    "wargames/war"
+)
+
+// A game's surprise is how unlikely its winner was given the deal, in bits:
+// -log2 of the probability a model of the opening hands gave the player who
+// won. The model is a logistic regression of A winning on A's pip advantage
+// and high-card differential, fitted to the decided games of the run itself,
+// so an upset by a clearly weaker hand scores high and an expected win scores
+// close to zero.
+
+type gameSurprise struct {
+    GameNumber int
+    Surprise   float64
+}
+
+// surpriseScores scores every game that one of the players won. The stats
+// must include the initial deals.
+func surpriseScores(stats []war.GameStats) []gameSurprise {
+    var features [][2]float64
+    var winsA []float64
+    var decided []war.GameStats
+    for _, game := range stats {
+        if game.Winner != 1 && game.Winner != 2 {
+            continue
+        }
+        features = append(features, surpriseFeatures(game))
+        winsA = append(winsA, float64(2-game.Winner))
+        decided = append(decided, game)
+    }
+
+    weights := fitLogistic(features, winsA)
+    scores := make([]gameSurprise, len(decided))
+    for i, game := range decided {
+        pA := logistic(weights[0]*features[i][0] + weights[1]*features[i][1])
+        pWinner := pA
+        if game.Winner == 2 {
+            pWinner = 1 - pA
+        }
+        scores[i] = gameSurprise{GameNumber: game.GameNumber, Surprise: -math.Log2(pWinner)}
+    }
+    return scores
+}
+
+func surpriseFeatures(game war.GameStats) [2]float64 {
+    return [2]float64{float64(game.PipsA - game.PipsB), float64(highCards(game.InitialDealA) - highCards(game.InitialDealB))}
+}
+
+func logistic(x float64) float64 {
+    return 1 / (1 + math.Exp(-x))
+}
+
+// fitLogistic fits weights without an intercept, since the deal is symmetric
+// between the players, by Newton's method. A small ridge penalty keeps the
+// weights finite when the outcome is perfectly separated by the features.
+func fitLogistic(x [][2]float64, y []float64) [2]float64 {
+    const ridge = 1e-3
+    var w [2]float64
+    for iter := 0; iter < 50; iter++ {
+        g := [2]float64{-ridge * w[0], -ridge * w[1]}
+        h := [2][2]float64{{ridge, 0}, {0, ridge}}
+        for i := range x {
+            p := logistic(w[0]*x[i][0] + w[1]*x[i][1])
+            for a := 0; a < 2; a++ {
+                g[a] += (y[i] - p) * x[i][a]
+                for b := 0; b < 2; b++ {
+                    h[a][b] += p * (1 - p) * x[i][a] * x[i][b]
+                }
+            }
+        }
+        det := h[0][0]*h[1][1] - h[0][1]*h[1][0]
+        if det == 0 {
+            break
+        }
+        step := [2]float64{
+            (h[1][1]*g[0] - h[0][1]*g[1]) / det,
+            (h[0][0]*g[1] - h[1][0]*g[0]) / det,
+        }
+        w[0] += step[0]
+        w[1] += step[1]
+        if math.Abs(step[0])+math.Abs(step[1]) < 1e-9 {
+            break
+        }
+    }
+    return w
+}
+
+// printSurpriseReport prints the distribution of surprise scores and the top
+// most surprising games.
+func printSurpriseReport(w io.Writer, stats []war.GameStats, top int) {
+    scores := surpriseScores(stats)
+    if len(scores) == 0 {
+        fmt.Fprintln(w, "Surprise: no decided games")
+        return
+    }
+    values := make([]float64, len(scores))
+    for i, s := range scores {
+        values[i] = s.Surprise
+    }
+    printStatistic(w, newStatistic("Surprise (bits)", values, 2))
+
+    sort.SliceStable(scores, func(i, j int) bool { return scores[i].Surprise > scores[j].Surprise })
+    fmt.Fprintln(w, "Most surprising games:")
+    for _, s := range scores[:min(top, len(scores))] {
+        game := stats[s.GameNumber-stats[0].GameNumber] // An appended run starts after game 1
+        fmt.Fprintf(w, "  Game %d (seed %d): %.2f bits (winner %d, pips A %d vs B %d)\n", s.GameNumber, game.Seed, s.Surprise, game.Winner, game.PipsA, game.PipsB)
+    }
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "bytes"
+    "math"
+    "strings"
+    "testing"
+
+    "wargames/war"
+)
+
+// A game won by a clearly weaker opening hand is an upset, and scores high;
+// a win by the clearly stronger hand scores low.
+func TestSurpriseOfAnUpset(t *testing.T) {
+    opts := war.DefaultOptions()
+    opts.RecordDeal = true
+    stats := war.RunSimulations(400, 500, 15000, false, 3600000, 9, 2, opts)
+
+    byGame := make(map[int]float64)
+    for _, s := range surpriseScores(stats) {
+        byGame[s.GameNumber] = s.Surprise
+    }
+    // The deal only tilts the odds, so even an upset is not very unlikely:
+    // it is enough that every clear upset gave its winner worse than even
+    // odds and scores above every clearly expected win.
+    minUpset, maxExpected := math.Inf(1), math.Inf(-1)
+    for _, game := range stats {
+        f := surpriseFeatures(game)
+        weakerWon := game.Winner == 1 && f[0] <= -30 && f[1] < 0 || game.Winner == 2 && f[0] >= 30 && f[1] > 0
+        strongerWon := game.Winner == 1 && f[0] >= 30 && f[1] > 0 || game.Winner == 2 && f[0] <= -30 && f[1] < 0
+        switch {
+        case weakerWon:
+            minUpset = min(minUpset, byGame[game.GameNumber])
+        case strongerWon:
+            maxExpected = max(maxExpected, byGame[game.GameNumber])
+        }
+    }
+    if math.IsInf(minUpset, 0) || math.IsInf(maxExpected, 0) {
+        t.Fatal("run had no clear upset or no clearly expected win")
+    }
+    if minUpset <= 1 || minUpset <= maxExpected {
+        t.Errorf("clear upsets scored from %.2f bits and expected wins up to %.2f, want upsets above 1 bit and above every expected win", minUpset, maxExpected)
+    }
+}
+
+func TestSurpriseReportListsTheUpsetFirst(t *testing.T) {
+    // A wins with more pips and more high cards, except in game 5.
+    hand := func(rank int) []war.Card { return []war.Card{{Rank: rank}, {Rank: rank}} }
+    var stats []war.GameStats
+    for n := 1; n <= 8; n++ {
+        game := war.GameStats{GameNumber: n, Seed: int64(100 + n), Winner: 1, PipsA: 200 + n, PipsB: 180 - n, InitialDealA: hand(14), InitialDealB: hand(3)}
+        if n%4 == 0 {
+            game.Winner, game.PipsA, game.PipsB = 2, 190, 195
+        }
+        if n == 5 {
+            game.Winner = 2
+        }
+        stats = append(stats, game)
+    }
+    var buf bytes.Buffer
+    printSurpriseReport(&buf, stats, 1)
+    if want := "Most surprising games:\n  Game 5 (seed 105):"; !strings.Contains(buf.String(), want) {
+        t.Errorf("report does not begin its list with %q:\n%s", want, buf.String())
+    }
+}
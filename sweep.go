@@ -0,0 +1,81 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "wargames/war"
+)
+
+// A maxtime sweep plays every game once with the largest cutoff and reads the
+// finish rate at each smaller cutoff off the games' last time checks. Play
+// does not depend on the cutoff until it is reached, so a game that ended on
+// its own without ever checking the time at m or later would have ended the
+// same way with -maxtime m. Its length is no guide: the last trick's
+// reshuffle or war can take it past m after the last check.
+
+// parseMaxTimeSweep turns "start,stop,step" into the cutoffs start,
+// start+step, ... up to and including stop.
+func parseMaxTimeSweep(spec string) ([]int, error) {
+    parts := strings.Split(spec, ",")
+    if len(parts) != 3 {
+        return nil, fmt.Errorf("invalid maxtime sweep %q (want start,stop,step)", spec)
+    }
+    var values [3]int
+    for i, part := range parts {
+        v, err := strconv.Atoi(strings.TrimSpace(part))
+        if err != nil {
+            return nil, fmt.Errorf("invalid maxtime sweep %q: %v", spec, err)
+        }
+        values[i] = v
+    }
+    start, stop, step := values[0], values[1], values[2]
+    if start <= 0 || step <= 0 || stop < start {
+        return nil, fmt.Errorf("invalid maxtime sweep %q (need 0 < start <= stop and step > 0)", spec)
+    }
+
+    var cutoffs []int
+    for m := start; m <= stop; m += step {
+        cutoffs = append(cutoffs, m)
+    }
+    return cutoffs, nil
+}
+
+// finishedNaturally reports whether a game ended by the rules rather than by
+// running out of time or tricks.
+func finishedNaturally(game war.GameStats) bool {
+    switch game.TerminationReason {
+    case "exhaustion", "simultaneous", "draw", "early-draw":
+        return true
+    }
+    return false
+}
+
+// finishRates returns, for each cutoff in milliseconds, the fraction of games
+// that would have ended naturally with that maxtime.
+func finishRates(stats []war.GameStats, cutoffs []int) []float64 {
+    rates := make([]float64, len(cutoffs))
+    if len(stats) == 0 {
+        return rates
+    }
+    for i, cutoff := range cutoffs {
+        finished := 0
+        for _, game := range stats {
+            if finishedNaturally(game) && game.LastTimeCheck.Milliseconds() < int64(cutoff) {
+                finished++
+            }
+        }
+        rates[i] = float64(finished) / float64(len(stats))
+    }
+    return rates
+}
+
+func printMaxTimeSweep(w io.Writer, stats []war.GameStats, cutoffs []int) {
+    fmt.Fprintln(w, "\nFinish rate by maxtime:")
+    fmt.Fprintf(w, "%12s %12s\n", "Maxtime (ms)", "Finished")
+    for i, rate := range finishRates(stats, cutoffs) {
+        fmt.Fprintf(w, "%12d %11.2f%%\n", cutoffs[i], rate*100)
+    }
+}
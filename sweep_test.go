@@ -0,0 +1,50 @@
+package main
+
+import (
+    "testing"
+
+    "wargames/war"
+)
+
+func TestFinishRateRisesWithMaxtime(t *testing.T) {
+    cutoffs, err := parseMaxTimeSweep("300000,3600000,300000")
+    if err != nil {
+        t.Fatal(err)
+    }
+    stats := war.RunSimulations(300, 500, 15000, false, cutoffs[len(cutoffs)-1], 4, 4, war.DefaultOptions())
+    rates := finishRates(stats, cutoffs)
+    for i := 1; i < len(rates); i++ {
+        if rates[i] < rates[i-1] {
+            t.Errorf("finish rate falls from %.3f at %d ms to %.3f at %d ms", rates[i-1], cutoffs[i-1], rates[i], cutoffs[i])
+        }
+    }
+    if rates[0] >= rates[len(rates)-1] {
+        t.Errorf("finish rate %.3f at %d ms is no lower than %.3f at %d ms", rates[0], cutoffs[0], rates[len(rates)-1], cutoffs[len(cutoffs)-1])
+    }
+
+    // Each rate is the one a run with that maxtime gets.
+    for i, cutoff := range cutoffs {
+        run := war.RunSimulations(300, 500, 15000, false, cutoff, 4, 4, war.DefaultOptions())
+        finished := 0
+        for _, game := range run {
+            if finishedNaturally(game) {
+                finished++
+            }
+        }
+        if got := float64(finished) / float64(len(run)); got != rates[i] {
+            t.Errorf("at %d ms the sweep reports %.3f finished, a run finishes %.3f", cutoff, rates[i], got)
+        }
+    }
+}
+
+func TestParseMaxTimeSweep(t *testing.T) {
+    cutoffs, err := parseMaxTimeSweep("1000, 3000, 1000")
+    if err != nil || len(cutoffs) != 3 || cutoffs[2] != 3000 {
+        t.Errorf("parseMaxTimeSweep = %v, %v; want [1000 2000 3000]", cutoffs, err)
+    }
+    for _, spec := range []string{"1000,3000", "0,3000,1000", "3000,1000,1000", "1000,3000,0", "a,b,c"} {
+        if _, err := parseMaxTimeSweep(spec); err == nil {
+            t.Errorf("parseMaxTimeSweep(%q) accepted it", spec)
+        }
+    }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/url"
+    "strings"
+)
+
+// Results can be uploaded straight to object storage with -out s3://bucket/key
+// or -out gs://bucket/key, through the AWS and Google Cloud SDKs. Output is
+// streamed: S3 gets it a part at a time as a multipart upload, or as a single
+// PUT if it never fills a part, and GCS as a resumable upload.
+//
+// The SDKs are only compiled in with the cloud build tag
+// (go build -tags cloud), so that the plain command does not depend on
+// them; without it -out fails before the simulation starts.
+//
+// Credentials are found the way each SDK's own tools find them:
+//   s3://  the AWS default chain (AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY,
+//          ~/.aws, an instance role), AWS_REGION (default us-east-1), and
+//          optionally S3_ENDPOINT for an S3-compatible server
+//   gs://  Application Default Credentials; STORAGE_EMULATOR_HOST points at
+//          an emulator
+
+// errUploadAborted is what an aborted upload's reader fails with.
+var errUploadAborted = errors.New("upload aborted")
+
+// objectWriter streams data to a single object. Close completes the upload;
+// Abort abandons it instead, leaving no object or unfinished upload behind.
+type objectWriter struct {
+    io.Writer
+    finish  func() error
+    abandon func()
+}
+
+// Close completes the upload.
+func (w *objectWriter) Close() error {
+    return w.finish()
+}
+
+// Abort abandons the upload after a failure to write the results.
+func (w *objectWriter) Abort() {
+    w.abandon()
+}
+
+// openObjectWriter finds credentials for dest, checks that its bucket is
+// reachable and returns a writer for the object. Checking up front means bad
+// credentials or permissions fail before the simulation rather than after it.
+func openObjectWriter(dest string) (*objectWriter, error) {
+    u, err := url.Parse(dest)
+    if err != nil {
+        return nil, fmt.Errorf("invalid output URL %q: %v", dest, err)
+    }
+    bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+    if bucket == "" || key == "" {
+        return nil, fmt.Errorf("output URL %q must include a bucket and an object key", dest)
+    }
+
+    switch u.Scheme {
+    case "s3":
+        return openS3Writer(context.Background(), bucket, key)
+    case "gs":
+        return openGCSWriter(context.Background(), bucket, key)
+    }
+    return nil, fmt.Errorf("unsupported output scheme %q (want s3 or gs)", u.Scheme)
+}
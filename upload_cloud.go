@@ -0,0 +1,90 @@
+//go:build cloud
+
+package main
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+
+    "cloud.google.com/go/storage"
+    "github.com/aws/aws-sdk-go-v2/aws"
+    "github.com/aws/aws-sdk-go-v2/config"
+    "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+    "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// openS3Writer uploads to an S3 object through a pipe: the uploader reads
+// from it in the background, and Close waits for it to finish.
+func openS3Writer(ctx context.Context, bucket, key string) (*objectWriter, error) {
+    cfg, err := config.LoadDefaultConfig(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("loading AWS configuration: %v", err)
+    }
+    if cfg.Region == "" {
+        cfg.Region = "us-east-1"
+    }
+    client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+        if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+            o.BaseEndpoint = aws.String(endpoint)
+            o.UsePathStyle = true
+        }
+    })
+    if _, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
+        return nil, fmt.Errorf("cannot access bucket %q: %v", bucket, err)
+    }
+
+    // A failed read makes the uploader abort any multipart upload it began.
+    reader, writer := io.Pipe()
+    done := make(chan error, 1)
+    go func() {
+        _, err := manager.NewUploader(client).Upload(ctx, &s3.PutObjectInput{
+            Bucket: aws.String(bucket),
+            Key:    aws.String(key),
+            Body:   reader,
+        })
+        reader.CloseWithError(err) // Unblocks a writer the uploader gave up on
+        done <- err
+    }()
+    return &objectWriter{
+        Writer: writer,
+        finish: func() error {
+            writer.Close()
+            return <-done
+        },
+        abandon: func() {
+            writer.CloseWithError(errUploadAborted)
+            <-done
+        },
+    }, nil
+}
+
+// openGCSWriter uploads to a GCS object. Cancelling the writer's context
+// before it is closed abandons the upload.
+func openGCSWriter(ctx context.Context, bucket, key string) (*objectWriter, error) {
+    client, err := storage.NewClient(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("creating GCS client: %v", err)
+    }
+    if _, err := client.Bucket(bucket).Attrs(ctx); err != nil {
+        client.Close()
+        return nil, fmt.Errorf("cannot access bucket %q: %v", bucket, err)
+    }
+
+    ctx, cancel := context.WithCancel(ctx)
+    writer := client.Bucket(bucket).Object(key).NewWriter(ctx)
+    return &objectWriter{
+        Writer: writer,
+        finish: func() error {
+            defer client.Close()
+            defer cancel()
+            return writer.Close()
+        },
+        abandon: func() {
+            cancel()
+            writer.Close()
+            client.Close()
+        },
+    }, nil
+}
@@ -0,0 +1,164 @@
+//go:build cloud
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// fakeS3 is just enough of an S3 server, path-style, for the uploader: it
+// keeps completed objects and records aborted multipart uploads.
+type fakeS3 struct {
+    mu      sync.Mutex
+    bucket  string
+    objects map[string][]byte
+    parts   map[string][][]byte // Unfinished multipart uploads' parts, by upload ID
+    aborted []string
+}
+
+func newFakeS3(t *testing.T, bucket string) *fakeS3 {
+    s := &fakeS3{bucket: bucket, objects: make(map[string][]byte), parts: make(map[string][][]byte)}
+    server := httptest.NewServer(s)
+    t.Cleanup(server.Close)
+
+    t.Setenv("S3_ENDPOINT", server.URL)
+    t.Setenv("AWS_ACCESS_KEY_ID", "test")
+    t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+    t.Setenv("AWS_REGION", "us-east-1")
+    t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/config")
+    t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/credentials")
+    t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+    t.Setenv("AWS_REQUEST_CHECKSUM_CALCULATION", "when_required")
+    return s
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+    if bucket != s.bucket {
+        http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+        return
+    }
+    body, _ := io.ReadAll(r.Body)
+    query := r.URL.Query()
+    uploadID := query.Get("uploadId")
+    switch {
+    case r.Method == "HEAD" && key == "":
+    case r.Method == "PUT" && uploadID != "":
+        s.parts[uploadID] = append(s.parts[uploadID], body)
+        w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(s.parts[uploadID])))
+    case r.Method == "PUT":
+        s.objects[key] = body
+    case r.Method == "POST" && query.Has("uploads"):
+        uploadID = fmt.Sprintf("upload%d", len(s.parts)+1)
+        s.parts[uploadID] = nil
+        fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", bucket, key, uploadID)
+    case r.Method == "POST" && uploadID != "":
+        s.objects[key] = bytes.Join(s.parts[uploadID], nil)
+        delete(s.parts, uploadID)
+        fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key></CompleteMultipartUploadResult>", bucket, key)
+    case r.Method == "DELETE" && uploadID != "":
+        delete(s.parts, uploadID)
+        s.aborted = append(s.aborted, uploadID)
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        http.Error(w, "unexpected request", http.StatusBadRequest)
+    }
+}
+
+func TestUploadToS3(t *testing.T) {
+    s := newFakeS3(t, "results")
+    upload, err := openObjectWriter("s3://results/runs/war.csv")
+    if err != nil {
+        t.Fatal(err)
+    }
+    stats := runGames(t, 10)
+    columns, _ := columnsForPreset("standard")
+    format := Format{Name: "csv", Columns: columns, Metadata: RunMetadata{Seed: 1, Games: 10}}
+    if err := WriteResults(upload, stats, format); err != nil {
+        t.Fatal(err)
+    }
+    if err := upload.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    var want bytes.Buffer
+    WriteResults(&want, stats, format)
+    if got := s.objects["runs/war.csv"]; !bytes.Equal(got, want.Bytes()) {
+        t.Errorf("uploaded object is\n%s\nwant\n%s", got, want.Bytes())
+    }
+}
+
+// An upload large enough to go in parts streams them, and completes them
+// into the one object.
+func TestUploadToS3InParts(t *testing.T) {
+    s := newFakeS3(t, "results")
+    upload, err := openObjectWriter("s3://results/big.csv")
+    if err != nil {
+        t.Fatal(err)
+    }
+    data := bytes.Repeat([]byte("0123456789abcdef"), 12<<16) // 12 MiB
+    if _, err := upload.Write(data); err != nil {
+        t.Fatal(err)
+    }
+    if err := upload.Close(); err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(s.objects["big.csv"], data) {
+        t.Errorf("uploaded %d bytes, want %d", len(s.objects["big.csv"]), len(data))
+    }
+}
+
+func TestAbortedUploadLeavesNothing(t *testing.T) {
+    s := newFakeS3(t, "results")
+    upload, err := openObjectWriter("s3://results/big.csv")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := upload.Write(bytes.Repeat([]byte("x"), 12<<20)); err != nil {
+        t.Fatal(err)
+    }
+    upload.Abort()
+    if len(s.objects) != 0 {
+        t.Errorf("aborted upload left objects %v", s.objects)
+    }
+    if len(s.aborted) != 1 || len(s.parts) != 0 {
+        t.Errorf("aborted uploads %v, unfinished %d, want the one begun aborted", s.aborted, len(s.parts))
+    }
+}
+
+func TestUploadChecksBucketFirst(t *testing.T) {
+    newFakeS3(t, "results")
+    if _, err := openObjectWriter("s3://elsewhere/war.csv"); err == nil || !strings.Contains(err.Error(), "cannot access bucket") {
+        t.Errorf("opening an inaccessible bucket gave %v", err)
+    }
+}
+
+func TestGCSUploadChecksBucketFirst(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/storage/v1/b/results" {
+            fmt.Fprint(w, `{"name": "results"}`)
+            return
+        }
+        http.Error(w, `{"error": {"code": 403, "message": "denied"}}`, http.StatusForbidden)
+    }))
+    defer server.Close()
+    t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+    upload, err := openObjectWriter("gs://results/war.csv")
+    if err != nil {
+        t.Fatalf("opening an accessible bucket: %v", err)
+    }
+    upload.Abort()
+    if _, err := openObjectWriter("gs://elsewhere/war.csv"); err == nil || !strings.Contains(err.Error(), "cannot access bucket") {
+        t.Errorf("opening an inaccessible bucket gave %v", err)
+    }
+}
@@ -0,0 +1,21 @@
+//go:build !cloud
+
+package main
+
+import (
+    "context"
+    "errors"
+)
+
+// errNoCloud is what -out fails with in a build without the cloud tag.
+var errNoCloud = errors.New("uploading results needs a build with the SDKs: go build -tags cloud")
+
+// openS3Writer refuses the upload: this build has no S3 SDK.
+func openS3Writer(ctx context.Context, bucket, key string) (*objectWriter, error) {
+    return nil, errNoCloud
+}
+
+// openGCSWriter refuses the upload: this build has no GCS SDK.
+func openGCSWriter(ctx context.Context, bucket, key string) (*objectWriter, error) {
+    return nil, errNoCloud
+}
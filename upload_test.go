@@ -0,0 +1,11 @@
+package main
+
+import "testing"
+
+func TestUploadURLs(t *testing.T) {
+    for _, dest := range []string{"s3://bucket", "s3:///key", "ftp://bucket/key"} {
+        if _, err := openObjectWriter(dest); err == nil {
+            t.Errorf("openObjectWriter(%q) accepted it", dest)
+        }
+    }
+}
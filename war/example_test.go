@@ -0,0 +1,36 @@
+package war_test
+
+import (
+    "fmt"
+
+    "wargames/war"
+)
+
+// A game is played from outside the package with explicit timings, in
+// milliseconds, and its own random source.
+func ExamplePlayGame() {
+    opts := war.DefaultOptions()
+    stats := war.PlayGame(nil, 500, 15000, false, 3600000, opts, war.NewGameRand(1, 1, 0))
+    again := war.PlayGame(nil, 500, 15000, false, 3600000, opts, war.NewGameRand(1, 1, 0))
+    fmt.Println(stats.Finished, stats.Winner == again.Winner, stats.Tricks == again.Tricks)
+    // Output: true true true
+}
+
+// Each game of a run can also be stepped through a trick at a time.
+func ExampleGame_PlayTrick() {
+    deal := []war.Card{{Rank: 14, Suit: war.Spades}, {Rank: 2, Suit: war.Clubs}}
+    opts := war.DefaultOptions()
+    opts.Deal = [][]war.Card{deal[:1], deal[1:]}
+    g := war.NewGame(nil, 500, 15000, false, 3600000, opts, war.NewGameRand(1, 1, 0))
+    for {
+        trick, ok := g.PlayTrick()
+        if !ok {
+            break
+        }
+        fmt.Printf("trick %d: %s against %s, won by player %d\n", trick.Trick, trick.CardA, trick.CardB, trick.Winner)
+    }
+    fmt.Println("winner:", g.Stats().Winner)
+    // Output:
+    // trick 1: A♠ against 2♣, won by player 1
+    // winner: 1
+}
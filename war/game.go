@@ -0,0 +1,314 @@
+package war
+
+import (
+    "math/rand"
+    "time"
+)
+
+// Game is a two-player game in progress, played one trick at a time with
+// PlayTrick. PlayGame and PlayDeal play a whole game the same way, so a game
+// stepped through trick by trick plays exactly as it would in a run.
+type Game struct {
+    playerA, playerB Player
+    stats            GameStats
+    totalTime        int // in milliseconds
+    handTime         int
+    shuffleTime      int
+    maxGameTime      int
+    opts             Options
+    rng              *rand.Rand
+    cycles           *cycleDetector // Nil unless the game's play is deterministic
+    over             bool
+    leader           int     // Player in the lead, 1 or 2, or 0 before anyone has led (TrackLead only)
+    behind           [2]bool // Whether each player has ever trailed (TrackLead only)
+}
+
+// TrickResult describes one trick played by PlayTrick.
+type TrickResult struct {
+    Trick    int
+    CardA    Card   // The card Player A turned up to start the trick
+    CardB    Card   // The card Player B turned up to start the trick
+    CardsA   []Card // For a war, every card Player A committed, starting with CardA
+    CardsB   []Card // For a war, every card Player B committed, starting with CardB
+    Winner   int    // 1 for Player A, 2 for Player B, 0 if nobody took the cards
+    WarDepth int    // Rounds of war played, 0 for a plain trick
+    TimedOut bool   // The time limit cut the war off, ending the game
+}
+
+// NewGame deals a game as PlayGame does, ready to be played trick by trick.
+// Only two-player games can be stepped through; Options.Players is ignored.
+func NewGame(deck []Card, handTime, shuffleTime int, includeJokers bool, maxGameTime int, opts Options, rng *rand.Rand) *Game {
+    deck, split := dealDeck(deck, includeJokers, opts, rng)
+    g := newGame(deck, split, handTime, shuffleTime, maxGameTime, opts, rng)
+    return &g
+}
+
+// newGame deals the first split cards of deck to Player A and the rest to
+// Player B.
+func newGame(deck []Card, split, handTime, shuffleTime, maxGameTime int, opts Options, rng *rand.Rand) Game {
+    g := Game{
+        playerA:     newPlayer(deck[:split], len(deck)),
+        playerB:     newPlayer(deck[split:], len(deck)),
+        stats:       GameStats{PipsA: pipSum(deck[:split]), PipsB: pipSum(deck[split:]), ExtraCardTo: extraCardTo(split, len(deck)-split)},
+        handTime:    handTime,
+        shuffleTime: shuffleTime,
+        maxGameTime: maxGameTime,
+        opts:        opts,
+        rng:         rng,
+    }
+    if opts.RecordDeal {
+        // The deck may be a reused buffer, so keep copies.
+        g.stats.InitialDealA = append([]Card(nil), deck[:split]...)
+        g.stats.InitialDealB = append([]Card(nil), deck[split:]...)
+    }
+
+    if opts.Variant == "central-discard" {
+        discard := NewPile(len(deck))
+        g.playerA.Discard = &discard
+        g.playerB.Discard = &discard
+    }
+
+    // Without any shuffling the next trick depends only on the cards, so a
+    // repeated arrangement means the game will loop forever.
+    if deterministicPlay(opts) {
+        g.cycles = &cycleDetector{power: 1}
+    }
+    return g
+}
+
+// Over reports whether the game has ended.
+func (g *Game) Over() bool {
+    return g.over
+}
+
+// CardsLeft returns the number of cards each player can still draw.
+func (g *Game) CardsLeft() (a, b int) {
+    return cardsLeft(&g.playerA), cardsLeft(&g.playerB)
+}
+
+// PlayTrick plays the next trick, including any war it starts, and reports
+// what happened. It returns false, and the game is over, when the game ends
+// before another trick can be played. A war cut short by the time limit is
+// still reported, with Winner 0, and ends the game.
+func (g *Game) PlayTrick() (TrickResult, bool) {
+    playerA, playerB, stats, opts, rng := &g.playerA, &g.playerB, &g.stats, g.opts, g.rng
+
+    if g.over || cardsLeft(playerA) == 0 || cardsLeft(playerB) == 0 ||
+        (opts.MaxTricks > 0 && stats.Tricks >= opts.MaxTricks) || timeUp(stats, g.totalTime, g.maxGameTime) {
+        g.over = true
+        return TrickResult{}, false
+    }
+
+    if g.cycles != nil && g.cycles.repeats(stateHash(playerA, playerB)) {
+        stats.TerminationReason = "cycle"
+        g.over = true
+        return TrickResult{}, false
+    }
+
+    stats.Tricks++
+    g.totalTime += g.handTime
+
+    // Check if we've exceeded the time limit
+    if timeUp(stats, g.totalTime, g.maxGameTime) {
+        endOnTimeout(stats, playerA, playerB)
+        g.over = true
+        return TrickResult{}, false
+    }
+
+    if playerA.Discard != nil {
+        splitDiscard(playerA, playerB, opts, rng)
+    }
+
+    cardA, shuffledA := drawCard(playerA, opts, rng)
+    cardB, shuffledB := drawCard(playerB, opts, rng)
+
+    // With a shared discard, Player A can reclaim the last card Player B
+    // was counting on. B is then out and the trick is never played.
+    if (cardB == Card{}) {
+        playerA.DrawPile.PushFront(cardA)
+        stats.Tricks--
+        g.totalTime -= g.handTime
+        g.over = true
+        return TrickResult{}, false
+    }
+
+    // Right after a reshuffle, hands that match card for card can only tie
+    // until both players run out, so when that is a draw, call it one now.
+    // The trick is never played, so neither it nor the reshuffles that
+    // started it are counted or timed.
+    if (shuffledA > 0 || shuffledB > 0) && identicalHands(playerA, playerB, cardA, cardB, stats, opts) {
+        playerA.DrawPile.PushFront(cardA)
+        playerB.DrawPile.PushFront(cardB)
+        stats.Tricks--
+        g.totalTime -= g.handTime
+        stats.Finished = true
+        stats.EarlyDraw = true
+        stats.TerminationReason = "early-draw"
+        g.over = true
+        return TrickResult{}, false
+    }
+    chargeShuffles(stats, &g.totalTime, shuffledA, shuffledB, g.shuffleTime, opts)
+
+    trick := playTrick(playerA, playerB, cardA, cardB, stats, &g.totalTime, g.handTime, g.shuffleTime, g.maxGameTime, opts, rng)
+    if opts.Transcript != nil {
+        leftA, leftB := g.CardsLeft()
+        if trick.WarDepth > 0 {
+            opts.Transcript.record(trick.Trick, trick.CardsA, trick.CardsB, trick.Winner, trick.WarDepth, leftA, leftB)
+        } else {
+            opts.Transcript.record(trick.Trick, []Card{cardA}, []Card{cardB}, trick.Winner, 0, leftA, leftB)
+        }
+    }
+    if opts.TrackLead {
+        g.trackLead()
+    }
+    if trick.TimedOut {
+        // Nobody wins a war cut off by the time limit; its cards stay on
+        // the table and the game is decided on those held.
+        endOnTimeout(stats, playerA, playerB)
+        g.over = true
+    }
+    return trick, true
+}
+
+// trackLead notes who leads after a trick: the player holding more cards, or
+// in count-tricks, where nobody holds on to cards, the one with more tricks.
+// A level score leaves the lead where it was.
+func (g *Game) trackLead() {
+    lead := ownCards(&g.playerA) - ownCards(&g.playerB)
+    if g.opts.Variant == "count-tricks" {
+        lead = g.stats.PlayerATricks - g.stats.PlayerBTricks
+    }
+    leader := 0
+    if lead > 0 {
+        leader = 1
+    } else if lead < 0 {
+        leader = 2
+    }
+    if leader == 0 {
+        return
+    }
+    g.behind[2-leader] = true
+    if g.leader != 0 && leader != g.leader {
+        g.stats.LeadChanges++
+    }
+    g.leader = leader
+}
+
+// playTrick settles the trick in which Player A turned up cardA and Player B
+// cardB: it compares them, plays out any war they start, and gives the cards
+// to the winner. It does not end the game; a war cut off by the time limit
+// comes back TimedOut with its cards taken by nobody.
+func playTrick(playerA, playerB *Player, cardA, cardB Card, stats *GameStats, totalTime *int, handTime, shuffleTime, maxGameTime int, opts Options, rng *rand.Rand) TrickResult {
+    trick := TrickResult{Trick: stats.Tricks, CardA: cardA, CardB: cardB}
+    rankA, rankB := playRanks(cardA, cardB, opts)
+    tie := rankA == rankB
+    if tie && opts.TieBreak == "suit" {
+        stats.SuitTieBreaks++
+    }
+
+    if tie && opts.TieBreak != "suit" {
+        wars := stats.Wars
+        result := handleWar(playerA, playerB, []Card{cardA}, []Card{cardB}, stats, totalTime, handTime, shuffleTime, maxGameTime, opts, rng)
+        trick.CardsA, trick.CardsB, trick.WarDepth = result.CardsA, result.CardsB, stats.Wars-wars
+        if result.TimedOut {
+            trick.TimedOut = true
+            return trick
+        }
+        if hasJoker(result.CardsA) || hasJoker(result.CardsB) {
+            stats.JokerTricks++
+        }
+        if result.Winner == 0 && result.DepthCapped {
+            // A capped war between equal holdings: both take their cards back.
+            collectCards(playerA, result.CardsA, opts)
+            collectCards(playerB, result.CardsB, opts)
+            return trick
+        }
+        warPile := collectWarPile(orderPiles([][]Card{result.CardsA, result.CardsB}, result.Winner-1, opts, rng), opts.WarCollectOrder, rng)
+        stats.PlayerATricks += result.PlayerATricks
+        stats.PlayerBTricks += result.PlayerBTricks
+        if result.DecidedBy.Rank != 0 {
+            stats.RankWins[result.DecidedBy.Rank]++
+        }
+        if result.Winner == 1 {
+            collectCards(playerA, warPile, opts)
+        } else if result.Winner == 2 {
+            collectCards(playerB, warPile, opts)
+        }
+        trick.Winner = result.Winner
+        return trick
+    }
+
+    trick.Winner = 2
+    if outranks(cardA, cardB, opts) {
+        trick.Winner = 1
+    }
+    if cardA.Rank == 15 || cardB.Rank == 15 {
+        stats.JokerTricks++
+    }
+    if trick.Winner == 1 {
+        collectCards(playerA, trickCards(cardA, cardB, 1, opts, rng), opts)
+        stats.PlayerATricks++
+        stats.RankWins[cardA.Rank]++
+    } else {
+        collectCards(playerB, trickCards(cardA, cardB, 2, opts, rng), opts)
+        stats.PlayerBTricks++
+        stats.RankWins[cardB.Rank]++
+    }
+    return trick
+}
+
+// Stats returns the game's statistics. Once the game is over they include
+// how it ended and who won.
+func (g *Game) Stats() GameStats {
+    stats := g.stats
+    if !g.over {
+        stats.GameDuration = time.Duration(g.totalTime) * time.Millisecond
+        return stats
+    }
+
+    // Nobody collects cards in count-tricks, so both players run out together
+    // and the game goes to whoever won more tricks.
+    if g.opts.Variant == "count-tricks" && !stats.Finished {
+        stats.Finished = true
+        stats.TerminationReason = "exhaustion"
+        if stats.PlayerATricks > stats.PlayerBTricks {
+            stats.Winner = 1
+        } else if stats.PlayerBTricks > stats.PlayerATricks {
+            stats.Winner = 2
+        }
+    }
+
+    if !stats.Finished && stats.TerminationReason == "" {
+        emptyA := cardsLeft(&g.playerA) == 0
+        emptyB := cardsLeft(&g.playerB) == 0
+        stats.Finished = emptyA || emptyB
+        if emptyA && emptyB {
+            resolveSimultaneousEnd(&stats, g.opts.SimulEnd)
+        } else if stats.Finished {
+            stats.TerminationReason = "exhaustion"
+            if emptyA {
+                stats.Winner = 2 // Player B wins
+            } else {
+                stats.Winner = 1 // Player A wins
+            }
+        } else if g.totalTime >= g.maxGameTime {
+            endOnTimeout(&stats, &g.playerA, &g.playerB)
+        } else {
+            stats.TerminationReason = "trick-limit"
+        }
+    }
+
+    if g.opts.TrackLead && stats.Winner != 0 {
+        stats.WinnerWasBehind = g.behind[stats.Winner-1]
+    }
+    stats.PlayerTricks = []int{stats.PlayerATricks, stats.PlayerBTricks}
+    stats.FinalCardsA, stats.FinalCardsB = ownCards(&g.playerA), ownCards(&g.playerB)
+    switch stats.Winner {
+    case 1:
+        stats.WinnerMargin = stats.FinalCardsA - stats.FinalCardsB
+    case 2:
+        stats.WinnerMargin = stats.FinalCardsB - stats.FinalCardsA
+    }
+    stats.GameDuration = time.Duration(g.totalTime) * time.Millisecond
+    return stats
+}
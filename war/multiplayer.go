@@ -0,0 +1,223 @@
+package war
+
+import (
+    "math/rand"
+    "time"
+)
+
+// playMultiDeal plays a game of three or more players from an already
+// shuffled deck, dealt round-robin starting with the first player. Every
+// player with cards plays one each trick and the highest rank takes them all.
+// When several players tie for the highest rank, only they go to war: each
+// lays WarDown cards face down and one face up, and the highest face-up card
+// among them takes the whole pot, with further ties warring again among
+// themselves. Every trick's cards are collected like a war pile, so the
+// collect order decides whose cards come first. A tied player with no cards
+// left to lay drops out of the war, as does one short of a full round under
+// the forfeit short war rule unless nobody completed it; if none of them has
+// a card left, the first of them in seat order takes the pot.
+//
+// A player with no cards is out, and the last player left wins: Winner is
+// that player's seat, counting from 1, so it is above 2 when a player after
+// Player A and Player B wins.
+//
+// Only the standard variant and war tie-breaks are played (Validate rejects
+// the rest), and neither cycle detection nor transcripts are supported.
+// Player A and Player B in the stats are the first two seats.
+func playMultiDeal(deck []Card, handTime, shuffleTime, maxGameTime int, opts Options, rng *rand.Rand) GameStats {
+    players := make([]Player, opts.Players)
+    for i := range players {
+        players[i] = newPlayer(nil, len(deck))
+    }
+    for i, card := range deck {
+        players[i%len(players)].DrawPile.Add(card)
+    }
+
+    handA, handB := players[0].DrawPile.Cards(), players[1].DrawPile.Cards()
+    stats := GameStats{
+        PipsA:        pipSum(handA),
+        PipsB:        pipSum(handB),
+        ExtraCardTo:  extraCardTo(len(handA), len(handB)),
+        PlayerTricks: make([]int, len(players)),
+    }
+    if opts.RecordDeal {
+        stats.InitialDealA, stats.InitialDealB = handA, handB
+    }
+    shuffles := make([]int, len(players))
+    warShuffles := make([]int, len(players))
+    totalTime := 0 // in milliseconds
+
+    pot := make([][]Card, len(players))
+    active := inPlay(players, nil)
+    for len(active) > 1 && (opts.MaxTricks == 0 || stats.Tricks < opts.MaxTricks) && !timeUp(&stats, totalTime, maxGameTime) {
+        if cancelled(opts, stats.Tricks) {
+            return GameStats{TerminationReason: "cancelled"}
+        }
+        stats.Tricks++
+        totalTime += handTime
+        if timeUp(&stats, totalTime, maxGameTime) {
+            break
+        }
+
+        shuffled := false
+        for i := range pot {
+            pot[i] = nil
+        }
+        for _, i := range active {
+            card, s := drawCard(&players[i], opts, rng)
+            shuffles[i] += s
+            shuffled = shuffled || s > 0
+            pot[i] = append(pot[i], card)
+        }
+        if shuffled {
+            totalTime += shuffleTime
+        }
+
+        // The card that decides the trick is the winner's last face-up
+        // card, unless the winner is the only one left with cards to lay.
+        contenders := highestFaceUp(pot, active, opts)
+        decided := len(contenders) == 1
+        for depth := 1; len(contenders) > 1 && !timeUp(&stats, totalTime, maxGameTime); depth++ {
+            stats.Wars++
+            stats.TotalWarDepth += depth
+            stats.MaxWarDepth = max(stats.MaxWarDepth, depth)
+            if depth > 1 {
+                stats.DeepWars++
+            }
+
+            laid, shuffled := 0, false
+            var stillIn, complete []int
+            for _, i := range contenders {
+                cards, s := drawWarCards(&players[i], opts, rng)
+                shuffles[i] += s
+                warShuffles[i] += s
+                shuffled = shuffled || s > 0
+                pot[i] = append(pot[i], cards...)
+                laid = max(laid, len(cards))
+                if len(cards) > 0 {
+                    stillIn = append(stillIn, i)
+                }
+                if len(cards) > opts.WarDown {
+                    complete = append(complete, i)
+                }
+            }
+            if opts.ShortWar == "forfeit" && len(complete) > 0 {
+                stillIn = complete
+            }
+            totalTime += handTime * laid
+            if shuffled {
+                totalTime += shuffleTime
+            }
+            if len(stillIn) == 0 {
+                contenders = contenders[:1]
+                break
+            }
+            contenders = highestFaceUp(pot, stillIn, opts)
+            decided = len(stillIn) > 1 && len(contenders) == 1
+        }
+
+        // A war cut short by the time limit goes to nobody; the game is over.
+        if len(contenders) > 1 {
+            break
+        }
+        winner := contenders[0]
+        stats.PlayerTricks[winner]++
+        if decided {
+            stats.RankWins[pot[winner][len(pot[winner])-1].Rank]++
+        }
+        for _, cards := range pot {
+            if hasJoker(cards) {
+                stats.JokerTricks++
+                break
+            }
+        }
+        players[winner].WinningsPile.Add(collectWarPile(orderPiles(pot, winner, opts, rng), opts.WarCollectOrder, rng)...)
+        active = inPlay(players, active[:0])
+    }
+
+    stats.PlayerATricks, stats.PlayerBTricks = stats.PlayerTricks[0], stats.PlayerTricks[1]
+    stats.ShufflesA, stats.ShufflesB = shuffles[0], shuffles[1]
+    stats.WarShufflesA, stats.WarShufflesB = warShuffles[0], warShuffles[1]
+
+    switch {
+    case len(active) == 1:
+        stats.Finished = true
+        stats.TerminationReason = "exhaustion"
+        stats.Winner = active[0] + 1
+    case totalTime >= maxGameTime:
+        // As in a two-player game, the player holding the most cards wins.
+        stats.Finished = true
+        stats.TerminationReason = "timeout"
+        stats.Winner = mostCards(players)
+    default:
+        stats.TerminationReason = "trick-limit"
+    }
+
+    stats.FinalCardsA, stats.FinalCardsB = ownCards(&players[0]), ownCards(&players[1])
+    if stats.Winner > 0 {
+        runnerUp := 0
+        for i := range players {
+            if i != stats.Winner-1 {
+                runnerUp = max(runnerUp, ownCards(&players[i]))
+            }
+        }
+        stats.WinnerMargin = ownCards(&players[stats.Winner-1]) - runnerUp
+    }
+
+    stats.GameDuration = time.Duration(totalTime) * time.Millisecond
+    return stats
+}
+
+// inPlay appends the seats of the players that still hold cards to seats.
+func inPlay(players []Player, seats []int) []int {
+    for i := range players {
+        if cardsLeft(&players[i]) > 0 {
+            seats = append(seats, i)
+        }
+    }
+    return seats
+}
+
+// highestFaceUp returns the seats among seats whose last card in the pot has
+// the highest rank. Under the joker rule a wild joker plays at the highest
+// rank among the other cards, so it ties with them.
+func highestFaceUp(pot [][]Card, seats []int, opts Options) []int {
+    highest := 0
+    for _, i := range seats {
+        if rank := pot[i][len(pot[i])-1].Rank; rank != 15 {
+            highest = max(highest, rank)
+        }
+    }
+    if highest == 0 {
+        highest = 15 // Only jokers: they tie
+    }
+
+    best := 0
+    var top []int
+    for _, i := range seats {
+        rank := jokerRank(pot[i][len(pot[i])-1], highest, opts)
+        if rank > best {
+            best = rank
+            top = top[:0]
+        }
+        if rank == best {
+            top = append(top, i)
+        }
+    }
+    return top
+}
+
+// mostCards returns the seat, numbered from 1, of the player holding the
+// most cards, or 0 if several share the most.
+func mostCards(players []Player) int {
+    winner, most := 0, -1
+    for i := range players {
+        switch n := cardsLeft(&players[i]); {
+        case n > most:
+            winner, most = i+1, n
+        case n == most:
+            winner = 0
+        }
+    }
+    return winner
+}
@@ -0,0 +1,98 @@
+package war
+
+import "math/rand"
+
+// Pile is a pile of cards played from the top and added to at the bottom. It
+// is a ring buffer, so drawing and adding move cards in and out of a fixed
+// backing array instead of reslicing and reallocating a slice. A pile made by
+// NewPile with room for the whole deck never reallocates; the zero value is an
+// empty pile that grows as cards are added.
+type Pile struct {
+    cards []Card // Backing array; its length is the pile's capacity
+    head  int    // Index of the top card
+    size  int
+}
+
+// NewPile returns an empty pile with room for capacity cards.
+func NewPile(capacity int) Pile {
+    return Pile{cards: make([]Card, capacity)}
+}
+
+// Len is the number of cards in the pile.
+func (p *Pile) Len() int {
+    return p.size
+}
+
+// At returns the card i places from the top of the pile.
+func (p *Pile) At(i int) Card {
+    return p.cards[(p.head+i)%len(p.cards)]
+}
+
+// Cards returns a copy of the pile's cards from top to bottom.
+func (p *Pile) Cards() []Card {
+    cards := make([]Card, p.size)
+    for i := range cards {
+        cards[i] = p.At(i)
+    }
+    return cards
+}
+
+// Draw removes and returns the top card. The pile must not be empty.
+func (p *Pile) Draw() Card {
+    card := p.cards[p.head]
+    p.head = (p.head + 1) % len(p.cards)
+    p.size--
+    return card
+}
+
+// Add puts cards on the bottom of the pile in order.
+func (p *Pile) Add(cards ...Card) {
+    if p.size+len(cards) > len(p.cards) {
+        p.grow(p.size + len(cards))
+    }
+    for _, card := range cards {
+        p.cards[(p.head+p.size)%len(p.cards)] = card
+        p.size++
+    }
+}
+
+// PushFront puts card back on top of the pile.
+func (p *Pile) PushFront(card Card) {
+    if p.size == len(p.cards) {
+        p.grow(p.size + 1)
+    }
+    p.head = (p.head - 1 + len(p.cards)) % len(p.cards)
+    p.cards[p.head] = card
+    p.size++
+}
+
+// Shuffle shuffles the pile in place. It makes the same calls on rng and
+// gives the same order as ShuffleDeck on the pile's cards as a slice.
+func (p *Pile) Shuffle(rng *rand.Rand) {
+    rng.Shuffle(p.size, func(i, j int) {
+        i, j = (p.head+i)%len(p.cards), (p.head+j)%len(p.cards)
+        p.cards[i], p.cards[j] = p.cards[j], p.cards[i]
+    })
+}
+
+// shuffleAs shuffles the pile with the shuffle model, such as a player would
+// before playing it. The uniform model is Shuffle, with its order.
+func (p *Pile) shuffleAs(model string, rng *rand.Rand) {
+    if model == "uniform" {
+        p.Shuffle(rng)
+        return
+    }
+    cards := p.Cards()
+    shuffleCards(cards, model, rng)
+    copy(p.cards, cards)
+    p.head = 0
+}
+
+// grow moves the pile to a backing array with room for at least n cards.
+func (p *Pile) grow(n int) {
+    cards := make([]Card, max(n, 2*len(p.cards)))
+    for i := 0; i < p.size; i++ {
+        cards[i] = p.At(i)
+    }
+    p.cards, p.head = cards, 0
+}
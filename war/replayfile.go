@@ -0,0 +1,117 @@
+package war
+
+import (
+    "bufio"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// A replay (.war) file holds what it takes to play a game again: the seed of
+// the game's random source, the players' starting hands and the settings the
+// game was played with. It is "WAR1", the seed as 8 bytes big-endian, then
+// each hand, Player A's first, as its length in a uvarint followed by a byte
+// per card from the top, the rank shifted left two bits with the suit in the
+// low two, then the settings as name=value lines, their length in a uvarint
+// followed by the text. The settings are the caller's; this package only
+// keeps them.
+//
+// The random source plays the game from the start, deal included, so a
+// replay played with the settings the game was played with deals the saved
+// hands and then reshuffles exactly as the game did. The settings let the
+// caller refuse to play it with others, and the hands let it be read without
+// playing it.
+
+const replayMagic = "WAR1"
+
+// maxReplayHand bounds the hand lengths LoadReplay accepts, far beyond any
+// deck this package can build, and maxReplaySettings the length of the
+// settings.
+const (
+    maxReplayHand     = 1 << 16
+    maxReplaySettings = 1 << 20
+)
+
+// SaveReplay writes a replay of the game dealt initial, Player A's hand and
+// Player B's, top card first, from the random source seeded with seed.
+func SaveReplay(w io.Writer, initial [2][]Card, seed int64) error {
+    return SaveReplayWithSettings(w, initial, seed, nil)
+}
+
+// SaveReplayWithSettings is SaveReplay for a game played with settings,
+// name=value lines without newlines.
+func SaveReplayWithSettings(w io.Writer, initial [2][]Card, seed int64, settings []string) error {
+    buf := binary.BigEndian.AppendUint64([]byte(replayMagic), uint64(seed))
+    for _, hand := range initial {
+        buf = binary.AppendUvarint(buf, uint64(len(hand)))
+        for _, card := range hand {
+            if card.Rank < 2 || card.Rank > 15 || card.Suit < Clubs || card.Suit > Spades {
+                return fmt.Errorf("cannot save card %v in a replay", card)
+            }
+            buf = append(buf, byte(card.Rank<<2|int(card.Suit)))
+        }
+    }
+    for _, setting := range settings {
+        if strings.Contains(setting, "\n") {
+            return fmt.Errorf("cannot save setting %q in a replay", setting)
+        }
+    }
+    text := strings.Join(settings, "\n")
+    buf = binary.AppendUvarint(buf, uint64(len(text)))
+    buf = append(buf, text...)
+    _, err := w.Write(buf)
+    return err
+}
+
+// LoadReplay reads a replay written by SaveReplay, returning the starting
+// hands, the seed of the game's random source and the settings it was played
+// with, which are nil for a replay saved without any.
+func LoadReplay(r io.Reader) (initial [2][]Card, seed int64, settings []string, err error) {
+    br := bufio.NewReader(r)
+    header := make([]byte, len(replayMagic)+8)
+    if _, err := io.ReadFull(br, header); err != nil {
+        return initial, 0, nil, fmt.Errorf("not a replay file")
+    }
+    if string(header[:len(replayMagic)]) != replayMagic {
+        return initial, 0, nil, fmt.Errorf("not a replay file")
+    }
+    seed = int64(binary.BigEndian.Uint64(header[len(replayMagic):]))
+
+    for i := range initial {
+        n, err := binary.ReadUvarint(br)
+        if err != nil {
+            return initial, 0, nil, fmt.Errorf("reading hand %d: %v", i+1, err)
+        }
+        if n == 0 || n > maxReplayHand {
+            return initial, 0, nil, fmt.Errorf("hand %d has %d cards", i+1, n)
+        }
+        hand := make([]byte, n)
+        if _, err := io.ReadFull(br, hand); err != nil {
+            return initial, 0, nil, fmt.Errorf("reading hand %d: %v", i+1, err)
+        }
+        initial[i] = make([]Card, n)
+        for j, b := range hand {
+            card := Card{Rank: int(b >> 2), Suit: Suit(b & 3)}
+            if card.Rank < 2 || card.Rank > 15 {
+                return initial, 0, nil, fmt.Errorf("hand %d: invalid card byte %#x", i+1, b)
+            }
+            initial[i][j] = card
+        }
+    }
+    n, err := binary.ReadUvarint(br)
+    if err != nil || n > maxReplaySettings {
+        return initial, 0, nil, fmt.Errorf("reading settings: bad length")
+    }
+    text := make([]byte, n)
+    if _, err := io.ReadFull(br, text); err != nil {
+        return initial, 0, nil, fmt.Errorf("reading settings: %v", err)
+    }
+    if n > 0 {
+        settings = strings.Split(string(text), "\n")
+    }
+    if _, err := br.ReadByte(); err != io.EOF {
+        return initial, 0, nil, fmt.Errorf("unexpected data after the replay")
+    }
+    return initial, seed, settings, nil
+}
@@ -0,0 +1,80 @@
+package war
+
+import (
+    "bytes"
+    "encoding/binary"
+    "reflect"
+    "slices"
+    "strings"
+    "testing"
+)
+
+func TestReplayFileRoundTrip(t *testing.T) {
+    deck := CreateDeck(StandardDeck, true)
+    ShuffleDeck(deck, NewGameRand(1, 1, 0))
+    initial := [2][]Card{deck[:27], deck[27:]}
+    for _, settings := range [][]string{{"hand=500", "variant=single-pile", "deal=2♣ 3♦ / A♠"}, nil} {
+        var buf bytes.Buffer
+        if err := SaveReplayWithSettings(&buf, initial, -42, settings); err != nil {
+            t.Fatal(err)
+        }
+        gotInitial, seed, gotSettings, err := LoadReplay(&buf)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !reflect.DeepEqual(gotInitial, initial) || seed != -42 || !reflect.DeepEqual(gotSettings, settings) {
+            t.Errorf("replay read back as %v, seed %d, settings %q; want %v, seed -42, settings %q", gotInitial, seed, gotSettings, initial, settings)
+        }
+    }
+}
+
+// A game saved with SaveReplay and loaded back plays again with identical
+// stats.
+func TestLoadedReplayReplaysTheGame(t *testing.T) {
+    opts := testOptions()
+    opts.RecordDeal = true
+    game := RunGameRange(6, 1, 500, 15000, false, 3600000, 3, 1, opts)[0]
+    var buf bytes.Buffer
+    if err := SaveReplay(&buf, [2][]Card{game.InitialDealA, game.InitialDealB}, game.Seed); err != nil {
+        t.Fatal(err)
+    }
+    initial, seed, settings, err := LoadReplay(&buf)
+    if err != nil || settings != nil {
+        t.Fatalf("replay loaded with settings %q: %v", settings, err)
+    }
+    replayed := PlaySeededGame(seed, 500, 15000, false, 3600000, opts)
+    if !slices.Equal(replayed.InitialDealA, initial[0]) || !slices.Equal(replayed.InitialDealB, initial[1]) {
+        t.Errorf("replay dealt %v and %v, not the saved %v and %v", replayed.InitialDealA, replayed.InitialDealB, initial[0], initial[1])
+    }
+    replayed.GameNumber = game.GameNumber // A replay is game 1 of its own
+    if !reflect.DeepEqual(replayed, game) {
+        t.Errorf("replay played\n%+v\nnot\n%+v", replayed, game)
+    }
+}
+
+func TestInvalidReplayFiles(t *testing.T) {
+    var buf bytes.Buffer
+    if err := SaveReplayWithSettings(&buf, [2][]Card{hand(2, 3), hand(4, 5)}, 1, []string{"hand=500"}); err != nil {
+        t.Fatal(err)
+    }
+    valid := buf.Bytes()
+    for _, tc := range []struct {
+        name string
+        data []byte
+        want string
+    }{
+        {"empty", nil, "not a replay file"},
+        {"other magic", append([]byte("WAR2"), valid[4:]...), "not a replay file"},
+        {"cut in a hand", valid[:14], "reading hand 1"},
+        {"cut in the settings", valid[:len(valid)-2], "reading settings"},
+        {"trailing data", append(bytes.Clone(valid), 0), "unexpected data"},
+        {"invalid card", append(binary.BigEndian.AppendUint64([]byte("WAR1"), 1), 1, 0, 1, 8, 0), "invalid card byte"},
+    } {
+        if _, _, _, err := LoadReplay(bytes.NewReader(tc.data)); err == nil || !strings.Contains(err.Error(), tc.want) {
+            t.Errorf("%s: %v, want an error with %q", tc.name, err, tc.want)
+        }
+    }
+    if err := SaveReplayWithSettings(&buf, [2][]Card{hand(2), hand(3)}, 1, []string{"a=1\nb=2"}); err == nil {
+        t.Error("a setting with a newline was saved")
+    }
+}
@@ -0,0 +1,80 @@
+package war
+
+import "math/rand"
+
+// riffleClumping is the chance that a riffle drops the next card from the
+// same half as the last one, on top of the even odds of a perfect riffle.
+// Real riffles let cards fall in clumps of two or three.
+const riffleClumping = 0.5
+
+// overhandCut is the chance that an overhand shuffle breaks the deck between
+// any two adjacent cards, so packets average five cards.
+const overhandCut = 0.2
+
+// shuffleCards shuffles cards the way model describes: uniform is ShuffleDeck,
+// riffle and overhand are the imperfect shuffles of riffleShuffle and
+// overhandShuffle.
+func shuffleCards(cards []Card, model string, rng *rand.Rand) {
+    switch model {
+    case "riffle":
+        riffleShuffle(cards, riffleClumping, rng)
+    case "overhand":
+        overhandShuffle(cards, overhandCut, rng)
+    default:
+        ShuffleDeck(cards, rng)
+    }
+}
+
+// riffleShuffle models a single riffle. The cards are cut near the middle,
+// with the binomial cut of a real hand, and the halves are interleaved from
+// the top. Each card comes from a half with odds proportional to the cards
+// left in it, as in the Gilbert-Shannon-Reeds model, except that with
+// probability clumping the card comes from the same half as the one before.
+// A clumping of 0 is an ideal riffle; cards adjacent before a riffle tend to
+// stay adjacent either way.
+func riffleShuffle(cards []Card, clumping float64, rng *rand.Rand) {
+    cut := 0
+    for range cards {
+        cut += rng.Intn(2)
+    }
+    left := append([]Card(nil), cards[:cut]...)
+    right := append([]Card(nil), cards[cut:]...)
+
+    fromLeft := false
+    for i := range cards {
+        switch {
+        case len(left) == 0:
+            fromLeft = false
+        case len(right) == 0:
+            fromLeft = true
+        case i > 0 && rng.Float64() < clumping:
+            // Keep dropping from the same half.
+        default:
+            fromLeft = rng.Intn(len(left)+len(right)) < len(left)
+        }
+        if fromLeft {
+            cards[i], left = left[0], left[1:]
+        } else {
+            cards[i], right = right[0], right[1:]
+        }
+    }
+}
+
+// overhandShuffle models one overhand shuffle: packets are slid off the top
+// of the deck one after another and each is dropped on top of the last, so
+// the packets end up in reverse order with the cards inside each in their
+// original order. The deck is broken between two cards with probability cut.
+func overhandShuffle(cards []Card, cut float64, rng *rand.Rand) {
+    shuffled := make([]Card, len(cards))
+    end := len(cards)
+    for start := 0; start < len(cards); {
+        size := 1
+        for start+size < len(cards) && rng.Float64() >= cut {
+            size++
+        }
+        copy(shuffled[end-size:end], cards[start:start+size])
+        end -= size
+        start += size
+    }
+    copy(cards, shuffled)
+}
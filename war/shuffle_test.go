@@ -0,0 +1,71 @@
+package war
+
+import (
+    "math/rand"
+    "slices"
+    "testing"
+)
+
+// adjacentPairs counts the cards in deck still directly followed by the card
+// that followed them in the factory order.
+func adjacentPairs(deck []Card) int {
+    factory := CreateDeck(StandardDeck, false)
+    next := make(map[Card]Card, len(factory))
+    for i := 0; i+1 < len(factory); i++ {
+        next[factory[i]] = factory[i+1]
+    }
+    pairs := 0
+    for i := 0; i+1 < len(deck); i++ {
+        if next[deck[i]] == deck[i+1] {
+            pairs++
+        }
+    }
+    return pairs
+}
+
+// Riffles and overhand shuffles keep cards that were together together far
+// more often than a uniform shuffle, which leaves about one of the 51 pairs
+// of a deck in place, and all three keep every card.
+func TestShuffleModelsKeepAdjacentCards(t *testing.T) {
+    const trials = 2000
+    mean := make(map[string]float64)
+    for _, model := range []string{"uniform", "riffle", "overhand"} {
+        rng := rand.New(rand.NewSource(1))
+        total := 0
+        for range trials {
+            deck := CreateDeck(StandardDeck, false)
+            shuffleCards(deck, model, rng)
+            sorted := slices.Clone(deck)
+            slices.SortFunc(sorted, compareCards)
+            if !slices.Equal(sorted, CreateDeck(StandardDeck, false)) {
+                t.Fatalf("%s shuffle changed the cards: %v", model, deck)
+            }
+            total += adjacentPairs(deck)
+        }
+        mean[model] = float64(total) / trials
+    }
+    if mean["uniform"] < 0.8 || mean["uniform"] > 1.2 {
+        t.Errorf("uniform shuffles keep %.2f adjacent pairs on average, want about 1", mean["uniform"])
+    }
+    for _, model := range []string{"riffle", "overhand"} {
+        if mean[model] < 5*mean["uniform"] {
+            t.Errorf("%s shuffles keep %.2f adjacent pairs on average, uniform %.2f; want many more", model, mean[model], mean["uniform"])
+        }
+    }
+
+    // Less clumping keeps fewer pairs, and an ideal riffle still keeps more
+    // than a uniform shuffle.
+    pairs := func(clumping float64) float64 {
+        rng := rand.New(rand.NewSource(1))
+        total := 0
+        for range trials {
+            deck := CreateDeck(StandardDeck, false)
+            riffleShuffle(deck, clumping, rng)
+            total += adjacentPairs(deck)
+        }
+        return float64(total) / trials
+    }
+    if ideal, clumped := pairs(0), pairs(0.8); ideal >= clumped || ideal < 2*mean["uniform"] {
+        t.Errorf("riffles keep %.2f adjacent pairs with no clumping and %.2f with 0.8, uniform %.2f", ideal, clumped, mean["uniform"])
+    }
+}
@@ -0,0 +1,34 @@
+package war
+
+// TranscriptEntry records one trick of a game. For a war the cards include
+// the tied cards and every card drawn during the war, face-down ones too, in
+// the order each player played them.
+type TranscriptEntry struct {
+    Trick    int
+    CardsA   []Card
+    CardsB   []Card
+    Winner   int  // 1 for Player A, 2 for Player B, 0 if nobody took the cards
+    War      bool
+    WarDepth int  // Rounds of war played, 0 for a plain trick
+    CardsLeftA int // Cards Player A holds after the trick
+    CardsLeftB int // Cards Player B holds after the trick
+}
+
+// GameTranscript collects the tricks of a game played with
+// Options.Transcript set to it.
+type GameTranscript struct {
+    Entries []TranscriptEntry
+}
+
+func (t *GameTranscript) record(trick int, cardsA, cardsB []Card, winner, warDepth, cardsLeftA, cardsLeftB int) {
+    t.Entries = append(t.Entries, TranscriptEntry{
+        Trick:    trick,
+        CardsA:   cardsA,
+        CardsB:   cardsB,
+        Winner:   winner,
+        War:      warDepth > 0,
+        WarDepth: warDepth,
+        CardsLeftA: cardsLeftA,
+        CardsLeftB: cardsLeftB,
+    })
+}
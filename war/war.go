@@ -0,0 +1,1190 @@
+// Package war simulates games of the card game War. PlayGame deals and plays
+// a single game and RunSimulations plays a batch, each returning the
+// per-game GameStats.
+package war
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "math/rand"
+    "os"
+    "runtime/debug"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+type Card struct {
+    Rank int
+    Suit Suit // Carried for identity only; play compares ranks
+}
+
+type Suit int
+
+const (
+    Clubs Suit = iota
+    Diamonds
+    Hearts
+    Spades
+)
+
+// String returns the suit's symbol.
+func (s Suit) String() string {
+    if s < Clubs || s > Spades {
+        return "?"
+    }
+    return []string{"♣", "♦", "♥", "♠"}[s]
+}
+
+// ParseRank reads a rank written as a number from 2 to 15 or as one of the
+// names RankName gives: J, Q, K, A or Joker, in any case.
+func ParseRank(name string) (int, error) {
+    switch strings.ToUpper(name) {
+    case "J":
+        return 11, nil
+    case "Q":
+        return 12, nil
+    case "K":
+        return 13, nil
+    case "A":
+        return 14, nil
+    case "JOKER":
+        return 15, nil
+    }
+    rank, err := strconv.Atoi(name)
+    if err != nil || rank < 2 || rank > 15 {
+        return 0, fmt.Errorf("invalid rank %q (want 2 to 15, J, Q, K, A or Joker)", name)
+    }
+    return rank, nil
+}
+
+// RankName returns the short name of a rank: 2 to 10, J, Q, K, A, or Joker
+// for 15. Any other rank is shown as a number in parentheses.
+func RankName(rank int) string {
+    switch {
+    case rank >= 2 && rank <= 10:
+        return strconv.Itoa(rank)
+    case rank >= 11 && rank <= 14:
+        return []string{"J", "Q", "K", "A"}[rank-11]
+    case rank == 15:
+        return "Joker"
+    }
+    return "(" + strconv.Itoa(rank) + ")"
+}
+
+// String returns the card's rank name followed by its suit symbol, such as
+// "10♥" or "Q♠". Jokers are shown as "Joker" alone.
+func (c Card) String() string {
+    if c.Rank == 15 {
+        return RankName(c.Rank)
+    }
+    return RankName(c.Rank) + c.Suit.String()
+}
+
+type Player struct {
+    DrawPile     Pile
+    WinningsPile Pile
+    Discard      *Pile // Central discard shared by both players (central-discard variant only)
+}
+
+type GameStats struct {
+    GameNumber    int
+    Seed          int64 // Seed of the game's random source: GameSeed of the run seed and GameNumber
+    Tricks        int
+    Wars          int
+    DeepWars      int
+    TotalWarDepth int
+    MaxWarDepth   int // Rounds played in the game's longest war
+    ShufflesA     int
+    ShufflesB     int
+    WarShufflesA  int // Of ShufflesA, those Player A made while laying cards for a war
+    WarShufflesB  int // Of ShufflesB, those Player B made while laying cards for a war
+    GameDuration  time.Duration
+    LastTimeCheck time.Duration // Time when the limit was last checked; any -maxtime above it plays the game the same
+    Finished      bool
+    PlayerATricks int  // Renamed from PlayerAWins
+    PlayerBTricks int  // Renamed from PlayerBWins
+    Winner        int // Seat of the winner: 1 for Player A, 2 for Player B, 3 and up for the further players of -players; 0 without one
+    NoReshuffleWinner int // Winner of the same deal played without reshuffling (-determinism-probe only)
+    PermutedWinner    int // Winner of the same game with the suits permuted within every rank (-verify only)
+    PermutedTricks    int // Tricks of the same game with the suits permuted within every rank (-verify only)
+    ShuffleTricksA []int // Trick on which each of Player A's reshuffles happened (-log only)
+    ShuffleTricksB []int // Trick on which each of Player B's reshuffles happened (-log only)
+    TerminationReason string // exhaustion, simultaneous, draw, timeout, trick-limit, cycle, early-draw or error
+    InitialDealA []Card // Player A's starting hand, top card first (recorded only when needed)
+    InitialDealB []Card // Player B's starting hand, top card first (recorded only when needed)
+    PipsA         int // Sum of ranks in Player A's starting hand
+    PipsB         int // Sum of ranks in Player B's starting hand
+    ExtraCardTo   int // 1 or 2 for whichever of Player A and B was dealt more cards, 0 if neither was
+    SuitTieBreaks int // Rank ties decided by suit instead of a war (-tiebreak suit only)
+    JokerTricks   int // Tricks, wars included, in which a joker was played
+    FinalCardsA   int // Cards in Player A's own piles when the game ended
+    FinalCardsB   int // Cards in Player B's own piles when the game ended; with FinalCardsA short of the deck by any cards on the table in a war cut off by the time limit, or in a shared discard
+    WinnerMargin  int // Cards the winner held beyond the runner-up at the end, counted as FinalCardsA and B are; 0 without a winner
+    Errored       bool // The game panicked; Tricks is -1 and nothing else is recorded but PanicTrace
+    PanicTrace    string // The panic value and stack trace of an errored game
+    PlayerTricks  []int // Tricks won by each player in seat order, Player A first
+    DepthCapped   int   // Wars decided on cards held at the -max-war-depth cap
+    EarlyDraw     bool  // Ended as a draw because the players' hands matched card for card right after a reshuffle
+    LeadChanges   int   // Times the lead passed from one player to the other (-track-lead only)
+    WinnerWasBehind bool // The winner trailed at some point during the game (-track-lead only)
+    RankWins      [16]int // Tricks won, by the rank of the face-up card that decided them; index 15 is the joker
+}
+
+
+type WarResult struct {
+    Winner        int // 1 for Player A, 2 for Player B
+    PlayerATricks int // Renamed from PlayerAWins
+    PlayerBTricks int // Renamed from PlayerBWins
+    CardsA        []Card // Every card Player A committed to the war, in the order played
+    CardsB        []Card // Every card Player B committed to the war, in the order played
+    TimedOut      bool   // The time limit cut the war off; Winner is decided on cards held
+    DepthCapped   bool   // The war reached MaxWarDepth and was decided on cards held
+    DecidedBy     Card   // The face-up card that won the last round; zero if no card did
+}
+
+// Options holds the settings that change how games are played.
+type Options struct {
+    DeterminismProbe bool   // Replay each deal without reshuffling and compare winners
+    VerifySuits      bool   // Replay each game with the suits permuted within every rank and record the outcome
+    RNGWarmup        int    // Number of initial outputs of each game's random source to discard
+    NoReshuffle      bool   // Flip the winnings pile over in order instead of shuffling it
+    WarCollectOrder  string // How a won war pile is stacked: interleaved, owner-grouped or shuffled
+    CollectOrder     string // Whose cards a winner takes first: fixed (seat order), random or winner-first
+    Variant          string // Rule variant: standard, count-tricks, central-discard or single-pile
+    Log              bool   // Record per-game event logs such as reshuffle tricks
+    TrackLead        bool   // Follow who leads after every trick, for LeadChanges and WinnerWasBehind
+    SimulEnd         string // Outcome when both players run out together: a, b, draw or pile
+    RecordDeal       bool   // Keep each game's starting hands in its stats
+    InitialSortedness float64 // Fraction of a new deck left in factory order: 0 shuffled, 1 unshuffled
+    FairDeal         bool   // Swap cards between the shuffled halves until their pip sums are within FairDealTolerance
+    FairDealTolerance int   // Largest pip difference a fair deal leaves between the starting hands
+    TieBreak         string // How equal ranks are settled: war, or suit for the higher suit in SuitOrder
+    SuitOrder        string // Suit letters from lowest to highest, such as cdhs
+    JokerRule        string // How a joker compares: high (beats everything), wild (ties anything) or low
+    WarDown          int    // Cards each player lays face down in a round of war
+    MaxWarDepth      int    // Rounds after which a war still tied is decided on cards held; 0 for no limit
+    MaxTricks        int    // Tricks after which a game is stopped unfinished as a safety net; 0 for no limit
+    ShortWar         string // A player short of cards for a round of war: play-out (lays what they have) or forfeit (loses the war)
+    ShuffleModel     string // How players shuffle their winnings: uniform, riffle or overhand
+    Players          int    // Number of players; more than two plays the standard rules only
+    Transcript       *GameTranscript `json:"-"` // Receives every trick when set; for single games only
+    Progress         *atomic.Int64   `json:"-"` // Incremented as each game of a run finishes when set
+    Logger           Logger          `json:"-"` // Receives the report of every game that panics; standard error when nil
+    Context          context.Context `json:"-"` // Stops a run when done: games not played to the end are dropped
+    Deck             DeckSpec // Ranks and copies of the deck that is shuffled and dealt
+    Deal             [][]Card // Player A's and B's starting hands, top card first, played instead of a shuffled deal when set
+}
+
+// DefaultOptions returns the standard rules: interleaved war piles, wars on
+// ties with three cards face down, a fully shuffled standard deck and Player B
+// winning a simultaneous end.
+func DefaultOptions() Options {
+    return Options{
+        WarCollectOrder: "interleaved",
+        CollectOrder:    "fixed",
+        Variant:         "standard",
+        SimulEnd:        "b",
+        TieBreak:        "war",
+        SuitOrder:       "cdhs",
+        JokerRule:       "high",
+        WarDown:         3,
+        ShortWar:        "play-out",
+        ShuffleModel:    "uniform",
+        MaxTricks:       10000000,
+        Players:         2,
+        Deck:            StandardDeck,
+    }
+}
+
+// Validate checks that every named option has a known value.
+func (opts Options) Validate() error {
+    switch opts.WarCollectOrder {
+    case "interleaved", "owner-grouped", "shuffled":
+    default:
+        return fmt.Errorf("unknown war collect order %q (want interleaved, owner-grouped or shuffled)", opts.WarCollectOrder)
+    }
+
+    switch opts.CollectOrder {
+    case "fixed", "random", "winner-first":
+    default:
+        return fmt.Errorf("unknown collect order %q (want fixed, random or winner-first)", opts.CollectOrder)
+    }
+
+    switch opts.Variant {
+    case "standard", "count-tricks", "central-discard", "single-pile":
+    default:
+        return fmt.Errorf("unknown variant %q (want standard, count-tricks, central-discard or single-pile)", opts.Variant)
+    }
+
+    switch opts.TieBreak {
+    case "war", "suit":
+    default:
+        return fmt.Errorf("unknown tie-break %q (want war or suit)", opts.TieBreak)
+    }
+    if opts.VerifySuits && opts.TieBreak == "suit" {
+        return fmt.Errorf("suit verification checks that suits never matter, but the suit tie-break uses them")
+    }
+    switch opts.JokerRule {
+    case "high", "wild", "low":
+    default:
+        return fmt.Errorf("unknown joker rule %q (want high, wild or low)", opts.JokerRule)
+    }
+
+    if len(opts.SuitOrder) != 4 || strings.Trim("cdhs", opts.SuitOrder) != "" {
+        return fmt.Errorf("invalid suit order %q (want each of c, d, h and s once)", opts.SuitOrder)
+    }
+
+    if opts.RNGWarmup < 0 {
+        return fmt.Errorf("rng warmup must not be negative, got %d", opts.RNGWarmup)
+    }
+    if opts.WarDown < 0 {
+        return fmt.Errorf("war-down must not be negative, got %d", opts.WarDown)
+    }
+    if opts.MaxWarDepth < 0 {
+        return fmt.Errorf("max war depth must not be negative, got %d", opts.MaxWarDepth)
+    }
+    if opts.FairDealTolerance < 0 {
+        return fmt.Errorf("fair deal tolerance must not be negative, got %d", opts.FairDealTolerance)
+    }
+    if opts.FairDeal && (opts.Players > 2 || opts.Deal != nil) {
+        return fmt.Errorf("a fair deal is supported for two players dealt a shuffled deck only")
+    }
+    if opts.MaxTricks < 0 {
+        return fmt.Errorf("max tricks must not be negative, got %d", opts.MaxTricks)
+    }
+    if opts.MaxWarDepth > 0 && opts.Players > 2 {
+        return fmt.Errorf("a war depth cap is supported for two players only")
+    }
+    if opts.TrackLead && opts.Players > 2 {
+        return fmt.Errorf("lead tracking is supported for two players only")
+    }
+    switch opts.ShortWar {
+    case "play-out", "forfeit":
+    default:
+        return fmt.Errorf("unknown short war rule %q (want play-out or forfeit)", opts.ShortWar)
+    }
+
+    switch opts.ShuffleModel {
+    case "uniform", "riffle", "overhand":
+    default:
+        return fmt.Errorf("unknown shuffle model %q (want uniform, riffle or overhand)", opts.ShuffleModel)
+    }
+
+    if opts.Players < 2 || opts.Players > 26 {
+        return fmt.Errorf("players must be between 2 and 26, got %d", opts.Players)
+    }
+    if opts.Players > 2 && (opts.Variant != "standard" || opts.TieBreak != "war") {
+        return fmt.Errorf("more than two players requires the standard variant and war tie-breaks")
+    }
+
+    if opts.Deck.MinRank < 2 || opts.Deck.MaxRank > 14 || opts.Deck.MinRank > opts.Deck.MaxRank {
+        return fmt.Errorf("invalid deck ranks %s to %s (want a range within 2 to A)", RankName(opts.Deck.MinRank), RankName(opts.Deck.MaxRank))
+    }
+    if opts.Deck.Copies < 1 {
+        return fmt.Errorf("deck copies must be at least 1, got %d", opts.Deck.Copies)
+    }
+    if opts.TieBreak == "suit" && opts.Deck.Copies > 4 {
+        return fmt.Errorf("the suit tie-break needs a deck of at most 4 copies of each rank, so that no two cards share a rank and suit, got %d", opts.Deck.Copies)
+    }
+    if opts.Deck.Size() < max(2, opts.Players) {
+        return fmt.Errorf("a deck of %d cards is too small for %d players", opts.Deck.Size(), opts.Players)
+    }
+
+    if opts.Deal != nil && (len(opts.Deal) != 2 || len(opts.Deal[0]) == 0 || len(opts.Deal[1]) == 0 || opts.Players > 2) {
+        return fmt.Errorf("a fixed deal needs a non-empty hand for each of two players")
+    }
+
+    if opts.InitialSortedness < 0 || opts.InitialSortedness > 1 {
+        return fmt.Errorf("initial sortedness must be between 0 and 1, got %g", opts.InitialSortedness)
+    }
+
+    switch opts.SimulEnd {
+    case "a", "b", "draw", "pile":
+    default:
+        return fmt.Errorf("unknown simultaneous end rule %q (want a, b, draw or pile)", opts.SimulEnd)
+    }
+    return nil
+}
+
+// Simulated time is counted in hands and shuffles. A hand is both players
+// putting down a card at the same time and costs handTime: a trick is one
+// hand, and a round of war is one hand per card laid by whoever laid more,
+// normally WarDown+1. A shuffle costs shuffleTime, charged once per trick or round
+// of war in which either player, or both, had to shuffle. So a game lasts
+// handTime × (tricks + cards per war round) + shuffleTime × shuffle breaks.
+
+// RunSimulations plays gamesToPlay games, numbered from 1, on up to workers
+// goroutines and returns their stats in order. Every game has its own random
+// source seeded from seed and the game number, so the results for a seed do
+// not depend on the number of workers. A game that panics is recorded with
+// Errored set, Tricks -1, TerminationReason "error" and the panic's stack in
+// PanicTrace.
+func RunSimulations(gamesToPlay, handTime, shuffleTime int, includeJokers bool, maxGameTime int, seed int64, workers int, opts Options) []GameStats {
+    return RunGameRange(0, gamesToPlay, handTime, shuffleTime, includeJokers, maxGameTime, seed, workers, opts)
+}
+
+// RunGameRange plays the count games numbered start+1 to start+count, seeded
+// exactly as they would be in a RunSimulations of the whole run, so a run can
+// be split into ranges and played in pieces.
+//
+// When Options.Context is cancelled no more games are started and the games
+// in progress stop within cancelCheckTricks tricks. The stats returned are
+// then those of the games played to the end before the first one that was
+// not, so they are still the first games of the range, in order.
+func RunGameRange(start, count, handTime, shuffleTime int, includeJokers bool, maxGameTime int, seed int64, workers int, opts Options) []GameStats {
+    stats := make([]GameStats, count)
+    playGameRange(start, count, handTime, shuffleTime, includeJokers, maxGameTime, seed, workers, opts, func(i int, game GameStats) {
+        stats[i] = game
+    })
+
+    if opts.Context != nil {
+        for i, game := range stats {
+            if game.GameNumber == 0 || game.TerminationReason == "cancelled" {
+                return stats[:i]
+            }
+        }
+    }
+    return stats
+}
+
+// StreamGameRange plays the same games as RunGameRange but sends each game's
+// stats to out as soon as it has been played instead of keeping them, so the
+// games arrive in the order they finish rather than by number. It returns
+// when every game has been sent and leaves out open. When Options.Context is
+// cancelled, the games it stops are not sent.
+func StreamGameRange(start, count, handTime, shuffleTime int, includeJokers bool, maxGameTime int, seed int64, workers int, opts Options, out chan<- GameStats) {
+    playGameRange(start, count, handTime, shuffleTime, includeJokers, maxGameTime, seed, workers, opts, func(_ int, game GameStats) {
+        if game.TerminationReason != "cancelled" {
+            out <- game
+        }
+    })
+}
+
+// playGameRange plays the games of RunGameRange on up to workers goroutines,
+// passing each one to played, from the goroutine that played it, with its
+// index in the range.
+func playGameRange(start, count, handTime, shuffleTime int, includeJokers bool, maxGameTime int, seed int64, workers int, opts Options, played func(i int, game GameStats)) {
+    next := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < max(workers, 1); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            deck := make([]Card, 0, 54) // Refilled by every game instead of allocating a new deck
+            for i := range next {
+                if opts.Context != nil && opts.Context.Err() != nil {
+                    continue // Not played, so RunGameRange leaves it with GameNumber 0
+                }
+                gameNumber := start + i + 1
+                played(i, playNumberedGame(deck, gameNumber, handTime, shuffleTime, includeJokers, maxGameTime, seed, opts))
+                if opts.Progress != nil {
+                    opts.Progress.Add(1)
+                }
+            }
+        }()
+    }
+    for i := 0; i < count; i++ {
+        if opts.Context != nil && opts.Context.Err() != nil {
+            break
+        }
+        next <- i
+    }
+    close(next)
+    wg.Wait()
+}
+
+// cancelCheckTricks is how often, in tricks, a game checks Options.Context.
+const cancelCheckTricks = 1000
+
+// cancelled reports whether the run's Context is done, checking only every
+// cancelCheckTricks tricks. A game that is stops at once with
+// TerminationReason "cancelled", and RunGameRange drops it.
+func cancelled(opts Options, tricks int) bool {
+    return opts.Context != nil && tricks%cancelCheckTricks == 0 && opts.Context.Err() != nil
+}
+
+// Logger receives the engine's error reports.
+type Logger interface {
+    Errorf(format string, args ...any)
+}
+
+// stderrLogger is the Logger used when Options.Logger is nil.
+type stderrLogger struct{}
+
+func (stderrLogger) Errorf(format string, args ...any) {
+    fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+}
+
+// playNumberedGame plays game gameNumber of a run from its own random source.
+func playNumberedGame(deck []Card, gameNumber, handTime, shuffleTime int, includeJokers bool, maxGameTime int, seed int64, opts Options) GameStats {
+    return playSeededGame(deck, gameNumber, GameSeed(seed, gameNumber), handTime, shuffleTime, includeJokers, maxGameTime, opts)
+}
+
+// PlaySeededGame plays on its own, as game 1, the game whose random source is
+// seeded with gameSeed, the Seed in its GameStats, exactly as RunGameRange
+// played it.
+func PlaySeededGame(gameSeed int64, handTime, shuffleTime int, includeJokers bool, maxGameTime int, opts Options) GameStats {
+    return playSeededGame(nil, 1, gameSeed, handTime, shuffleTime, includeJokers, maxGameTime, opts)
+}
+
+// playSeededGame plays game gameNumber from the random source seeded with
+// gameSeed, recovering from a panic in the game and reporting it, with the
+// stack, to the Logger.
+func playSeededGame(deck []Card, gameNumber int, gameSeed int64, handTime, shuffleTime int, includeJokers bool, maxGameTime int, opts Options) (stats GameStats) {
+    defer func() {
+        if r := recover(); r != nil {
+            logger := opts.Logger
+            if logger == nil {
+                logger = stderrLogger{}
+            }
+            trace := fmt.Sprintf("%v\n%s", r, debug.Stack())
+            logger.Errorf("game %d panicked: %s", gameNumber, trace)
+            stats = GameStats{GameNumber: gameNumber, Seed: gameSeed, Tricks: -1, Finished: false, TerminationReason: "error", Errored: true, PanicTrace: trace} // Use -1 to indicate an error
+        }
+    }()
+    rng := NewSeededRand(gameSeed, opts.RNGWarmup)
+    if opts.DeterminismProbe {
+        stats = playProbeGame(deck, handTime, shuffleTime, includeJokers, maxGameTime, opts, rng)
+    } else {
+        stats = PlayGame(deck, handTime, shuffleTime, includeJokers, maxGameTime, opts, rng)
+    }
+    if opts.VerifySuits && stats.TerminationReason != "cancelled" {
+        permuted := playSuitPermutedGame(deck, handTime, shuffleTime, includeJokers, maxGameTime, opts, NewSeededRand(gameSeed, opts.RNGWarmup), rand.New(rand.NewSource(^gameSeed)))
+        if permuted.TerminationReason == "cancelled" {
+            stats = permuted
+        } else {
+            stats.PermutedWinner, stats.PermutedTricks = permuted.Winner, permuted.Tricks
+        }
+    }
+    stats.GameNumber, stats.Seed = gameNumber, gameSeed
+    return stats
+}
+
+// GameSeed returns the seed of the random source that game gameNumber of a
+// run seeded with seed is played from: splitmix64 of the run seed mixed with
+// the game number, so runs with nearby seeds share no games.
+func GameSeed(seed int64, gameNumber int) int64 {
+    z := uint64(seed) ^ uint64(gameNumber)*0x9e3779b97f4a7c15
+    z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+    z = (z ^ z>>27) * 0x94d049bb133111eb
+    return int64(z ^ z>>31)
+}
+
+// NewGameRand returns the random source that RunSimulations gives game
+// gameNumber of a run seeded with seed, after discarding warmup outputs.
+// Passing it to PlayGame plays that game on its own.
+func NewGameRand(seed int64, gameNumber, warmup int) *rand.Rand {
+    return NewSeededRand(GameSeed(seed, gameNumber), warmup)
+}
+
+// NewSeededRand returns the random source of the game whose GameSeed is
+// gameSeed, after discarding warmup outputs.
+func NewSeededRand(gameSeed int64, warmup int) *rand.Rand {
+    rng := rand.New(rand.NewSource(gameSeed))
+    for i := 0; i < warmup; i++ {
+        rng.Int63()
+    }
+    return rng
+}
+
+// PlayGame deals and plays one game. The deck is built in deck's backing
+// array, so passing the same buffer to every game avoids allocating a fresh
+// deck each time; a nil deck allocates one.
+func PlayGame(deck []Card, handTime, shuffleTime int, includeJokers bool, maxGameTime int, opts Options, rng *rand.Rand) GameStats {
+    deck, split := dealDeck(deck, includeJokers, opts, rng)
+    return playDealAt(deck, split, handTime, shuffleTime, maxGameTime, opts, rng)
+}
+
+// dealDeck builds the deck for a game in deck's backing array and returns it
+// with the number of cards that go to Player A: a shuffled deck split in
+// half, or the fixed Deal with A's hand followed by B's. Player B, who is
+// dealt the second half, gets the extra card of an odd deck.
+func dealDeck(deck []Card, includeJokers bool, opts Options, rng *rand.Rand) ([]Card, int) {
+    if opts.Deal != nil {
+        deck = append(append(deck[:0], opts.Deal[0]...), opts.Deal[1]...)
+        return deck, len(opts.Deal[0])
+    }
+    deck = FillDeck(deck[:0], opts.Deck, includeJokers)
+    partialShuffle(deck, opts.InitialSortedness, rng)
+    if opts.FairDeal {
+        balanceDeal(deck, len(deck)/2, opts.FairDealTolerance)
+    }
+    return deck, len(deck) / 2
+}
+
+// balanceDeal evens out the pip sums of the hands deck[:split] and
+// deck[split:] by swapping cards between them, each time making the swap
+// that brings the sums closest, until they differ by at most tolerance or
+// no swap brings them closer. Every card keeps its position within the hand
+// it ends up in, so the hands are otherwise still as shuffled.
+func balanceDeal(deck []Card, split, tolerance int) {
+    diff := pipSum(deck[:split]) - pipSum(deck[split:])
+    for abs(diff) > tolerance {
+        bestI, bestJ, best := -1, -1, abs(diff)
+        for i := 0; i < split; i++ {
+            for j := split; j < len(deck); j++ {
+                if d := abs(diff - 2*(deck[i].Rank-deck[j].Rank)); d < best {
+                    bestI, bestJ, best = i, j, d
+                }
+            }
+        }
+        if bestI < 0 {
+            return
+        }
+        diff -= 2 * (deck[bestI].Rank - deck[bestJ].Rank)
+        deck[bestI], deck[bestJ] = deck[bestJ], deck[bestI]
+    }
+}
+
+func abs(n int) int {
+    if n < 0 {
+        return -n
+    }
+    return n
+}
+
+// playProbeGame plays a shuffled deal normally, then replays the same deal
+// with reshuffling disabled and records that game's winner alongside.
+func playProbeGame(deck []Card, handTime, shuffleTime int, includeJokers bool, maxGameTime int, opts Options, rng *rand.Rand) GameStats {
+    deck, split := dealDeck(deck, includeJokers, opts, rng)
+    counterpartDeck := append([]Card(nil), deck...)
+
+    stats := playDealAt(deck, split, handTime, shuffleTime, maxGameTime, opts, rng)
+    counterpartOpts := opts
+    counterpartOpts.NoReshuffle = true
+    counterpart := playDealAt(counterpartDeck, split, handTime, shuffleTime, maxGameTime, counterpartOpts, rng)
+    if counterpart.TerminationReason == "cancelled" {
+        return counterpart
+    }
+    stats.NoReshuffleWinner = counterpart.Winner
+    return stats
+}
+
+// playSuitPermutedGame deals a game from rng as PlayGame does, then swaps the
+// suits of each rank's cards by a permutation drawn from permRng before
+// playing it. rng is used exactly as in the original game, so under rules
+// that compare ranks only the two games are played trick for trick the same.
+func playSuitPermutedGame(deck []Card, handTime, shuffleTime int, includeJokers bool, maxGameTime int, opts Options, rng, permRng *rand.Rand) GameStats {
+    deck, split := dealDeck(deck, includeJokers, opts, rng)
+    permuteSuits(deck, permRng)
+    return playDealAt(deck, split, handTime, shuffleTime, maxGameTime, opts, rng)
+}
+
+// permuteSuits relabels the suits of deck with a random permutation of the
+// four suits for each rank, leaving every card where it is.
+func permuteSuits(deck []Card, rng *rand.Rand) {
+    perms := make(map[int][]int)
+    for i, card := range deck {
+        perm, ok := perms[card.Rank]
+        if !ok {
+            perm = rng.Perm(4)
+            perms[card.Rank] = perm
+        }
+        deck[i].Suit = Suit(perm[card.Suit])
+    }
+}
+
+// PlayDeal plays a game from an already shuffled deck, splitting it in half
+// between the two players. With more than two players it deals the deck
+// round-robin and plays the general game in playMultiDeal instead.
+func PlayDeal(deck []Card, handTime, shuffleTime, maxGameTime int, opts Options, rng *rand.Rand) GameStats {
+    return playDealAt(deck, len(deck)/2, handTime, shuffleTime, maxGameTime, opts, rng)
+}
+
+// playDealAt plays a game from deck with its first split cards dealt to
+// Player A and the rest to Player B, one PlayTrick at a time.
+func playDealAt(deck []Card, split, handTime, shuffleTime, maxGameTime int, opts Options, rng *rand.Rand) GameStats {
+    if opts.Players > 2 {
+        return playMultiDeal(deck, handTime, shuffleTime, maxGameTime, opts, rng)
+    }
+
+    g := newGame(deck, split, handTime, shuffleTime, maxGameTime, opts, rng)
+    for !g.Over() {
+        if cancelled(opts, g.stats.Tricks) {
+            return GameStats{TerminationReason: "cancelled"}
+        }
+        g.PlayTrick()
+    }
+    return g.Stats()
+}
+
+// deterministicPlay reports whether a game's course is fixed by the deal:
+// winnings are never shuffled and neither are war piles or a shared discard,
+// and won cards are collected in an order fixed by the play.
+func deterministicPlay(opts Options) bool {
+    return (opts.NoReshuffle || opts.Variant == "single-pile") &&
+        opts.Variant != "central-discard" && opts.Variant != "count-tricks" &&
+        opts.WarCollectOrder != "shuffled" && opts.CollectOrder != "random"
+}
+
+// extraCardTo returns 1 if Player A was dealt more cards than Player B, 2 if
+// B was, and 0 if they were dealt the same number.
+func extraCardTo(cardsA, cardsB int) int {
+    switch {
+    case cardsA > cardsB:
+        return 1
+    case cardsB > cardsA:
+        return 2
+    }
+    return 0
+}
+
+// identicalHands reports whether, with cardA and cardB just turned up, both
+// players have nothing but a draw pile and the two hands match rank for rank.
+// Every trick is then a tie, and so is every round of war it starts, until
+// both players run out together, so the game is a draw whenever the
+// simultaneous end rule makes that one. In count-tricks the tricks already
+// won still decide, and a depth cap can settle the war first, so it never
+// applies to them.
+func identicalHands(playerA, playerB *Player, cardA, cardB Card, stats *GameStats, opts Options) bool {
+    if opts.TieBreak == "suit" || opts.Variant == "count-tricks" || opts.MaxWarDepth > 0 || playerA.Discard != nil ||
+        playerA.WinningsPile.Len() > 0 || playerB.WinningsPile.Len() > 0 ||
+        playerA.DrawPile.Len() != playerB.DrawPile.Len() {
+        return false
+    }
+    switch opts.SimulEnd {
+    case "draw":
+    case "pile":
+        if stats.PlayerATricks != stats.PlayerBTricks {
+            return false
+        }
+    default:
+        return false
+    }
+    if rankA, rankB := playRanks(cardA, cardB, opts); rankA != rankB {
+        return false
+    }
+    for i := 0; i < playerA.DrawPile.Len(); i++ {
+        rankA, rankB := playRanks(playerA.DrawPile.At(i), playerB.DrawPile.At(i), opts)
+        if rankA != rankB {
+            return false
+        }
+    }
+    return true
+}
+
+// stateHash fingerprints the order of every card in both players' piles with
+// 64-bit FNV-1a, marking the boundaries between piles.
+func stateHash(playerA, playerB *Player) uint64 {
+    const prime = 1099511628211
+    hash := uint64(14695981039346656037)
+    for _, pile := range []*Pile{&playerA.DrawPile, &playerA.WinningsPile, &playerB.DrawPile, &playerB.WinningsPile} {
+        for i := 0; i < pile.Len(); i++ {
+            card := pile.At(i)
+            hash = (hash ^ uint64(card.Rank)<<2 ^ uint64(card.Suit)) * prime
+        }
+        hash = (hash ^ 0xff) * prime
+    }
+    return hash
+}
+
+// cycleDetector finds a repeated state with Brent's algorithm, in constant
+// memory however long a game runs: it saves the state at every power of two
+// steps and looks for it again until the next. Once the game is in its
+// loop, a saved state recurs as soon as the gap between saves reaches the
+// loop's length, so the repeat is found within a few times the tricks it
+// took to begin repeating.
+type cycleDetector struct {
+    saved        uint64
+    power, steps int
+}
+
+// repeats reports whether state is the last saved one, and counts the step.
+func (c *cycleDetector) repeats(state uint64) bool {
+    if c.power > 1 && state == c.saved {
+        return true
+    }
+    c.steps++
+    if c.steps == c.power {
+        c.saved, c.power, c.steps = state, c.power*2, 0
+    }
+    return false
+}
+
+// chargeShuffles counts the reshuffles each player made while drawing for a
+// trick or a round of war, and charges shuffleTime once if either shuffled:
+// the players shuffle at the same time.
+func chargeShuffles(stats *GameStats, totalTime *int, shuffledA, shuffledB, shuffleTime int, opts Options) {
+    stats.ShufflesA += shuffledA
+    stats.ShufflesB += shuffledB
+    if opts.Log {
+        recordShuffleTricks(&stats.ShuffleTricksA, shuffledA, stats.Tricks)
+        recordShuffleTricks(&stats.ShuffleTricksB, shuffledB, stats.Tricks)
+    }
+    if shuffledA > 0 || shuffledB > 0 {
+        *totalTime += shuffleTime
+    }
+}
+
+// recordShuffleTricks notes count reshuffles as happening on trick.
+func recordShuffleTricks(shuffleTricks *[]int, count, trick int) {
+    for i := 0; i < count; i++ {
+        *shuffleTricks = append(*shuffleTricks, trick)
+    }
+}
+
+// drawWarCards draws a player's cards for one round of war, WarDown face down
+// and one face up. A player short of cards lays what they have, and the last
+// of them is the face-up card. Like drawCard, it also returns how many times
+// the player reshuffled.
+func drawWarCards(player *Player, opts Options, rng *rand.Rand) ([]Card, int) {
+    cards := make([]Card, 0, opts.WarDown+1)
+    shuffles := 0
+    for i := 0; i <= opts.WarDown; i++ {
+        card, shuffled := drawCard(player, opts, rng)
+        shuffles += shuffled
+        if (card == Card{}) {
+            break // No more cards available
+        }
+        cards = append(cards, card)
+    }
+    return cards, shuffles
+}
+
+// collectCards gives the cards won in a trick to player. In the count-tricks
+// variant won cards are thrown away, in the central-discard variant they go
+// to the shared discard, and in the single-pile variant they go straight to
+// the bottom of the draw pile, so the player never reshuffles.
+func collectCards(player *Player, cards []Card, opts Options) {
+    switch opts.Variant {
+    case "count-tricks":
+        return
+    case "central-discard":
+        player.Discard.Add(cards...)
+        return
+    case "single-pile":
+        player.DrawPile.Add(cards...)
+        return
+    }
+    player.WinningsPile.Add(cards...)
+}
+
+// trickCards returns the two cards of a plain trick won by winner (1 for A,
+// 2 for B) in the order they are collected: Player A's first with the fixed
+// collect order, the winner's first with winner-first, and either way round
+// at random with random.
+func trickCards(cardA, cardB Card, winner int, opts Options, rng *rand.Rand) []Card {
+    switch opts.CollectOrder {
+    case "winner-first":
+        if winner == 2 {
+            return []Card{cardB, cardA}
+        }
+    case "random":
+        if rng.Intn(2) == 1 {
+            return []Card{cardB, cardA}
+        }
+    }
+    return []Card{cardA, cardB}
+}
+
+// orderPiles puts the piles of cards each player committed to a war, given in
+// seat order, in the order the collect order takes them: seat order for
+// fixed, the winning seat's pile first and the rest in seat order for
+// winner-first, and a random order for random. A winner of -1 means nobody
+// collects, and the piles are left as they are.
+func orderPiles(piles [][]Card, winner int, opts Options, rng *rand.Rand) [][]Card {
+    if winner < 0 {
+        return piles
+    }
+    switch opts.CollectOrder {
+    case "winner-first":
+        if winner > 0 {
+            ordered := make([][]Card, 0, len(piles))
+            ordered = append(ordered, piles[winner])
+            ordered = append(ordered, piles[:winner]...)
+            return append(ordered, piles[winner+1:]...)
+        }
+    case "random":
+        ordered := append([][]Card(nil), piles...)
+        rng.Shuffle(len(ordered), func(i, j int) { ordered[i], ordered[j] = ordered[j], ordered[i] })
+        return ordered
+    }
+    return piles
+}
+
+// collectWarPile stacks the cards each player committed to a war, in the order
+// they were played, into the pile the winner collects. piles holds each
+// player's cards in seat order:
+//   interleaved   - one card from each player in turn: A, B, A, B, ... (the
+//                   leftover tail of a longer pile goes last)
+//   owner-grouped - all of A's cards followed by all of B's, and so on
+//   shuffled      - a random order
+func collectWarPile(piles [][]Card, order string, rng *rand.Rand) []Card {
+    total, longest := 0, 0
+    for _, cards := range piles {
+        total += len(cards)
+        longest = max(longest, len(cards))
+    }
+    pile := make([]Card, 0, total)
+    switch order {
+    case "owner-grouped":
+        for _, cards := range piles {
+            pile = append(pile, cards...)
+        }
+    case "shuffled":
+        for _, cards := range piles {
+            pile = append(pile, cards...)
+        }
+        ShuffleDeck(pile, rng)
+    default:
+        for i := 0; i < longest; i++ {
+            for _, cards := range piles {
+                if i < len(cards) {
+                    pile = append(pile, cards[i])
+                }
+            }
+        }
+    }
+    return pile
+}
+
+// handleWar plays out a war, round by round until a round's face-up cards
+// differ or a player cannot go on. committedA and committedB hold the cards
+// each player has put in so far, starting with the tied cards; the result
+// carries them along with everything drawn during the war, for the winner to
+// collect.
+func handleWar(playerA, playerB *Player, committedA, committedB []Card, stats *GameStats, totalTime *int, handTime, shuffleTime, maxGameTime int, opts Options, rng *rand.Rand) WarResult {
+    for depth := 1; ; depth++ {
+        stats.Wars++
+        stats.TotalWarDepth += depth
+        stats.MaxWarDepth = max(stats.MaxWarDepth, depth)
+
+        if timeUp(stats, *totalTime, maxGameTime) {
+            result := timeoutResult(playerA, playerB)
+            result.CardsA, result.CardsB = committedA, committedB
+            return result
+        }
+
+        cardsA, shuffledA := drawWarCards(playerA, opts, rng)
+        cardsB, shuffledB := drawWarCards(playerB, opts, rng)
+        stats.WarShufflesA += shuffledA
+        stats.WarShufflesB += shuffledB
+        *totalTime += handTime * max(len(cardsA), len(cardsB))
+        chargeShuffles(stats, totalTime, shuffledA, shuffledB, shuffleTime, opts)
+
+        committedA = append(committedA, cardsA...)
+        committedB = append(committedB, cardsB...)
+
+        // Under the forfeit rule a player who cannot lay a full round loses
+        // the war, and with it the whole pot, unless neither can.
+        forfeit := opts.ShortWar == "forfeit"
+        shortA, shortB := len(cardsA) <= opts.WarDown, len(cardsB) <= opts.WarDown
+        var result WarResult
+        if forfeit && shortA && !shortB {
+            result = WarResult{Winner: 2, PlayerBTricks: 1}
+        } else if forfeit && shortB && !shortA {
+            result = WarResult{Winner: 1, PlayerATricks: 1}
+        } else if len(cardsA) == 0 || len(cardsB) == 0 {
+            result = determineWarWinner(cardsA, cardsB)
+        } else if cardA, cardB := cardsA[len(cardsA)-1], cardsB[len(cardsB)-1]; sameRank(cardA, cardB, opts) {
+            // Tied again: another round, unless a player has nothing left
+            // to lay or the war has reached the depth cap.
+            stats.DeepWars++
+            remainingCardsA := cardsLeft(playerA)
+            remainingCardsB := cardsLeft(playerB)
+            if remainingCardsA == 0 && remainingCardsB == 0 {
+                result = WarResult{} // Both ran out; PlayGame applies the simultaneous end rule
+            } else if remainingCardsA == 0 {
+                result = WarResult{Winner: 2, PlayerBTricks: 1}
+            } else if remainingCardsB == 0 {
+                result = WarResult{Winner: 1, PlayerATricks: 1}
+            } else if opts.MaxWarDepth > 0 && depth >= opts.MaxWarDepth {
+                stats.DepthCapped++
+                result = depthCappedResult(playerA, playerB)
+            } else {
+                continue
+            }
+        } else if outranks(cardA, cardB, opts) {
+            result = WarResult{Winner: 1, PlayerATricks: 1, DecidedBy: cardA}
+        } else {
+            result = WarResult{Winner: 2, PlayerBTricks: 1, DecidedBy: cardB}
+        }
+        result.CardsA, result.CardsB = committedA, committedB
+        return result
+    }
+}
+
+// outranks reports whether a beats b: the higher rank, as played under the
+// joker rule, wins, and with the suit tie-break equal ranks go to the suit
+// that comes later in the suit order.
+func outranks(a, b Card, opts Options) bool {
+    rankA, rankB := playRanks(a, b, opts)
+    if rankA != rankB || opts.TieBreak != "suit" {
+        return rankA > rankB
+    }
+    return suitStrength(a.Suit, opts.SuitOrder) > suitStrength(b.Suit, opts.SuitOrder)
+}
+
+// sameRank reports whether a and b play at the same rank against each other.
+func sameRank(a, b Card, opts Options) bool {
+    rankA, rankB := playRanks(a, b, opts)
+    return rankA == rankB
+}
+
+// playRanks returns the ranks a and b play at against each other. Only a
+// joker's can differ from its face rank of 15: under the low joker rule it
+// is below every other card, and under wild it takes the other card's rank,
+// so that it ties and forces a war. Two jokers always tie.
+func playRanks(a, b Card, opts Options) (int, int) {
+    if a.Rank != 15 && b.Rank != 15 {
+        return a.Rank, b.Rank
+    }
+    return jokerRank(a, b.Rank, opts), jokerRank(b, a.Rank, opts)
+}
+
+// jokerRank is the rank card plays at against a card of rank other.
+func jokerRank(card Card, other int, opts Options) int {
+    if card.Rank != 15 {
+        return card.Rank
+    }
+    switch opts.JokerRule {
+    case "low":
+        return 1
+    case "wild":
+        return other
+    }
+    return card.Rank
+}
+
+// hasJoker reports whether cards include a joker.
+func hasJoker(cards []Card) bool {
+    for _, card := range cards {
+        if card.Rank == 15 {
+            return true
+        }
+    }
+    return false
+}
+
+// suitStrength is the position of suit in order, a string of the suit letters
+// c, d, h and s from lowest to highest.
+func suitStrength(suit Suit, order string) int {
+    return strings.IndexByte(order, "cdhs"[suit])
+}
+
+// resolveSimultaneousEnd decides a game in which both players ran out of cards
+// in the same trick, which can only happen when neither can continue a war.
+// The pile rule gives the game to whoever won more tricks.
+func resolveSimultaneousEnd(stats *GameStats, rule string) {
+    switch rule {
+    case "a":
+        stats.Winner = 1
+    case "b":
+        stats.Winner = 2
+    case "pile":
+        if stats.PlayerATricks > stats.PlayerBTricks {
+            stats.Winner = 1
+        } else if stats.PlayerBTricks > stats.PlayerATricks {
+            stats.Winner = 2
+        }
+    }
+    if stats.Winner == 0 {
+        stats.TerminationReason = "draw"
+    } else {
+        stats.TerminationReason = "simultaneous"
+    }
+}
+
+// timeoutResult decides a game stopped by the time limit in favour of the
+// player holding more cards, with Winner 0 for a draw when they hold the same
+// number. No trick is credited: the time ran out before one was won.
+func timeoutResult(playerA, playerB *Player) WarResult {
+    totalCardsA := ownCards(playerA)
+    totalCardsB := ownCards(playerB)
+
+    if totalCardsA > totalCardsB {
+        return WarResult{Winner: 1, TimedOut: true}
+    } else if totalCardsB > totalCardsA {
+        return WarResult{Winner: 2, TimedOut: true}
+    }
+    return WarResult{Winner: 0, TimedOut: true}
+}
+
+// timeUp reports whether totalTime has reached the time limit, and notes
+// the time checked as the game's LastTimeCheck.
+func timeUp(stats *GameStats, totalTime, maxGameTime int) bool {
+    stats.LastTimeCheck = max(stats.LastTimeCheck, time.Duration(totalTime)*time.Millisecond)
+    return totalTime >= maxGameTime
+}
+
+// endOnTimeout finishes a game stopped by the time limit with the winner, or
+// draw, that timeoutResult decides.
+func endOnTimeout(stats *GameStats, playerA, playerB *Player) {
+    stats.Winner = timeoutResult(playerA, playerB).Winner
+    stats.Finished = true
+    stats.TerminationReason = "timeout"
+}
+
+func determineWarWinner(cardsA, cardsB []Card) WarResult {
+    if len(cardsA) == 0 && len(cardsB) == 0 {
+        return WarResult{} // Both ran out; PlayGame applies the simultaneous end rule
+    }
+    if len(cardsA) == 0 {
+        return WarResult{Winner: 2, PlayerBTricks: 1}
+    }
+    return WarResult{Winner: 1, PlayerATricks: 1}
+}
+
+// depthCappedResult settles a war stopped by the depth cap the way a timeout
+// is decided: the player holding more cards wins it, and takes the pot along
+// with the trick. With equal holdings Winner is 0 and each player takes back
+// the cards they put in.
+func depthCappedResult(playerA, playerB *Player) WarResult {
+    result := timeoutResult(playerA, playerB)
+    result.TimedOut = false
+    result.DepthCapped = true
+    switch result.Winner {
+    case 1:
+        result.PlayerATricks = 1
+    case 2:
+        result.PlayerBTricks = 1
+    }
+    return result
+}
+
+// pipSum is the total rank of a hand, a rough measure of its strength.
+func pipSum(cards []Card) int {
+    sum := 0
+    for _, card := range cards {
+        sum += card.Rank
+    }
+    return sum
+}
+
+// DealBalance is the absolute difference between the players' starting pip
+// sums; 0 means a perfectly balanced deal.
+func (g GameStats) DealBalance() int {
+    if g.PipsA > g.PipsB {
+        return g.PipsA - g.PipsB
+    }
+    return g.PipsB - g.PipsA
+}
+
+// newPlayer returns a player holding hand as their draw pile, with room in
+// each pile for a deck of deckSize cards. Both piles share one allocation.
+func newPlayer(hand []Card, deckSize int) Player {
+    cards := make([]Card, 2*deckSize)
+    player := Player{
+        DrawPile:     Pile{cards: cards[:deckSize:deckSize]},
+        WinningsPile: Pile{cards: cards[deckSize:]},
+    }
+    player.DrawPile.Add(hand...)
+    return player
+}
+
+// cardsLeft is the number of cards player can still draw, including a shared
+// central discard they could reclaim.
+func cardsLeft(player *Player) int {
+    n := player.DrawPile.Len() + player.WinningsPile.Len()
+    if player.Discard != nil {
+        n += player.Discard.Len()
+    }
+    return n
+}
+
+// ownCards is the number of cards in player's own draw and winnings piles,
+// leaving out a shared central discard.
+func ownCards(player *Player) int {
+    return player.DrawPile.Len() + player.WinningsPile.Len()
+}
+
+// splitDiscard deals the central discard out alternately between both
+// players when both need to reclaim it at once, so that neither gets it all
+// just for drawing first. Each player's share is shuffled as it is drawn.
+func splitDiscard(playerA, playerB *Player, opts Options, rng *rand.Rand) {
+    if ownCards(playerA) > 0 || ownCards(playerB) > 0 {
+        return
+    }
+    discard := playerA.Discard
+    discard.shuffleAs(opts.ShuffleModel, rng)
+    for i := 0; discard.Len() > 0; i++ {
+        if i%2 == 0 {
+            playerA.WinningsPile.Add(discard.Draw())
+        } else {
+            playerB.WinningsPile.Add(discard.Draw())
+        }
+    }
+}
+
+// drawCard takes the top card of the player's draw pile, refilling it from the
+// winnings pile when empty, or from the central discard when both are empty.
+// The refill is shuffled first, in the ShuffleModel, unless NoReshuffle is
+// set, in which case it is simply flipped over and played in order.
+func drawCard(player *Player, opts Options, rng *rand.Rand) (Card, int) {
+    if player.DrawPile.Len() == 0 {
+        // Piles are swapped rather than copied, so the emptied pile's
+        // backing array is reused for the next cards won.
+        if player.WinningsPile.Len() == 0 {
+            if player.Discard == nil || player.Discard.Len() == 0 {
+                return Card{}, 0
+            }
+            player.WinningsPile, *player.Discard = *player.Discard, player.WinningsPile
+        }
+        player.DrawPile, player.WinningsPile = player.WinningsPile, player.DrawPile
+        if !opts.NoReshuffle {
+            player.DrawPile.shuffleAs(opts.ShuffleModel, rng)
+            return player.DrawPile.Draw(), 1
+        }
+    }
+    return player.DrawPile.Draw(), 0
+}
+
+// DeckSpec describes the deck games are played with: Copies cards of every
+// rank from MinRank to MaxRank. The copies of a rank take the suits in turn,
+// so a rank with more than four copies repeats suits. A deck with an odd
+// number of cards gives Player B the extra card.
+type DeckSpec struct {
+    MinRank int // Lowest rank, from 2
+    MaxRank int // Highest rank, up to 14 (Ace)
+    Copies  int // Cards of each rank
+}
+
+// StandardDeck is the 52-card deck: four suits of 2 to Ace.
+var StandardDeck = DeckSpec{MinRank: 2, MaxRank: 14, Copies: 4}
+
+// Size is the number of cards in the deck, not counting jokers.
+func (spec DeckSpec) Size() int {
+    return (spec.MaxRank - spec.MinRank + 1) * spec.Copies
+}
+
+func CreateDeck(spec DeckSpec, includeJokers bool) []Card {
+    return FillDeck(make([]Card, 0, spec.Size()+2), spec, includeJokers)
+}
+
+// FillDeck appends a complete, unshuffled deck made to spec to deck.
+func FillDeck(deck []Card, spec DeckSpec, includeJokers bool) []Card {
+    for rank := spec.MinRank; rank <= spec.MaxRank; rank++ { // 11=Jack, 12=Queen, 13=King, 14=Ace
+        for i := 0; i < spec.Copies; i++ {
+            deck = append(deck, Card{Rank: rank, Suit: Clubs + Suit(i%4)})
+        }
+    }
+    if includeJokers {
+        deck = append(deck, Card{Rank: 15, Suit: Clubs}, Card{Rank: 15, Suit: Diamonds}) // Black and red jokers
+    }
+    return deck
+}
+
+func ShuffleDeck(deck []Card, rng *rand.Rand) {
+    rng.Shuffle(len(deck), func(i, j int) {
+        deck[i], deck[j] = deck[j], deck[i]
+    })
+}
+
+// partialShuffle models an imperfectly shuffled new deck: a random
+// 1-sortedness fraction of the positions have their cards shuffled among
+// themselves and every other card stays where it was. A sortedness of 0 is an
+// ordinary full shuffle.
+func partialShuffle(deck []Card, sortedness float64, rng *rand.Rand) {
+    if sortedness == 0 {
+        ShuffleDeck(deck, rng)
+        return
+    }
+    moved := rng.Perm(len(deck))[:int(math.Round((1-sortedness)*float64(len(deck))))]
+    rng.Shuffle(len(moved), func(i, j int) {
+        deck[moved[i]], deck[moved[j]] = deck[moved[j]], deck[moved[i]]
+    })
+}